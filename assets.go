@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+
+	"MyLocalBoard/internal/assets"
+	"MyLocalBoard/internal/state"
+)
+
+// handleAssetMessage serves asset_request messages from store and takes in
+// asset_chunk messages, asking the sender for the next chunk through reply.
+// It returns false for any other message type.
+func handleAssetMessage(msg NetworkMessage, store *assets.Store, reply func(NetworkMessage)) bool {
+	switch msg.Type {
+	case "asset_request":
+		if msg.Asset == nil {
+			return true
+		}
+		if chunk, ok := store.Chunk(msg.Asset.Hash, msg.Asset.Index); ok {
+			reply(NetworkMessage{Type: "asset_chunk", Asset: &chunk})
+		}
+	case "asset_chunk":
+		if msg.Asset == nil {
+			return true
+		}
+		next, more, err := store.Receive(*msg.Asset)
+		if err != nil {
+			log.Printf("Dropping asset chunk: %v", err)
+			return true
+		}
+		if more {
+			reply(NetworkMessage{Type: "asset_request", Asset: &next})
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// requestAssets asks through send for the assets of the image elements in
+// paths that we lack and are not already downloading
+func requestAssets(store *assets.Store, paths []state.Path, send func(NetworkMessage)) {
+	for _, p := range paths {
+		if p.Image == nil {
+			continue
+		}
+		if request, ok := store.Wanted(p.Image.Asset); ok {
+			send(NetworkMessage{Type: "asset_request", Asset: &request})
+		}
+	}
+}
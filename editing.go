@@ -0,0 +1,155 @@
+package main
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/ui"
+)
+
+// bindEditing wires the board's local edits to doc. The resulting operations
+// are shown on the board and handed to send; role prefixes the log lines.
+func bindEditing(role string, board *ui.BoardWidget, doc *state.WhiteboardState, send func(NetworkMessage)) {
+	board.OnNewPath = func(p ui.Path) {
+		log.Printf("%s: New path with %d points", role, len(p.Points))
+		op := doc.AddLocalPath(p)
+		board.AddRemotePath(*op.Path) // Draw locally
+		send(NetworkMessage{Type: "op", Op: &op})
+	}
+
+	// Text elements keep their ID across edits; the newest version wins
+	board.OnEditPath = func(p ui.Path, continued bool) {
+		op, change := doc.EditLocal(p, continued)
+		showChange(board, change)
+		send(NetworkMessage{Type: "op", Op: &op})
+	}
+
+	board.OnDeletePaths = func(ids []string) {
+		ops, change := doc.DeleteLocal(board.LocalClientID, ids)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	// Moving, scaling and rotating send a transform per path, not its points
+	board.OnTransform = func(ids []string, t state.Transform) {
+		ops, change := doc.TransformLocal(board.LocalClientID, ids, t)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	board.OnAddPaths = func(paths []ui.Path) {
+		log.Printf("%s: Adding %d paths", role, len(paths))
+		ops, change := doc.AddLocalPaths(paths)
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	board.OnClear = func() {
+		log.Printf("%s: Clearing paths", role)
+		ops, change := doc.ClearLocal(board.LocalClientID)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	// Layers are records of their own; paths name the layer they are on
+	board.OnLayerChanged = func(l state.Layer) {
+		log.Printf("%s: Layer %s changed", role, l.ID)
+		op, change := doc.SetLayerLocal(board.LocalClientID, l)
+		showChange(board, change)
+		send(NetworkMessage{Type: "op", Op: &op})
+	}
+
+	// Bringing to the front or sending to the back gives paths a new height,
+	// which every peer stacks them by
+	board.OnRestack = func(ids []string, front bool) {
+		ops, change := doc.RestackLocal(board.LocalClientID, ids, front)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	// Pages are records of their own, like layers
+	board.OnFrameChanged = func(f state.Frame) {
+		log.Printf("%s: Page %s changed", role, f.ID)
+		op, change := doc.SetFrameLocal(board.LocalClientID, f)
+		showChange(board, change)
+		send(NetworkMessage{Type: "op", Op: &op})
+	}
+
+	board.OnDeletePage = func(id string, pathIDs []string) {
+		ops, change := doc.DeleteFrameLocal(board.LocalClientID, id, pathIDs)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	board.OnMoveToLayer = func(ids []string, layer string) {
+		ops, change := doc.MoveToLayerLocal(board.LocalClientID, ids, layer)
+		if len(ops) == 0 {
+			return
+		}
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	board.OnErase = func(trail []fyne.Position, radius float32, partial bool) {
+		ops, change := doc.EraseLocal(board.LocalClientID, trail, radius, partial)
+		if len(ops) == 0 {
+			return
+		}
+		log.Printf("%s: Erased %d paths", role, len(change.Removed))
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	// Undo and redo are new operations, so they merge with whatever others
+	// did in the meantime like any other edit.
+	board.OnUndo = func() {
+		ops, change := doc.Undo()
+		if len(ops) == 0 {
+			board.SetStatus("Nothing to undo")
+			return
+		}
+		log.Printf("%s: Undo with %d operations", role, len(ops))
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+
+	board.OnRedo = func() {
+		ops, change := doc.Redo()
+		if len(ops) == 0 {
+			board.SetStatus("Nothing to redo")
+			return
+		}
+		log.Printf("%s: Redo with %d operations", role, len(ops))
+		showChange(board, change)
+		sendOperations(ops, send)
+	}
+}
+
+// sendOperations sends a single operation as an op message and several as
+// sync_ops batches.
+func sendOperations(ops []state.PathOperation, send func(NetworkMessage)) {
+	if len(ops) == 1 {
+		send(NetworkMessage{Type: "op", Op: &ops[0]})
+		return
+	}
+	for _, batch := range operationBatches(ops, nil) {
+		send(batch)
+	}
+}
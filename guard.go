@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	localnet "MyLocalBoard/internal/net"
+	"MyLocalBoard/internal/state"
+)
+
+// Host-side limits on what a client may send
+const (
+	MaxMessageBytes   = 1 << 20 // Largest message the host reads
+	MaxPathsPerSecond = 20      // Sustained rate of new paths per client
+	PathBurst         = 40      // Paths a client may send in a burst
+	MaxViolations     = 10      // Violations per ViolationWindow before disconnecting
+	ViolationWindow   = time.Minute
+)
+
+// ProtocolError tells a client why its message was rejected
+type ProtocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	OpID    string `json:"op_id,omitempty"`
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// clientGuard validates everything one client sends to the host, answers
+// violations with an error message and disconnects clients that keep
+// violating the rules.
+type clientGuard struct {
+	conn        net.Conn
+	connManager *ConnectionManager
+	doc         *state.WhiteboardState
+	clientID    string // Set by a valid hello
+	siteID      string
+	paths       *localnet.RateLimiter
+	violations  int
+	windowStart time.Time
+}
+
+func newClientGuard(conn net.Conn, connManager *ConnectionManager, doc *state.WhiteboardState) *clientGuard {
+	return &clientGuard{
+		conn:        conn,
+		connManager: connManager,
+		doc:         doc,
+		paths:       localnet.NewRateLimiter(MaxPathsPerSecond, PathBurst),
+		windowStart: time.Now(),
+	}
+}
+
+// Reject reports err to the client and counts it as a violation
+func (g *clientGuard) Reject(err error) {
+	var perr *ProtocolError
+	if !errors.As(err, &perr) {
+		perr = &ProtocolError{Code: "invalid_message", Message: err.Error()}
+	}
+	log.Printf("Rejected message from %s: %v", g.conn.RemoteAddr(), perr)
+	data, _ := json.Marshal(NetworkMessage{Type: "error", Error: perr})
+	g.connManager.Send(g.conn, data)
+
+	if time.Since(g.windowStart) > ViolationWindow {
+		g.violations = 0
+		g.windowStart = time.Now()
+	}
+	g.violations++
+	if g.violations >= MaxViolations {
+		g.connManager.Disconnect(g.conn, fmt.Sprintf("too many invalid messages (last: %s)", perr.Message))
+	}
+}
+
+// Hello binds the connection to the client and site it introduces itself as
+func (g *clientGuard) Hello(msg NetworkMessage) error {
+	if err := g.connManager.ClaimClientID(g.conn, msg.ClientID, msg.SiteID); err != nil {
+		return &ProtocolError{Code: "bad_hello", Message: err.Error()}
+	}
+	g.clientID = msg.ClientID
+	g.siteID = msg.SiteID
+	return nil
+}
+
+// CheckOperation validates an operation sent by the client. Clients may only
+// send operations they created, acting for themselves. Deletes may target any
+// path, and a path of someone else may be added back unchanged, which is how
+// undoing an erase restores it. Text elements are shared: anyone may edit
+// the text of one, which stays with its owner. Nothing may add, change or
+// remove paths on a locked layer, and only the host locks and unlocks
+// layers. New paths are rate limited, whether they
+// come one by one or in a sync_ops batch.
+func (g *clientGuard) CheckOperation(op state.PathOperation, live bool) error {
+	err := g.checkOperation(op)
+	if err == nil && op.Type == state.OpAdd && !g.paths.Allow() {
+		err = &ProtocolError{Code: "rate_limited", Message: fmt.Sprintf("more than %d paths per second", MaxPathsPerSecond), OpID: op.ID}
+		if !live {
+			// What a batch holds over the limit comes again with the next
+			// state vector exchange, so it is skipped, not a violation
+			return err
+		}
+	}
+	if err != nil {
+		g.Reject(err)
+	}
+	return err
+}
+
+func (g *clientGuard) checkOperation(op state.PathOperation) error {
+	if g.clientID == "" {
+		return &ProtocolError{Code: "hello_required", Message: "send hello before any operation", OpID: op.ID}
+	}
+	if err := state.ValidateOperation(op, state.DefaultLimits); err != nil {
+		return &ProtocolError{Code: "invalid_operation", Message: err.Error(), OpID: op.ID}
+	}
+	if err := g.doc.CheckTimestamp(op, state.DefaultLimits); err != nil {
+		return &ProtocolError{Code: "invalid_timestamp", Message: err.Error(), OpID: op.ID}
+	}
+	if op.SiteID != g.siteID {
+		return &ProtocolError{Code: "forged_site", Message: "clients may only send their own operations", OpID: op.ID}
+	}
+	if owner := state.OperationOwner(op); owner != g.clientID && !g.isRestore(op) && !g.isTextEdit(op) {
+		return &ProtocolError{Code: "forged_owner", Message: fmt.Sprintf("operation acts for %q", owner), OpID: op.ID}
+	}
+	if l, ok := g.doc.LockedLayer(op); ok {
+		return &ProtocolError{Code: "layer_locked", Message: fmt.Sprintf("layer %q is locked", l.Name), OpID: op.ID}
+	}
+	if g.doc.ChangesLock(op) {
+		return &ProtocolError{Code: "lock_forbidden", Message: "only the host may lock or unlock layers", OpID: op.ID}
+	}
+	return nil
+}
+
+// isRestore reports whether op adds back a path the host already knows,
+// unchanged and where it was
+func (g *clientGuard) isRestore(op state.PathOperation) bool {
+	if op.Type != state.OpAdd || op.Path == nil {
+		return false
+	}
+	known, ok := g.doc.Path(op.Path.ID)
+	return ok && keepsPlacement(known, *op.Path) && state.SameContent(known, *op.Path)
+}
+
+// isTextEdit reports whether op replaces a text element the host already
+// knows with another version of it that differs only in its text, and in the
+// size of the box fitted around that
+func (g *clientGuard) isTextEdit(op state.PathOperation) bool {
+	if op.Type != state.OpAdd || op.Path == nil || op.Path.Text == nil {
+		return false
+	}
+	known, ok := g.doc.Path(op.Path.ID)
+	if !ok || known.Text == nil || !keepsPlacement(known, *op.Path) {
+		return false
+	}
+	if len(op.Path.Points) != 2 || len(known.Points) != 2 || op.Path.Points[0] != known.Points[0] {
+		return false
+	}
+	edited := *op.Path
+	edited.Text, edited.Points = known.Text, known.Points
+	return state.SameContent(known, edited)
+}
+
+// keepsPlacement reports whether adding p again leaves the known version of
+// it on its layer, as high and where it was placed. An add that leaves them
+// out keeps them.
+func keepsPlacement(known, p state.Path) bool {
+	return (p.Transform == nil || (known.Transform != nil && *p.Transform == *known.Transform)) &&
+		(p.Layer == "" || p.Layer == known.Layer) && (p.Z == 0 || p.Z == known.Z)
+}
+
+// CheckViewport validates a viewport update sent by the client
+func (g *clientGuard) CheckViewport(msg NetworkMessage) error {
+	var err error
+	if g.clientID == "" {
+		err = &ProtocolError{Code: "hello_required", Message: "send hello before a viewport"}
+	} else if verr := checkViewport(msg.View); verr != nil {
+		err = &ProtocolError{Code: "invalid_viewport", Message: verr.Error()}
+	}
+	if err != nil {
+		g.Reject(err)
+	}
+	return err
+}
+
+// CheckPresenter validates a present or summon message sent by the client
+func (g *clientGuard) CheckPresenter(msg NetworkMessage) error {
+	var err error
+	if g.clientID == "" {
+		err = &ProtocolError{Code: "hello_required", Message: "send hello before " + msg.Type}
+	} else if msg.Type == "summon" {
+		if verr := checkViewport(msg.View); verr != nil {
+			err = &ProtocolError{Code: "invalid_viewport", Message: verr.Error()}
+		}
+	}
+	if err != nil {
+		g.Reject(err)
+	}
+	return err
+}
+
+// CheckLock validates an edit lock sent by the client
+func (g *clientGuard) CheckLock(msg NetworkMessage) error {
+	var err error
+	if g.clientID == "" {
+		err = &ProtocolError{Code: "hello_required", Message: "send hello before a lock"}
+	} else if msg.Lock == nil || msg.Lock.ElementID == "" {
+		err = &ProtocolError{Code: "invalid_lock", Message: "lock has no element"}
+	}
+	if err != nil {
+		g.Reject(err)
+	}
+	return err
+}
+
+// CheckAsset validates an asset request or chunk sent by the client. Chunks
+// are checked further as they are put together.
+func (g *clientGuard) CheckAsset(msg NetworkMessage) error {
+	var err error
+	if g.clientID == "" {
+		err = &ProtocolError{Code: "hello_required", Message: "send hello before an asset"}
+	} else if msg.Asset == nil || !state.IsAssetHash(msg.Asset.Hash) {
+		err = &ProtocolError{Code: "invalid_asset", Message: "asset has no valid hash"}
+	}
+	if err != nil {
+		g.Reject(err)
+	}
+	return err
+}
+
+// CheckBatch validates the size of a sync_ops batch
+func (g *clientGuard) CheckBatch(msg NetworkMessage) error {
+	if len(msg.Ops) > MaxOpsPerMessage {
+		err := &ProtocolError{Code: "batch_too_large", Message: fmt.Sprintf("%d operations, the limit is %d", len(msg.Ops), MaxOpsPerMessage)}
+		g.Reject(err)
+		return err
+	}
+	return nil
+}
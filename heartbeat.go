@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"MyLocalBoard/internal/ui"
+)
+
+// Heartbeats: every side pings its peers and answers their pings. A
+// connection that stays silent for IdleTimeout is considered dead, which
+// catches half-open TCP connections such as a laptop with its lid closed.
+const (
+	PingInterval = 2 * time.Second
+	IdleTimeout  = 10 * time.Second
+)
+
+func newPing() NetworkMessage {
+	return NetworkMessage{Type: "ping", Sent: time.Now().UnixNano()}
+}
+
+// handleHeartbeat answers a ping and measures the round-trip time of a pong.
+// It returns false for any other message type.
+func handleHeartbeat(msg NetworkMessage, reply func(NetworkMessage), onLatency func(time.Duration)) bool {
+	switch msg.Type {
+	case "ping":
+		reply(NetworkMessage{Type: "pong", Sent: msg.Sent})
+	case "pong":
+		onLatency(time.Since(time.Unix(0, msg.Sent)))
+	default:
+		return false
+	}
+	return true
+}
+
+// isTimeout reports whether err is a read deadline expiring
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// pingClients pings every client and shares the participant list, with each
+// client's latency, with the host UI and all clients.
+func pingClients(connManager *ConnectionManager, board *ui.BoardWidget) {
+	ticker := time.NewTicker(PingInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		data, _ := json.Marshal(newPing())
+		connManager.Broadcast(data, nil)
+
+		participants := connManager.Participants(board.LocalClientID)
+		board.SetParticipants(participants)
+		data, _ = json.Marshal(NetworkMessage{Type: "participants", Participants: participants})
+		connManager.BroadcastLatest("participants", data, nil)
+	}
+}
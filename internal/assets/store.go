@@ -0,0 +1,311 @@
+// Package assets keeps the files image elements show. Assets are named by
+// the SHA-256 of their content, so a name always means the same bytes, and
+// peers fetch the ones they lack from each other in chunks.
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Registers JPEG for image.Decode
+	_ "image/png"  // Registers PNG for image.Decode
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"MyLocalBoard/internal/state"
+)
+
+// Limits of assets
+const (
+	MaxAssetBytes = 16 << 20
+	ChunkSize     = 64 << 10 // Bytes sent per message, well under the message limit
+	maxChunks     = (MaxAssetBytes + ChunkSize - 1) / ChunkSize
+)
+
+// maxCachedPixels bounds the decoded images kept, at four bytes a pixel;
+// the ones used longest ago are dropped first
+const maxCachedPixels = 4 * state.MaxImagePixels
+
+// StallTimeout is how long a download may go without a chunk before it is
+// asked for again, possibly from someone else
+const StallTimeout = 5 * time.Second
+
+// Chunk is part of an asset. A request for a chunk carries no Data.
+type Chunk struct {
+	Hash  string `json:"hash"`
+	Index int    `json:"index"`
+	Total int    `json:"total,omitempty"` // Chunks in the whole asset
+	Data  []byte `json:"data,omitempty"`
+}
+
+// download is an asset being fetched
+type download struct {
+	chunks   [][]byte // nil until received
+	received int
+	updated  time.Time // Last request or chunk
+}
+
+// Store holds assets in memory and caches them on disk, so they outlive the
+// session and are shared by the boards running on this machine.
+type Store struct {
+	mu        sync.Mutex
+	dir       string // Cache directory; empty keeps assets in memory only
+	blobs     map[string][]byte
+	images    map[string]image.Image // Decoded assets
+	recent    []string               // Decoded assets, the one used last at the end
+	pixels    int                    // Pixels of the decoded assets
+	downloads map[string]*download
+
+	// OnAdded is called with the hash of each asset added after being
+	// missing, such as a finished download
+	OnAdded func(hash string)
+}
+
+// NewStore returns a store caching assets in dir, or only in memory if dir is empty
+func NewStore(dir string) *Store {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Assets: Not caching on disk: %v", err)
+			dir = ""
+		}
+	}
+	return &Store{
+		dir:       dir,
+		blobs:     make(map[string][]byte),
+		images:    make(map[string]image.Image),
+		downloads: make(map[string]*download),
+	}
+}
+
+// DefaultDir returns where assets are cached, or "" if there is no cache
+// directory on this platform
+func DefaultDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "MyLocalBoard", "assets")
+}
+
+// Hash returns the name of an asset with the given content
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Add checks that data is a PNG or JPEG file within the limits, keeps it and
+// returns the image element content showing it
+func (s *Store) Add(data []byte) (state.Image, error) {
+	if len(data) > MaxAssetBytes {
+		return state.Image{}, fmt.Errorf("images are limited to %d MB", MaxAssetBytes>>20)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg") {
+		return state.Image{}, fmt.Errorf("not a PNG or JPEG image")
+	}
+	if !state.ImageSizeAllowed(config.Width, config.Height) {
+		return state.Image{}, fmt.Errorf("images are limited to %dx%d and %d megapixels", state.MaxImageSide, state.MaxImageSide, state.MaxImagePixels>>20)
+	}
+	hash := Hash(data)
+	s.mu.Lock()
+	_, known := s.blobs[hash]
+	s.blobs[hash] = data
+	delete(s.downloads, hash)
+	dir := s.dir
+	s.mu.Unlock()
+
+	if dir != "" {
+		if err := os.WriteFile(filepath.Join(dir, hash), data, 0o644); err != nil {
+			log.Printf("Assets: Caching %s failed: %v", hash, err)
+		}
+	}
+	if !known && s.OnAdded != nil {
+		s.OnAdded(hash)
+	}
+	return state.Image{Asset: hash, Width: config.Width, Height: config.Height}, nil
+}
+
+// Get returns the content of an asset, if we have it
+func (s *Store) Get(hash string) ([]byte, bool) {
+	if !state.IsAssetHash(hash) {
+		return nil, false
+	}
+	s.mu.Lock()
+	data, ok := s.blobs[hash]
+	dir := s.dir
+	s.mu.Unlock()
+	if ok || dir == "" {
+		return data, ok
+	}
+	// Cached by an earlier session or another board; trust it only if intact
+	data, err := os.ReadFile(filepath.Join(dir, hash))
+	if err != nil || Hash(data) != hash {
+		return nil, false
+	}
+	s.mu.Lock()
+	s.blobs[hash] = data
+	s.mu.Unlock()
+	return data, true
+}
+
+// Has reports whether we have an asset
+func (s *Store) Has(hash string) bool {
+	_, ok := s.Get(hash)
+	return ok
+}
+
+// Image returns an asset decoded, if we have it and it decodes
+func (s *Store) Image(hash string) (image.Image, bool) {
+	s.mu.Lock()
+	img, ok := s.images[hash]
+	if ok {
+		s.touchLocked(hash)
+	}
+	s.mu.Unlock()
+	if ok {
+		return img, true
+	}
+	data, ok := s.Get(hash)
+	if !ok {
+		return nil, false
+	}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil && !state.ImageSizeAllowed(config.Width, config.Height) {
+		log.Printf("Assets: Not decoding %s, it is %dx%d pixels", hash, config.Width, config.Height)
+		return nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Assets: Decoding %s failed: %v", hash, err)
+		return nil, false
+	}
+	s.mu.Lock()
+	s.cacheLocked(hash, img)
+	s.mu.Unlock()
+	return img, true
+}
+
+// touchLocked marks a decoded asset as the one used last. Callers must hold s.mu.
+func (s *Store) touchLocked(hash string) {
+	if i := slices.Index(s.recent, hash); i >= 0 {
+		s.recent = append(slices.Delete(s.recent, i, i+1), hash)
+	}
+}
+
+// cacheLocked keeps a decoded asset, dropping the ones used longest ago
+// while there are more than maxCachedPixels. Callers must hold s.mu.
+func (s *Store) cacheLocked(hash string, img image.Image) {
+	if _, ok := s.images[hash]; ok {
+		s.touchLocked(hash)
+		return
+	}
+	s.images[hash] = img
+	s.recent = append(s.recent, hash)
+	s.pixels += imagePixels(img)
+	for s.pixels > maxCachedPixels && len(s.recent) > 1 {
+		oldest := s.recent[0]
+		s.pixels -= imagePixels(s.images[oldest])
+		delete(s.images, oldest)
+		s.recent = s.recent[1:]
+	}
+}
+
+func imagePixels(img image.Image) int {
+	return img.Bounds().Dx() * img.Bounds().Dy()
+}
+
+// Chunk returns chunk index of an asset, if we have it
+func (s *Store) Chunk(hash string, index int) (Chunk, bool) {
+	data, ok := s.Get(hash)
+	if !ok {
+		return Chunk{}, false
+	}
+	total := (len(data) + ChunkSize - 1) / ChunkSize
+	if index < 0 || index >= total {
+		return Chunk{}, false
+	}
+	end := min((index+1)*ChunkSize, len(data))
+	return Chunk{Hash: hash, Index: index, Total: total, Data: data[index*ChunkSize : end]}, true
+}
+
+// Wanted returns the request for the next chunk of an asset we lack. It
+// returns false if we have the asset, or if its download made progress
+// within StallTimeout and needs no asking.
+func (s *Store) Wanted(hash string) (Chunk, bool) {
+	if !state.IsAssetHash(hash) || s.Has(hash) {
+		return Chunk{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.downloads[hash]
+	if !ok {
+		d = &download{}
+		s.downloads[hash] = d
+	} else if time.Since(d.updated) < StallTimeout {
+		return Chunk{}, false
+	}
+	d.updated = time.Now()
+	return Chunk{Hash: hash, Index: d.next()}, true
+}
+
+// next returns the first chunk not received yet
+func (d *download) next() int {
+	for i, c := range d.chunks {
+		if c == nil {
+			return i
+		}
+	}
+	return 0
+}
+
+// Receive takes in a chunk of an asset being downloaded. It returns the
+// request for the next chunk, or false once the asset is complete or was
+// not asked for.
+func (s *Store) Receive(c Chunk) (Chunk, bool, error) {
+	if !state.IsAssetHash(c.Hash) || c.Total <= 0 || c.Total > maxChunks || c.Index < 0 || c.Index >= c.Total {
+		return Chunk{}, false, fmt.Errorf("invalid chunk %d/%d of %q", c.Index, c.Total, c.Hash)
+	}
+	if len(c.Data) == 0 || len(c.Data) > ChunkSize || (c.Index < c.Total-1 && len(c.Data) != ChunkSize) {
+		return Chunk{}, false, fmt.Errorf("chunk %d of %s has %d bytes", c.Index, c.Hash, len(c.Data))
+	}
+
+	s.mu.Lock()
+	d, ok := s.downloads[c.Hash]
+	if !ok {
+		s.mu.Unlock()
+		return Chunk{}, false, nil // Not wanted, or already complete
+	}
+	if d.chunks == nil {
+		d.chunks = make([][]byte, c.Total) // The first chunk tells how many there are
+	} else if len(d.chunks) != c.Total {
+		s.mu.Unlock()
+		return Chunk{}, false, fmt.Errorf("chunk %d of %s claims %d chunks, not %d", c.Index, c.Hash, c.Total, len(d.chunks))
+	}
+	if d.chunks[c.Index] == nil {
+		d.chunks[c.Index] = c.Data
+		d.received++
+	}
+	d.updated = time.Now()
+	if d.received < len(d.chunks) {
+		next := Chunk{Hash: c.Hash, Index: d.next()}
+		s.mu.Unlock()
+		return next, true, nil
+	}
+	delete(s.downloads, c.Hash)
+	data := bytes.Join(d.chunks, nil)
+	s.mu.Unlock()
+
+	if Hash(data) != c.Hash {
+		return Chunk{}, false, fmt.Errorf("asset %s arrived corrupted", c.Hash)
+	}
+	if _, err := s.Add(data); err != nil {
+		return Chunk{}, false, fmt.Errorf("asset %s: %w", c.Hash, err)
+	}
+	log.Printf("Assets: Received %s (%d bytes)", c.Hash, len(data))
+	return Chunk{}, false, nil
+}
@@ -0,0 +1,46 @@
+// Package export renders the board to files other programs can open.
+// Strokes are drawn as filled outlines, so per-point widths survive.
+package export
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/stroke"
+)
+
+// Margin is the space left around the content, in board units
+const Margin float32 = 20
+
+// MaxPixels bounds the size of a raster export
+const MaxPixels = 64 << 20
+
+// contentBounds returns the box around all paths, with Margin added
+func contentBounds(paths []state.Path) (spatial.Rect, error) {
+	var bounds spatial.Rect
+	found := false
+	for _, p := range paths {
+		box, ok := state.PathBounds(p)
+		if !ok {
+			continue
+		}
+		if !found {
+			bounds, found = box, true
+		} else {
+			bounds = bounds.Union(box)
+		}
+	}
+	if !found {
+		return spatial.Rect{}, fmt.Errorf("the board is empty")
+	}
+	return bounds.Grow(Margin), nil
+}
+
+// outline returns the filled shape of a path, in board units
+func outline(p state.Path) [][]fyne.Position {
+	points, widths := p.PolylineWidths()
+	return stroke.Outline(points, widths)
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"bytes"
+	"image"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+
+	"MyLocalBoard/internal/state"
+)
+
+// Assets looks up the pictures image elements show
+type Assets interface {
+	Get(hash string) ([]byte, bool)
+	Image(hash string) (image.Image, bool)
+}
+
+// imageType returns the media type of an asset, which is a PNG or JPEG file
+func imageType(data []byte) string {
+	if bytes.HasPrefix(data, []byte("\x89PNG")) {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// pixelTransform returns the transform placing the pixels of img, as decoded,
+// where the image element p shows them on the board
+func pixelTransform(p state.Path, img image.Image) state.Transform {
+	// Stretch the picture over the element, whatever size it arrived in
+	bounds := img.Bounds()
+	sx := float32(p.Image.Width) / float32(bounds.Dx())
+	sy := float32(p.Image.Height) / float32(bounds.Dy())
+	fit := state.Transform{A: sx, D: sy, E: -float32(bounds.Min.X) * sx, F: -float32(bounds.Min.Y) * sy}
+	return fit.Then(p.ImageTransform())
+}
+
+// DrawImage draws the picture of the image element p into dst, mapping board
+// units to dst pixels with toDst. It reports false if the picture is missing.
+func DrawImage(dst draw.Image, p state.Path, assets Assets, toDst state.Transform) bool {
+	if assets == nil {
+		return false
+	}
+	img, ok := assets.Image(p.Image.Asset)
+	if !ok {
+		return false
+	}
+	t := pixelTransform(p, img).Then(toDst)
+	s2d := f64.Aff3{float64(t.A), float64(t.C), float64(t.E), float64(t.B), float64(t.D), float64(t.F)}
+	draw.ApproxBiLinear.Transform(dst, s2d, img, img.Bounds(), draw.Over, nil)
+	return true
+}
@@ -1,7 +1,243 @@
 package export
 
-// This file will handle the logic for exporting the whiteboard canvas to a PDF.
-// It will use the gofpdf library.
-func ExportToPDF() {
-	// TODO: Implement PDF export logic
-}
\ No newline at end of file
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+// pointsPerUnit maps board units to PDF points
+const pointsPerUnit = 0.75
+
+// writeFill adds polygons filled in hexColor to a page's content. PDF has y
+// pointing up. Alpha values used are collected in alphas.
+func writeFill(content *bytes.Buffer, alphas map[uint8]bool, hexColor string, polygons [][]fyne.Position, bounds spatial.Rect, pageHeight float32) {
+	c := color.NRGBAModel.Convert(state.HexToColor(hexColor)).(color.NRGBA)
+	alphas[c.A] = true
+	fmt.Fprintf(content, "/A%d gs\n", c.A)
+	fmt.Fprintf(content, "%.3f %.3f %.3f rg\n", float32(c.R)/255, float32(c.G)/255, float32(c.B)/255)
+	for _, polygon := range polygons {
+		for i, pt := range polygon {
+			x := (pt.X - bounds.MinX) * pointsPerUnit
+			y := pageHeight - (pt.Y-bounds.MinY)*pointsPerUnit
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(content, "%.2f %.2f %s\n", x, y, op)
+		}
+		content.WriteString("h\n")
+	}
+	content.WriteString("f\n")
+}
+
+// ExportToPDF writes paths, in drawing order, as a vector PDF. A board with a
+// background document gets a page per background page, showing the drawings
+// over it; any other board gets a single page sized to the content. Images
+// show their pictures from assets.
+func ExportToPDF(w io.Writer, paths []state.Path, assets Assets) error {
+	pages := backgroundPages(paths)
+	if len(pages) == 0 {
+		bounds, err := contentBounds(paths)
+		if err != nil {
+			return err
+		}
+		pages = []spatial.Rect{bounds}
+	}
+	return ExportPagesToPDF(w, paths, pages, assets)
+}
+
+// ExportPagesToPDF writes paths, in drawing order, as a vector PDF with a
+// page for each area of the board in pages
+func ExportPagesToPDF(w io.Writer, paths []state.Path, pages []spatial.Rect, assets Assets) error {
+	if len(pages) == 0 {
+		return fmt.Errorf("there are no pages to export")
+	}
+	text, err := newFonts()
+	if err != nil {
+		return err
+	}
+	defer text.Close()
+
+	// The catalog, the page tree and the font come first, then each page
+	// followed by its content, then the pictures
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // Page tree, once the pages are numbered
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	images := &pdfImages{assets: assets, first: len(objects) + 2*len(pages) + 1, names: make(map[string]string)}
+	var kids strings.Builder
+	for _, bounds := range pages {
+		pageWidth := (bounds.MaxX - bounds.MinX) * pointsPerUnit
+		pageHeight := (bounds.MaxY - bounds.MinY) * pointsPerUnit
+		content, alphas := writePage(paths, bounds, pageHeight, text, images)
+
+		var states bytes.Buffer
+		for _, a := range slices.Sorted(maps.Keys(alphas)) {
+			fmt.Fprintf(&states, " /A%d << /ca %.3f >>", a, float32(a)/255)
+		}
+		fmt.Fprintf(&kids, " %d 0 R", len(objects)+1)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /ExtGState << %s >> /Font << /F1 3 0 R >> /XObject << %s >> >> >>",
+				pageWidth, pageHeight, len(objects)+2, states.String(), images.resources()),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s ] /Count %d >>", kids.String(), len(pages))
+	objects = append(objects, images.objects...)
+
+	out := bufio.NewWriter(w)
+	offset, _ := fmt.Fprint(out, "%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = offset
+		n, _ := fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+		offset += n
+	}
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, offset)
+	return out.Flush()
+}
+
+// writePage returns the content of a page showing the area bounds of the
+// board, and the alpha values it uses. Each path is one filled shape or text,
+// after the fill of a shape or sticky note; paths outside the page are left
+// out, and the page cuts off those sticking out of it.
+func writePage(paths []state.Path, bounds spatial.Rect, pageHeight float32, text *fonts, images *pdfImages) (*bytes.Buffer, map[uint8]bool) {
+	var content bytes.Buffer
+	alphas := make(map[uint8]bool)
+	for _, p := range paths {
+		if box, ok := state.PathBounds(p); !ok || !box.Intersects(bounds) {
+			continue
+		}
+		if p.Image != nil {
+			if !images.write(&content, alphas, p, bounds, pageHeight) {
+				writeFill(&content, alphas, state.MissingImageColor, [][]fyne.Position{p.Polyline()}, bounds, pageHeight)
+			}
+			continue
+		}
+		if fill, c, ok := p.FillPolygon(); ok {
+			writeFill(&content, alphas, c, [][]fyne.Position{fill}, bounds, pageHeight)
+		}
+		if p.Text != nil {
+			text.writeText(&content, alphas, p, bounds, pageHeight)
+			continue
+		}
+		writeFill(&content, alphas, p.Color, outline(p), bounds, pageHeight)
+	}
+	return &content, alphas
+}
+
+// backgroundPages returns the areas of the board covered by the pages of the
+// background document, in page order
+func backgroundPages(paths []state.Path) []spatial.Rect {
+	var backgrounds []state.Path
+	for _, p := range paths {
+		if p.IsBackground() {
+			backgrounds = append(backgrounds, p)
+		}
+	}
+	state.SortPages(backgrounds)
+	var pages []spatial.Rect
+	for _, p := range backgrounds {
+		var points []spatial.Point
+		for _, pt := range p.Polyline() {
+			points = append(points, spatial.Point(pt))
+		}
+		if page, ok := spatial.BoundsOf(points, 0); ok {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// pdfImages turns the pictures of image elements into image objects, each
+// picture once, numbered on from first
+type pdfImages struct {
+	assets  Assets
+	first   int
+	names   map[string]string // Resource name by asset
+	objects []string
+	refs    strings.Builder // XObject resources
+}
+
+// write adds an image element to a page's content, reporting false if its
+// picture is missing
+func (x *pdfImages) write(content *bytes.Buffer, alphas map[uint8]bool, p state.Path, bounds spatial.Rect, pageHeight float32) bool {
+	name, ok := x.names[p.Image.Asset]
+	if !ok {
+		if x.assets == nil {
+			return false
+		}
+		img, found := x.assets.Image(p.Image.Asset)
+		if !found {
+			return false
+		}
+		name = fmt.Sprintf("Im%d", len(x.names))
+		x.names[p.Image.Asset] = name
+		x.add(name, img)
+	}
+	// An image fills the unit square, bottom up; stretch it over the
+	// element's pixels, then place those on the page
+	unit := state.Transform{A: float32(p.Image.Width), D: -float32(p.Image.Height), F: float32(p.Image.Height)}
+	page := state.Transform{A: pointsPerUnit, D: -pointsPerUnit, E: -bounds.MinX * pointsPerUnit, F: pageHeight + bounds.MinY*pointsPerUnit}
+	t := unit.Then(p.ImageTransform()).Then(page)
+	alphas[255] = true
+	fmt.Fprintf(content, "q /A255 gs %.5f %.5f %.5f %.5f %.2f %.2f cm /%s Do Q\n", t.A, t.B, t.C, t.D, t.E, t.F, name)
+	return true
+}
+
+// add appends the objects of a picture: its alpha as a soft mask, if it is
+// not opaque, then its colours
+func (x *pdfImages) add(name string, img image.Image) {
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 255
+		}
+	}
+	mask := ""
+	if !opaque {
+		x.objects = append(x.objects, imageObject(bounds, "/DeviceGray", "", alpha))
+		mask = fmt.Sprintf(" /SMask %d 0 R", x.first+len(x.objects)-1)
+	}
+	x.objects = append(x.objects, imageObject(bounds, "/DeviceRGB", mask, rgb))
+	fmt.Fprintf(&x.refs, " /%s %d 0 R", name, x.first+len(x.objects)-1)
+}
+
+// imageObject returns an image object holding pixels, compressed
+func imageObject(bounds image.Rectangle, colorSpace, extra string, pixels []byte) string {
+	var data bytes.Buffer
+	z := zlib.NewWriter(&data)
+	z.Write(pixels)
+	z.Close()
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /FlateDecode%s /Length %d >>\nstream\n%s\nendstream",
+		bounds.Dx(), bounds.Dy(), colorSpace, extra, data.Len(), data.String())
+}
+
+// resources returns the XObject resources of the pictures added
+func (x *pdfImages) resources() string {
+	return x.refs.String()
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"golang.org/x/image/vector"
+
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/stroke"
+)
+
+// ExportToPNG draws paths on a white background at scale pixels per board
+// unit and writes the image to w. Images show their pictures from assets.
+func ExportToPNG(w io.Writer, paths []state.Path, assets Assets, scale float32) error {
+	bounds, err := contentBounds(paths)
+	if err != nil {
+		return err
+	}
+	width := int(math.Ceil(float64((bounds.MaxX - bounds.MinX) * scale)))
+	height := int(math.Ceil(float64((bounds.MaxY - bounds.MinY) * scale)))
+	if width*height > MaxPixels {
+		return fmt.Errorf("a %dx%d image is too large, export at a smaller scale", width, height)
+	}
+
+	text, err := newFonts()
+	if err != nil {
+		return err
+	}
+	defer text.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Rect, image.White, image.Point{}, draw.Src)
+	z := vector.NewRasterizer(width, height)
+	for _, p := range paths {
+		// Rasterize only the box around the path, not the whole image
+		box, ok := state.PathBounds(p)
+		if !ok {
+			continue
+		}
+		area := image.Rect(
+			int((box.MinX-bounds.MinX)*scale)-1, int((box.MinY-bounds.MinY)*scale)-1,
+			int((box.MaxX-bounds.MinX)*scale)+2, int((box.MaxY-bounds.MinY)*scale)+2,
+		).Intersect(img.Rect)
+		if area.Empty() {
+			continue
+		}
+		ox, oy := float32(area.Min.X), float32(area.Min.Y)
+		addPolygon := func(polygon []fyne.Position) {
+			for i, pt := range polygon {
+				x, y := (pt.X-bounds.MinX)*scale-ox, (pt.Y-bounds.MinY)*scale-oy
+				if i == 0 {
+					z.MoveTo(x, y)
+				} else {
+					z.LineTo(x, y)
+				}
+			}
+			z.ClosePath()
+		}
+		if p.Image != nil {
+			toImage := state.Translate(-bounds.MinX, -bounds.MinY).Then(state.Transform{A: scale, D: scale})
+			if !DrawImage(img, p, assets, toImage) {
+				z.Reset(area.Dx(), area.Dy())
+				addPolygon(p.Polyline())
+				z.Draw(img, area, image.NewUniform(state.HexToColor(state.MissingImageColor)), image.Point{})
+			}
+			continue
+		}
+		if fill, c, ok := p.FillPolygon(); ok {
+			z.Reset(area.Dx(), area.Dy())
+			addPolygon(fill)
+			z.Draw(img, area, image.NewUniform(color.NRGBAModel.Convert(state.HexToColor(c))), image.Point{})
+		}
+		if p.Text != nil {
+			text.drawText(img, p, bounds, scale)
+			continue
+		}
+		z.Reset(area.Dx(), area.Dy())
+		points, widths := p.PolylineWidths()
+		stroke.EachOutlinePolygon(points, widths, addPolygon)
+		z.Draw(img, area, image.NewUniform(color.NRGBAModel.Convert(state.HexToColor(p.Color))), image.Point{})
+	}
+	return png.Encode(w, img)
+}
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// AddPNGText returns the PNG image data with text stored under keyword, in
+// an uncompressed international text chunk right after the header
+func AddPNGText(data []byte, keyword, text string) ([]byte, error) {
+	const headerEnd = len(pngSignature) + 8 + 13 + 4 // The IHDR chunk comes first
+	if len(data) < headerEnd || string(data[:len(pngSignature)]) != pngSignature {
+		return nil, fmt.Errorf("not a PNG image")
+	}
+	body := []byte("iTXt" + keyword + "\x00\x00\x00\x00\x00" + text)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4))
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:headerEnd]...)
+	out = append(out, chunk...)
+	return append(out, data[headerEnd:]...), nil
+}
+
+// PNGText returns the text stored under keyword in an uncompressed
+// international text chunk of the PNG image data, as AddPNGText stores it
+func PNGText(data []byte, keyword string) (string, bool) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return "", false
+	}
+	prefix := []byte(keyword + "\x00\x00\x00\x00\x00")
+	for rest := data[len(pngSignature):]; len(rest) >= 12; {
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n) > uint64(len(rest)-12) {
+			break
+		}
+		typ, body := string(rest[4:8]), rest[8:8+n]
+		if typ == "iTXt" && bytes.HasPrefix(body, prefix) {
+			return string(body[len(prefix):]), true
+		}
+		if typ == "IDAT" || typ == "IEND" {
+			break // Text we stored comes before the image data
+		}
+		rest = rest[12+n:]
+	}
+	return "", false
+}
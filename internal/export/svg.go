@@ -0,0 +1,112 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+// ExportToSVG writes paths as an SVG image sized to the content, one unit per
+// board unit, with the pictures of images from assets embedded. metadata, if
+// not empty, is embedded as the image's metadata, which is how the board
+// finds its own paths in an SVG pasted back into it.
+func ExportToSVG(w io.Writer, paths []state.Path, assets Assets, metadata string) error {
+	bounds, err := contentBounds(paths)
+	if err != nil {
+		return err
+	}
+	text, err := newFonts()
+	if err != nil {
+		return err
+	}
+	defer text.Close()
+
+	width, height := bounds.MaxX-bounds.MinX, bounds.MaxY-bounds.MinY
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%.2f" height="%.2f" viewBox="0 0 %.2f %.2f">`+"\n", width, height, width, height)
+	if metadata != "" {
+		out.WriteString(`<metadata id="mylocalboard">`)
+		xml.EscapeText(out, []byte(metadata))
+		out.WriteString("</metadata>\n")
+	}
+	fmt.Fprintf(out, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	for _, p := range paths {
+		if p.Image != nil {
+			writeSVGImage(out, p, assets, bounds)
+			continue
+		}
+		if fill, c, ok := p.FillPolygon(); ok {
+			writeSVGPath(out, c, [][]fyne.Position{fill}, bounds)
+		}
+		if p.Text != nil {
+			text.writeSVGText(out, p, bounds)
+			continue
+		}
+		writeSVGPath(out, p.Color, outline(p), bounds)
+	}
+	out.WriteString("</svg>\n")
+	return out.Flush()
+}
+
+// writeSVGPath adds polygons filled in hexColor as one path element
+func writeSVGPath(out *bufio.Writer, hexColor string, polygons [][]fyne.Position, bounds spatial.Rect) {
+	var d strings.Builder
+	for _, polygon := range polygons {
+		for i, pt := range polygon {
+			op := "L"
+			if i == 0 {
+				op = "M"
+			}
+			fmt.Fprintf(&d, "%s%.2f %.2f", op, pt.X-bounds.MinX, pt.Y-bounds.MinY)
+		}
+		d.WriteString("Z")
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(out, `<path d="%s" %s/>`+"\n", d.String(), svgFill(hexColor))
+}
+
+// writeSVGImage adds the picture of an image element, embedded as a data URI
+func writeSVGImage(out *bufio.Writer, p state.Path, assets Assets, bounds spatial.Rect) {
+	var data []byte
+	ok := false
+	if assets != nil {
+		data, ok = assets.Get(p.Image.Asset)
+	}
+	if !ok {
+		writeSVGPath(out, state.MissingImageColor, [][]fyne.Position{p.Polyline()}, bounds)
+		return
+	}
+	t := p.ImageTransform().Then(state.Translate(-bounds.MinX, -bounds.MinY))
+	fmt.Fprintf(out, `<image width="%d" height="%d" preserveAspectRatio="none" transform="matrix(%.5f %.5f %.5f %.5f %.2f %.2f)" xlink:href="data:%s;base64,%s"/>`+"\n",
+		p.Image.Width, p.Image.Height, t.A, t.B, t.C, t.D, t.E, t.F, imageType(data), base64.StdEncoding.EncodeToString(data))
+}
+
+// writeSVGText adds the lines of a text element, broken as on the board
+func (f *fonts) writeSVGText(out *bufio.Writer, p state.Path, bounds spatial.Rect) {
+	for _, line := range state.LayoutText(p, f.measure) {
+		fmt.Fprintf(out, `<text x="%.2f" y="%.2f" font-family="sans-serif" font-size="%.2f" %s xml:space="preserve">`,
+			line.Pos.X-bounds.MinX, line.Pos.Y-bounds.MinY+f.ascent(p.Text.Size), p.Text.Size, svgFill(p.Color))
+		xml.EscapeText(out, []byte(line.Text))
+		out.WriteString("</text>\n")
+	}
+}
+
+// svgFill returns the fill attributes for a colour
+func svgFill(hexColor string) string {
+	c := color.NRGBAModel.Convert(state.HexToColor(hexColor)).(color.NRGBA)
+	if c.A == 255 {
+		return fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	}
+	return fmt.Sprintf(`fill="#%02x%02x%02x" fill-opacity="%.3f"`, c.R, c.G, c.B, float32(c.A)/255)
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2/theme"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+var (
+	loadFont  sync.Once
+	boardFont *opentype.Font
+	fontErr   error
+)
+
+// fonts measures and draws text in the font the board shows it in, so text
+// wraps the same way in exports as on screen
+type fonts struct {
+	faces map[float32]font.Face
+}
+
+func newFonts() (*fonts, error) {
+	loadFont.Do(func() {
+		boardFont, fontErr = opentype.Parse(theme.DefaultTextFont().Content())
+	})
+	if fontErr != nil {
+		return nil, fmt.Errorf("loading the text font: %w", fontErr)
+	}
+	return &fonts{faces: make(map[float32]font.Face)}, nil
+}
+
+// face returns the font at size pixels
+func (f *fonts) face(size float32) font.Face {
+	face, ok := f.faces[size]
+	if !ok {
+		face, _ = opentype.NewFace(boardFont, &opentype.FaceOptions{Size: float64(size), DPI: 72})
+		f.faces[size] = face
+	}
+	return face
+}
+
+// measure returns how wide text is at size, for state.LayoutText
+func (f *fonts) measure(text string, size float32) float32 {
+	return float32(font.MeasureString(f.face(size), text)) / 64
+}
+
+// ascent returns how far the baseline is below the top of a line at size
+func (f *fonts) ascent(size float32) float32 {
+	return float32(f.face(size).Metrics().Ascent) / 64
+}
+
+func (f *fonts) Close() {
+	for _, face := range f.faces {
+		face.Close()
+	}
+}
+
+// drawText draws the lines of a text element into img at scale pixels per
+// board unit, with bounds.MinX, bounds.MinY at the image's origin
+func (f *fonts) drawText(img *image.RGBA, p state.Path, bounds spatial.Rect, scale float32) {
+	size := p.Text.Size * scale
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.NRGBAModel.Convert(state.HexToColor(p.Color))),
+		Face: f.face(size),
+	}
+	for _, line := range state.LayoutText(p, f.measure) {
+		x := (line.Pos.X - bounds.MinX) * scale
+		y := (line.Pos.Y-bounds.MinY)*scale + f.ascent(size)
+		drawer.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+		drawer.DrawString(line.Text)
+	}
+}
+
+// writeText adds the lines of a text element to a page's content in
+// Helvetica, which every PDF reader has. Lines are broken as on the board;
+// characters Helvetica's encoding lacks are written as "?".
+func (f *fonts) writeText(content *bytes.Buffer, alphas map[uint8]bool, p state.Path, bounds spatial.Rect, pageHeight float32) {
+	c := color.NRGBAModel.Convert(state.HexToColor(p.Color)).(color.NRGBA)
+	alphas[c.A] = true
+	fmt.Fprintf(content, "/A%d gs\n", c.A)
+	fmt.Fprintf(content, "%.3f %.3f %.3f rg\n", float32(c.R)/255, float32(c.G)/255, float32(c.B)/255)
+	for _, line := range state.LayoutText(p, f.measure) {
+		x := (line.Pos.X - bounds.MinX) * pointsPerUnit
+		y := pageHeight - (line.Pos.Y-bounds.MinY+f.ascent(p.Text.Size))*pointsPerUnit
+		fmt.Fprintf(content, "BT /F1 %.2f Tf %.2f %.2f Td (%s) Tj ET\n", p.Text.Size*pointsPerUnit, x, y, pdfString(line.Text))
+	}
+}
+
+// pdfString encodes s as the inside of a PDF string in WinAnsiEncoding,
+// which matches Latin-1 for the characters kept
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r <= 0x7e:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// DiscoveryPort is the UDP port mesh peers announce themselves on
+const DiscoveryPort = 8891
+
+// Beacon is broadcast on the LAN by every mesh peer
+type Beacon struct {
+	SiteID string `json:"site_id"`
+	Port   int    `json:"port"` // TCP port the peer accepts connections on
+}
+
+// AnnouncePeer broadcasts beacon on the local network every interval
+func AnnouncePeer(beacon Beacon, interval time.Duration) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		log.Printf("Discovery: failed to open announce socket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	data, err := json.Marshal(beacon)
+	if err != nil {
+		log.Printf("Discovery: failed to marshal beacon: %v", err)
+		return
+	}
+	dst := &net.UDPAddr{IP: net.IPv4bcast, Port: DiscoveryPort}
+
+	for {
+		if _, err := conn.WriteTo(data, dst); err != nil {
+			log.Printf("Discovery: failed to announce: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// DiscoverPeers listens for beacons and calls found with the TCP address of
+// every peer that announces itself, including ourselves.
+func DiscoverPeers(found func(address string, beacon Beacon)) {
+	conn, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", DiscoveryPort))
+	if err != nil {
+		log.Printf("Discovery: failed to listen on port %d: %v", DiscoveryPort, err)
+		return
+	}
+	defer conn.Close()
+
+	buffer := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			log.Printf("Discovery: read failed: %v", err)
+			return
+		}
+		var beacon Beacon
+		if err := json.Unmarshal(buffer[:n], &beacon); err != nil || beacon.SiteID == "" {
+			continue
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		found(fmt.Sprintf("%s:%d", udpAddr.IP, beacon.Port), beacon)
+	}
+}
@@ -0,0 +1,40 @@
+package net
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// ErrMessageTooLarge is returned by MessageReader for a message over the limit.
+// The message is skipped, so reading can continue.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageReader reads newline-delimited messages of bounded size
+type MessageReader struct {
+	r *bufio.Reader
+}
+
+func NewMessageReader(r io.Reader, maxBytes int) *MessageReader {
+	return &MessageReader{r: bufio.NewReaderSize(r, maxBytes)}
+}
+
+// Next returns the next message without its delimiter. The returned slice is
+// only valid until the next call.
+func (mr *MessageReader) Next() ([]byte, error) {
+	line, err := mr.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Skip the rest of the oversized message
+		for err == bufio.ErrBufferFull {
+			_, err = mr.r.ReadSlice('\n')
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrMessageTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return line[:len(line)-1], nil
+}
@@ -0,0 +1,233 @@
+package net
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Send queue defaults
+const (
+	SendQueueCapacity   = 256              // Messages queued per connection before dropping
+	WriteTimeout        = 5 * time.Second  // Deadline for a single write
+	SlowConsumerTimeout = 10 * time.Second // How long a queue may stay full before we give up
+)
+
+// QueueStats are the metrics of one send queue
+type QueueStats struct {
+	Depth     int   // Messages currently waiting
+	HighWater int   // Deepest the queue has been
+	Sent      int64 // Messages written
+	Dropped   int64 // Messages dropped because the queue was full
+	Coalesced int64 // Updates replaced by a newer one before being sent
+}
+
+// SendQueue owns all writes to one connection. A writer goroutine drains a
+// bounded queue so that a stalled peer never blocks the caller.
+//
+// Slow consumers are handled in three steps:
+//   - updates sent with SendLatest (cursors, viewports) are coalesced by key
+//     and are only written once the regular queue is empty
+//   - when the regular queue is full, new messages are dropped; operations
+//     lost this way are repaired by the next state vector exchange
+//   - if the queue stays full for SlowConsumerTimeout, or a write misses its
+//     deadline, the connection is closed with a reason
+type SendQueue struct {
+	conn   net.Conn
+	queue  chan []byte
+	wake   chan struct{}
+	closed chan struct{}
+
+	mu         sync.Mutex
+	latest     map[string][]byte // Coalesced updates, newest per key
+	latestKeys []string          // Keys of latest in arrival order
+	fullSince  time.Time
+	highWater  int
+	closeOnce  sync.Once
+	reason     string
+
+	sent      atomic.Int64
+	dropped   atomic.Int64
+	coalesced atomic.Int64
+
+	// Farewell builds a last message telling the peer why it is being
+	// disconnected. It is written with a short deadline, best effort.
+	Farewell func(reason string) []byte
+	// OnClose is called once the connection has been closed
+	OnClose func(reason string)
+}
+
+// NewSendQueue creates a queue for conn. Call Start once the hooks are set.
+func NewSendQueue(conn net.Conn, capacity int) *SendQueue {
+	return &SendQueue{
+		conn:   conn,
+		queue:  make(chan []byte, capacity),
+		wake:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		latest: make(map[string][]byte),
+	}
+}
+
+// Start launches the writer goroutine
+func (q *SendQueue) Start() {
+	go q.run()
+}
+
+// Send queues a newline-delimited message without blocking. It returns false
+// if the message was dropped.
+func (q *SendQueue) Send(data []byte) bool {
+	msg := append(append(make([]byte, 0, len(data)+1), data...), '\n')
+	select {
+	case <-q.closed:
+		return false
+	case q.queue <- msg:
+		q.mu.Lock()
+		q.fullSince = time.Time{}
+		if depth := len(q.queue) + len(q.latest); depth > q.highWater {
+			q.highWater = depth
+		}
+		q.mu.Unlock()
+		return true
+	default:
+	}
+
+	q.dropped.Add(1)
+	q.mu.Lock()
+	if q.fullSince.IsZero() {
+		q.fullSince = time.Now()
+		log.Printf("Send queue to %s is full, dropping messages", q.conn.RemoteAddr())
+	}
+	stalled := time.Since(q.fullSince) > SlowConsumerTimeout
+	q.mu.Unlock()
+
+	if stalled {
+		q.Close(fmt.Sprintf("send queue full for more than %s", SlowConsumerTimeout))
+	}
+	return false
+}
+
+// SendLatest queues an update that only matters in its newest version, such
+// as a cursor position. A queued update with the same key is replaced.
+func (q *SendQueue) SendLatest(key string, data []byte) {
+	msg := append(append(make([]byte, 0, len(data)+1), data...), '\n')
+	q.mu.Lock()
+	if _, exists := q.latest[key]; exists {
+		q.coalesced.Add(1)
+	} else {
+		q.latestKeys = append(q.latestKeys, key)
+	}
+	q.latest[key] = msg
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the writer and closes the connection. Only the first reason is
+// kept; an empty reason closes quietly, without a farewell message.
+func (q *SendQueue) Close(reason string) {
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.reason = reason
+		q.mu.Unlock()
+		close(q.closed)
+	})
+}
+
+// Stats returns the current metrics of the queue
+func (q *SendQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueStats{
+		Depth:     len(q.queue) + len(q.latest),
+		HighWater: q.highWater,
+		Sent:      q.sent.Load(),
+		Dropped:   q.dropped.Load(),
+		Coalesced: q.coalesced.Load(),
+	}
+}
+
+func (q *SendQueue) run() {
+	for {
+		// Regular messages always go before coalesced updates
+		select {
+		case msg := <-q.queue:
+			if !q.write(msg) {
+				return
+			}
+			continue
+		case <-q.closed:
+			q.shutdown()
+			return
+		default:
+		}
+
+		if msg, ok := q.popLatest(); ok {
+			if !q.write(msg) {
+				return
+			}
+			continue
+		}
+
+		select {
+		case msg := <-q.queue:
+			if !q.write(msg) {
+				return
+			}
+		case <-q.wake:
+		case <-q.closed:
+			q.shutdown()
+			return
+		}
+	}
+}
+
+func (q *SendQueue) popLatest() ([]byte, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.latestKeys) == 0 {
+		return nil, false
+	}
+	key := q.latestKeys[0]
+	q.latestKeys = q.latestKeys[1:]
+	msg := q.latest[key]
+	delete(q.latest, key)
+	return msg, true
+}
+
+func (q *SendQueue) write(msg []byte) bool {
+	q.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if _, err := q.conn.Write(msg); err != nil {
+		q.Close(fmt.Sprintf("write failed: %v", err))
+		q.shutdown()
+		return false
+	}
+	q.sent.Add(1)
+	return true
+}
+
+func (q *SendQueue) shutdown() {
+	q.mu.Lock()
+	reason := q.reason
+	q.mu.Unlock()
+
+	if q.Farewell != nil && reason != "" {
+		if msg := q.Farewell(reason); msg != nil {
+			q.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			q.conn.Write(append(msg, '\n'))
+		}
+	}
+	q.conn.Close()
+	if reason != "" {
+		log.Printf("Closed connection to %s: %s", q.conn.RemoteAddr(), reason)
+	}
+
+	if q.OnClose != nil {
+		q.OnClose(reason)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"net"
 	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 )
@@ -49,53 +50,107 @@ type DrawOperation struct {
 type Peer struct {
 	Conn     net.Conn
 	ClientID string
+	queue    *SendQueue
 }
 
+// DefaultMaxMessageBytes is the longest message a PeerManager reads unless
+// told otherwise
+const DefaultMaxMessageBytes = 1 << 20
+
 type PeerManager struct {
 	peers    map[string]*Peer
 	mu       sync.RWMutex
 	Messages chan Message // Now sends Message struct with client ID
+
+	// Peers that stay silent for longer are dropped; zero disables the timeout
+	IdleTimeout time.Duration
+	// Longer messages from peers are skipped
+	MaxMessageBytes int
+
+	// Optional hooks, called without the lock held
+	OnPeerConnected    func(clientID string)
+	OnPeerDisconnected func(clientID string)
 }
 
 func NewPeerManager() *PeerManager {
 	return &PeerManager{
-		peers:    make(map[string]*Peer),
-		Messages: make(chan Message, 100), // Buffered channel
+		peers:           make(map[string]*Peer),
+		Messages:        make(chan Message, 100), // Buffered channel
+		MaxMessageBytes: DefaultMaxMessageBytes,
 	}
 }
 
 func (pm *PeerManager) Add(peer *Peer) {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
 	addr := peer.Conn.RemoteAddr().String()
 	peer.ClientID = addr // Use address as client ID for now
+	peer.queue = NewSendQueue(peer.Conn, SendQueueCapacity)
+	peer.queue.OnClose = func(string) { pm.Remove(addr) }
+	peer.queue.Start()
 	pm.peers[addr] = peer
+	pm.mu.Unlock()
 	log.Printf("Added new client connection: %s", addr)
+
+	if pm.OnPeerConnected != nil {
+		pm.OnPeerConnected(addr)
+	}
 }
 
 func (pm *PeerManager) Remove(clientID string) {
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
-	if peer, exists := pm.peers[clientID]; exists {
-		peer.Conn.Close()
+	peer, exists := pm.peers[clientID]
+	if exists {
+		peer.queue.Close("")
 		delete(pm.peers, clientID)
-		log.Printf("Removed client connection: %s", clientID)
 	}
+	pm.mu.Unlock()
+	if !exists {
+		return
+	}
+	log.Printf("Removed client connection: %s", clientID)
+
+	if pm.OnPeerDisconnected != nil {
+		pm.OnPeerDisconnected(clientID)
+	}
+}
+
+// PeerCount returns the number of connected peers
+func (pm *PeerManager) PeerCount() int {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return len(pm.peers)
 }
 
+// Connect dials another peer and handles it like an accepted connection
+func (pm *PeerManager) Connect(address string) error {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	peer := &Peer{Conn: conn}
+	pm.Add(peer)
+	go pm.handleConnection(peer)
+	return nil
+}
+
+// Broadcast queues msg for every peer. It never blocks on a slow peer.
 func (pm *PeerManager) Broadcast(msg []byte) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 	
-	// Add newline delimiter for proper message framing
-	msgWithDelim := append(msg, '\n')
-	
-	for addr, peer := range pm.peers {
-		_, err := peer.Conn.Write(msgWithDelim)
-		if err != nil {
-			log.Printf("Error writing to client %s: %v. Removing client.", addr, err)
-			go pm.Remove(addr) // Remove in goroutine to avoid deadlock
-		}
+	for _, peer := range pm.peers {
+		peer.queue.Send(msg)
+	}
+}
+
+// BroadcastLatest queues an update of which only the newest version per key
+// matters for every peer; slow peers get it coalesced.
+func (pm *PeerManager) BroadcastLatest(key string, msg []byte) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	for _, peer := range pm.peers {
+		peer.queue.SendLatest(key, msg)
 	}
 }
 
@@ -103,15 +158,9 @@ func (pm *PeerManager) BroadcastExcept(excludeClientID string, msg []byte) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 	
-	msgWithDelim := append(msg, '\n')
-	
 	for addr, peer := range pm.peers {
 		if addr != excludeClientID {
-			_, err := peer.Conn.Write(msgWithDelim)
-			if err != nil {
-				log.Printf("Error writing to client %s: %v. Removing client.", addr, err)
-				go pm.Remove(addr)
-			}
+			peer.queue.Send(msg)
 		}
 	}
 }
@@ -121,13 +170,20 @@ func (pm *PeerManager) SendToClient(clientID string, msg []byte) {
 	defer pm.mu.RUnlock()
 	
 	if peer, exists := pm.peers[clientID]; exists {
-		msgWithDelim := append(msg, '\n')
-		_, err := peer.Conn.Write(msgWithDelim)
-		if err != nil {
-			log.Printf("Error writing to client %s: %v. Removing client.", clientID, err)
-			go pm.Remove(clientID)
-		}
+		peer.queue.Send(msg)
+	}
+}
+
+// QueueStats returns the send queue metrics of every peer
+func (pm *PeerManager) QueueStats() map[string]QueueStats {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	stats := make(map[string]QueueStats, len(pm.peers))
+	for addr, peer := range pm.peers {
+		stats[addr] = peer.queue.Stats()
 	}
+	return stats
 }
 
 func (pm *PeerManager) StartTCPServer(port int) {
@@ -158,47 +214,35 @@ func (pm *PeerManager) handleConnection(peer *Peer) {
 		pm.Remove(addr)
 	}()
 	
-	buffer := make([]byte, 0)
-	tempBuf := make([]byte, 4096)
-	
+	reader := NewMessageReader(peer.Conn, pm.MaxMessageBytes)
 	for {
-		n, err := peer.Conn.Read(tempBuf)
+		if pm.IdleTimeout > 0 {
+			peer.Conn.SetReadDeadline(time.Now().Add(pm.IdleTimeout))
+		}
+		data, err := reader.Next()
+		if err == ErrMessageTooLarge {
+			log.Printf("Client %s sent a message over %d bytes, skipping it", addr, pm.MaxMessageBytes)
+			continue
+		}
 		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Printf("Client %s silent for %s", addr, pm.IdleTimeout)
+			}
 			break
 		}
-		
-		log.Printf("[HOST NETWORK] Received %d bytes from client %s", n, addr)
-		buffer = append(buffer, tempBuf[:n]...)
-		
-		// Process complete messages (delimited by newlines)
-		for {
-			newlineIdx := -1
-			for i, b := range buffer {
-				if b == '\n' {
-					newlineIdx = i
-					break
-				}
-			}
-			
-			if newlineIdx == -1 {
-				break // No complete message yet
-			}
-			
-			messageBytes := buffer[:newlineIdx]
-			buffer = buffer[newlineIdx+1:]
-			
-			if len(messageBytes) > 0 {
-				msg := Message{
-					Data:     messageBytes,
-					ClientID: addr,
-				}
-				
-				select {
-				case pm.Messages <- msg:
-				default:
-					log.Println("Message channel full, dropping message")
-				}
-			}
+		if len(data) == 0 {
+			continue
+		}
+
+		// The reader reuses its buffer, so the message gets a copy
+		msg := Message{
+			Data:     append([]byte(nil), data...),
+			ClientID: addr,
+		}
+		select {
+		case pm.Messages <- msg:
+		default:
+			log.Println("Message channel full, dropping message")
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,279 @@
+// Package spatial indexes bounding boxes by ID for fast "what is here"
+// queries: hit-testing, culling and region overlap checks.
+package spatial
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCellSize suits strokes on a board, in board units
+const DefaultCellSize float32 = 256
+
+// maxCellsPerItem bounds how many cells an item is filed under; bigger items
+// go to a list that every query scans.
+const maxCellsPerItem = 256
+
+// Point is a position in board units
+type Point struct {
+	X, Y float32
+}
+
+// Rect is an axis-aligned box
+type Rect struct {
+	MinX, MinY, MaxX, MaxY float32
+}
+
+// RectAround returns the box of a point grown by radius in every direction
+func RectAround(p Point, radius float32) Rect {
+	return Rect{p.X - radius, p.Y - radius, p.X + radius, p.Y + radius}
+}
+
+// BoundsOf returns the box around points, grown by pad. ok is false if
+// there are no points.
+func BoundsOf(points []Point, pad float32) (r Rect, ok bool) {
+	if len(points) == 0 {
+		return Rect{}, false
+	}
+	r = Rect{points[0].X, points[0].Y, points[0].X, points[0].Y}
+	for _, p := range points[1:] {
+		r.MinX, r.MinY = min(r.MinX, p.X), min(r.MinY, p.Y)
+		r.MaxX, r.MaxY = max(r.MaxX, p.X), max(r.MaxY, p.Y)
+	}
+	return r.Grow(pad), true
+}
+
+// Grow returns the box grown by d in every direction
+func (r Rect) Grow(d float32) Rect {
+	return Rect{r.MinX - d, r.MinY - d, r.MaxX + d, r.MaxY + d}
+}
+
+// Union returns the smallest box containing both
+func (r Rect) Union(o Rect) Rect {
+	return Rect{min(r.MinX, o.MinX), min(r.MinY, o.MinY), max(r.MaxX, o.MaxX), max(r.MaxY, o.MaxY)}
+}
+
+// Intersects reports whether the boxes overlap, touching edges included
+func (r Rect) Intersects(o Rect) bool {
+	return r.MinX <= o.MaxX && o.MinX <= r.MaxX && r.MinY <= o.MaxY && o.MinY <= r.MaxY
+}
+
+// Contains reports whether p lies in the box, edges included
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.MinX && p.X <= r.MaxX && p.Y >= r.MinY && p.Y <= r.MaxY
+}
+
+// Encloses reports whether o lies entirely in the box
+func (r Rect) Encloses(o Rect) bool {
+	return o.MinX >= r.MinX && o.MaxX <= r.MaxX && o.MinY >= r.MinY && o.MaxY <= r.MaxY
+}
+
+// DistanceToSegment returns the distance between the box and the segment a-b,
+// 0 if they touch.
+func (r Rect) DistanceToSegment(a, b Point) float32 {
+	if r.Contains(a) || r.Contains(b) {
+		return 0
+	}
+	corners := [4]Point{{r.MinX, r.MinY}, {r.MaxX, r.MinY}, {r.MaxX, r.MaxY}, {r.MinX, r.MaxY}}
+	best := float32(math.MaxFloat32)
+	for i := range corners {
+		c, d := corners[i], corners[(i+1)%4]
+		if segmentsCross(a, b, c, d) {
+			return 0
+		}
+		best = min(best, DistanceToSegment(c, a, b))
+	}
+	// The segment's ends may be closest to an edge rather than a corner
+	for _, p := range []Point{a, b} {
+		dx := max(r.MinX-p.X, 0, p.X-r.MaxX)
+		dy := max(r.MinY-p.Y, 0, p.Y-r.MaxY)
+		best = min(best, float32(math.Hypot(float64(dx), float64(dy))))
+	}
+	return best
+}
+
+// DistanceToSegment returns the distance from p to the segment a-b
+func DistanceToSegment(p, a, b Point) float32 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSq := dx*dx + dy*dy
+	t := float32(0)
+	if lengthSq > 0 {
+		t = max(0, min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSq))
+	}
+	return float32(math.Hypot(float64(p.X-(a.X+t*dx)), float64(p.Y-(a.Y+t*dy))))
+}
+
+func segmentsCross(a, b, c, d Point) bool {
+	cross := func(o, p, q Point) float32 {
+		return (p.X-o.X)*(q.Y-o.Y) - (p.Y-o.Y)*(q.X-o.X)
+	}
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+type cell struct {
+	x, y int32
+}
+
+// Index is a uniform grid of bounding boxes keyed by ID. Items are filed
+// under every cell their box overlaps, so queries only look at the cells
+// they cover. Queries return candidates whose boxes match; callers check the
+// exact geometry. An Index is not safe for concurrent use.
+type Index struct {
+	cellSize float32
+	items    map[string]Rect
+	cells    map[cell]map[string]struct{}
+	large    map[string]struct{} // Items spanning more than maxCellsPerItem cells
+}
+
+// NewIndex creates an empty index with the given cell size
+func NewIndex(cellSize float32) *Index {
+	if cellSize <= 0 {
+		cellSize = DefaultCellSize
+	}
+	return &Index{
+		cellSize: cellSize,
+		items:    make(map[string]Rect),
+		cells:    make(map[cell]map[string]struct{}),
+		large:    make(map[string]struct{}),
+	}
+}
+
+// Len returns the number of items
+func (ix *Index) Len() int {
+	return len(ix.items)
+}
+
+// Bounds returns the box of an item
+func (ix *Index) Bounds(id string) (Rect, bool) {
+	r, ok := ix.items[id]
+	return r, ok
+}
+
+func (ix *Index) cellRange(r Rect) (lo, hi cell) {
+	lo = cell{int32(math.Floor(float64(r.MinX / ix.cellSize))), int32(math.Floor(float64(r.MinY / ix.cellSize)))}
+	hi = cell{int32(math.Floor(float64(r.MaxX / ix.cellSize))), int32(math.Floor(float64(r.MaxY / ix.cellSize)))}
+	return lo, hi
+}
+
+func cellCount(lo, hi cell) int64 {
+	return (int64(hi.x) - int64(lo.x) + 1) * (int64(hi.y) - int64(lo.y) + 1)
+}
+
+// Insert adds an item, replacing any item with the same ID
+func (ix *Index) Insert(id string, r Rect) {
+	ix.Remove(id)
+	ix.items[id] = r
+	lo, hi := ix.cellRange(r)
+	if cellCount(lo, hi) > maxCellsPerItem {
+		ix.large[id] = struct{}{}
+		return
+	}
+	for x := lo.x; x <= hi.x; x++ {
+		for y := lo.y; y <= hi.y; y++ {
+			c := cell{x, y}
+			ids := ix.cells[c]
+			if ids == nil {
+				ids = make(map[string]struct{})
+				ix.cells[c] = ids
+			}
+			ids[id] = struct{}{}
+		}
+	}
+}
+
+// Remove deletes an item. It returns false if there was none.
+func (ix *Index) Remove(id string) bool {
+	r, ok := ix.items[id]
+	if !ok {
+		return false
+	}
+	delete(ix.items, id)
+	if _, isLarge := ix.large[id]; isLarge {
+		delete(ix.large, id)
+		return true
+	}
+	lo, hi := ix.cellRange(r)
+	for x := lo.x; x <= hi.x; x++ {
+		for y := lo.y; y <= hi.y; y++ {
+			c := cell{x, y}
+			delete(ix.cells[c], id)
+			if len(ix.cells[c]) == 0 {
+				delete(ix.cells, c)
+			}
+		}
+	}
+	return true
+}
+
+// Clear removes every item
+func (ix *Index) Clear() {
+	clear(ix.items)
+	clear(ix.cells)
+	clear(ix.large)
+}
+
+// search calls match for every item whose cells overlap area, once each, and
+// returns the IDs it accepted in sorted order.
+func (ix *Index) search(area Rect, match func(Rect) bool) []string {
+	var found []string
+	seen := make(map[string]struct{})
+	check := func(id string) {
+		if _, done := seen[id]; done {
+			return
+		}
+		seen[id] = struct{}{}
+		if match(ix.items[id]) {
+			found = append(found, id)
+		}
+	}
+
+	lo, hi := ix.cellRange(area)
+	if cellCount(lo, hi) > int64(len(ix.cells)) {
+		// Scanning the occupied cells is cheaper than visiting empty ones
+		for c, ids := range ix.cells {
+			if c.x >= lo.x && c.x <= hi.x && c.y >= lo.y && c.y <= hi.y {
+				for id := range ids {
+					check(id)
+				}
+			}
+		}
+	} else {
+		for x := lo.x; x <= hi.x; x++ {
+			for y := lo.y; y <= hi.y; y++ {
+				for id := range ix.cells[cell{x, y}] {
+					check(id)
+				}
+			}
+		}
+	}
+	for id := range ix.large {
+		check(id)
+	}
+	sort.Strings(found)
+	return found
+}
+
+// QueryRect returns the items whose boxes intersect r
+func (ix *Index) QueryRect(r Rect) []string {
+	return ix.search(r, r.Intersects)
+}
+
+// QueryPoint returns the items whose boxes lie within radius of p
+func (ix *Index) QueryPoint(p Point, radius float32) []string {
+	area := RectAround(p, radius)
+	return ix.search(area, func(box Rect) bool {
+		return box.DistanceToSegment(p, p) <= radius
+	})
+}
+
+// QuerySegment returns the items whose boxes lie within radius of the
+// segment a-b
+func (ix *Index) QuerySegment(a, b Point, radius float32) []string {
+	area := Rect{min(a.X, b.X), min(a.Y, b.Y), max(a.X, b.X), max(a.Y, b.Y)}.Grow(radius)
+	return ix.search(area, func(box Rect) bool {
+		return box.DistanceToSegment(a, b) <= radius
+	})
+}
@@ -0,0 +1,116 @@
+package spatial
+
+import (
+	"slices"
+	"testing"
+)
+
+// testItems are filed in every index the tests query: small boxes in one or
+// a few cells, one spanning negative cells, and one too large to file by cell
+var testItems = map[string]Rect{
+	"a":     {0, 0, 10, 10},
+	"b":     {300, 300, 320, 320},
+	"c":     {200, 200, 600, 260}, // Spans several cells
+	"neg":   {-50, -50, -40, -40},
+	"large": {-100000, -100000, 100000, 100000},
+}
+
+func newTestIndex() *Index {
+	ix := NewIndex(DefaultCellSize)
+	for id, r := range testItems {
+		ix.Insert(id, r)
+	}
+	return ix
+}
+
+func TestIndexQueryRect(t *testing.T) {
+	tests := []struct {
+		name string
+		area Rect
+		want []string
+	}{
+		{"first cell", Rect{0, 0, 5, 5}, []string{"a", "large"}},
+		{"touching edge", Rect{10, 10, 20, 20}, []string{"a", "large"}},
+		{"across cells", Rect{250, 250, 310, 310}, []string{"b", "c", "large"}},
+		{"negative", Rect{-60, -60, -45, -45}, []string{"large", "neg"}},
+		{"only large", Rect{5000, 5000, 5100, 5100}, []string{"large"}},
+		{"everything", Rect{-1e6, -1e6, 1e6, 1e6}, []string{"a", "b", "c", "large", "neg"}},
+	}
+	ix := newTestIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.QueryRect(tt.area); !slices.Equal(got, tt.want) {
+				t.Errorf("QueryRect(%v) = %v, want %v", tt.area, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexQueryPointAndSegment(t *testing.T) {
+	ix := newTestIndex()
+	ix.Remove("large")
+	tests := []struct {
+		name   string
+		a, b   Point
+		radius float32
+		want   []string
+	}{
+		{"inside", Point{5, 5}, Point{5, 5}, 0, []string{"a"}},
+		{"within radius", Point{15, 5}, Point{15, 5}, 5, []string{"a"}},
+		{"out of radius", Point{15, 5}, Point{15, 5}, 4, nil},
+		{"segment across two boxes", Point{-45, -45}, Point{5, 5}, 1, []string{"a", "neg"}},
+		{"segment passing by", Point{100, 0}, Point{100, 1000}, 50, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			if tt.a == tt.b {
+				got = ix.QueryPoint(tt.a, tt.radius)
+			} else {
+				got = ix.QuerySegment(tt.a, tt.b, tt.radius)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("query %v-%v within %v = %v, want %v", tt.a, tt.b, tt.radius, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexInsertRemove(t *testing.T) {
+	ix := newTestIndex()
+	everywhere := Rect{-1e6, -1e6, 1e6, 1e6}
+
+	ix.Insert("a", Rect{1000, 1000, 1010, 1010}) // Moves a
+	if got := ix.QueryRect(Rect{0, 0, 5, 5}); !slices.Equal(got, []string{"large"}) {
+		t.Errorf("after moving a, its old place holds %v", got)
+	}
+	if got := ix.QueryRect(Rect{1000, 1000, 1001, 1001}); !slices.Equal(got, []string{"a", "large"}) {
+		t.Errorf("after moving a, its new place holds %v", got)
+	}
+	if ix.Len() != len(testItems) {
+		t.Errorf("Len() = %d after moving an item, want %d", ix.Len(), len(testItems))
+	}
+
+	for _, id := range []string{"a", "c", "large"} {
+		if !ix.Remove(id) {
+			t.Errorf("Remove(%q) = false, want true", id)
+		}
+		if ix.Remove(id) {
+			t.Errorf("second Remove(%q) = true, want false", id)
+		}
+		if _, ok := ix.Bounds(id); ok {
+			t.Errorf("Bounds(%q) found a removed item", id)
+		}
+	}
+	if got := ix.QueryRect(everywhere); !slices.Equal(got, []string{"b", "neg"}) {
+		t.Errorf("after removing, the index holds %v", got)
+	}
+	if len(ix.cells) != 2 {
+		t.Errorf("after removing, %d cells are left, want 2", len(ix.cells))
+	}
+
+	ix.Clear()
+	if got := ix.QueryRect(everywhere); len(got) != 0 || ix.Len() != 0 {
+		t.Errorf("after Clear, the index holds %v", got)
+	}
+}
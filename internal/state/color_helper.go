@@ -1,9 +1,52 @@
 package state
 
-import "image/color"
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
 
-// This is a helper file for color conversions or definitions if needed.
+// legacyColors are the named colours older boards were saved with
+var legacyColors = map[string]color.NRGBA{
+	"black": {A: 255},
+	"red":   {R: 255, A: 255},
+	"blue":  {B: 255, A: 255},
+	"green": {G: 255, A: 255},
+}
+
+// ParseColor reads a path colour: "#RRGGBBAA", "#RRGGBB" (opaque) or one of
+// the legacy names black, red, blue and green.
+func ParseColor(s string) (color.NRGBA, error) {
+	if c, ok := legacyColors[s]; ok {
+		return c, nil
+	}
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(hex) != 6 && len(hex) != 8) {
+		return color.NRGBA{}, fmt.Errorf("colour %q is not #RRGGBB or #RRGGBBAA", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("colour %q is not valid hex", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// HexToColor converts a path colour to a color.Color, falling back to black
+// for colours it cannot read.
 func HexToColor(hex string) color.Color {
-	// TODO: Implement hex to color conversion
-	return color.Black
-}
\ No newline at end of file
+	c, err := ParseColor(hex)
+	if err != nil {
+		return color.Black
+	}
+	return c
+}
+
+// ColorToHex formats any colour as "#RRGGBBAA", without premultiplied alpha
+func ColorToHex(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
@@ -0,0 +1,50 @@
+package state
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{in: "#ff000080", want: color.NRGBA{R: 255, A: 128}},
+		{in: "#1E88E5FF", want: color.NRGBA{R: 0x1e, G: 0x88, B: 0xe5, A: 255}},
+		{in: "#00ff00", want: color.NRGBA{G: 255, A: 255}},
+		{in: "#00000000", want: color.NRGBA{}},
+		{in: "black", want: color.NRGBA{A: 255}},
+		{in: "blue", want: color.NRGBA{B: 255, A: 255}},
+		{in: "", wantErr: true},
+		{in: "ff0000", wantErr: true},
+		{in: "#f00", wantErr: true},
+		{in: "#ff00000", wantErr: true},
+		{in: "#gg0000", wantErr: true},
+		{in: "#+f0000", wantErr: true},
+		{in: "purple", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseColor(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseColor(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestColorToHexRoundTrip(t *testing.T) {
+	for _, hex := range []string{"#000000ff", "#1e88e5ff", "#ff000080", "#00000000"} {
+		c, err := ParseColor(hex)
+		if err != nil {
+			t.Fatalf("ParseColor(%q): %v", hex, err)
+		}
+		if got := ColorToHex(c); got != hex {
+			t.Errorf("ColorToHex(ParseColor(%q)) = %q", hex, got)
+		}
+	}
+}
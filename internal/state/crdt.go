@@ -4,18 +4,106 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/stroke"
 )
 
 // Path represents a drawing path
 type Path struct {
-	ID     string          `json:"id"`
-	Points []fyne.Position `json:"points"`
-	Color  string          `json:"color"`
-	Stroke float32         `json:"stroke"`
+	ID      string          `json:"id"`
+	OwnerID string          `json:"owner_id"`
+	Points  []fyne.Position `json:"points"`
+	Color   string          `json:"color"`
+	Stroke  float32         `json:"stroke"`
+	Bezier  bool            `json:"bezier,omitempty"` // Points are cubic Bézier segments, see stroke.FitBeziers
+	// Widths optionally gives the width at each point, or at each curve end
+	// point of a Bézier path; Stroke is used throughout if it is empty
+	Widths []float32 `json:"widths,omitempty"`
+	// Shape, if set, makes the path a rectangle, ellipse, line or arrow
+	Shape *Shape `json:"shape,omitempty"`
+	// Text, if set, makes the path a text box or sticky note
+	Text *Text `json:"text,omitempty"`
+	// Image, if set, makes the path a picture
+	Image *Image `json:"image,omitempty"`
+	// Transform, if set, places the path somewhere other than its points say
+	Transform *Transform `json:"transform,omitempty"`
+	// Layer is the layer the path is on; see LayerID
+	Layer string `json:"layer,omitempty"`
+	// Z is how high the path is stacked on its layer; see Below
+	Z float64 `json:"z,omitempty"`
+}
+
+// Polyline returns the points the path is drawn through, with Bézier
+// segments flattened into lines, shapes turned into their outlines, text
+// and image elements into their boxes and the transform applied
+func (p Path) Polyline() []fyne.Position {
+	if p.Transform != nil {
+		return p.Transform.applyAll(p.untransformed())
+	}
+	return p.untransformed()
+}
+
+func (p Path) untransformed() []fyne.Position {
+	if (p.Text != nil || p.Image != nil) && len(p.Points) == 2 {
+		return closeLoop(roundedRect(p.Points[0], p.Points[1], 0))
+	}
+	if p.Shape != nil {
+		return p.shapeOutline()
+	}
+	if p.Bezier {
+		return stroke.Flatten(p.Points)
+	}
+	return p.Points
+}
+
+// PolylineWidths returns Polyline with the width at each of its points
+func (p Path) PolylineWidths() ([]fyne.Position, []float32) {
+	points := p.Polyline()
+	widths := p.Widths
+	if p.Bezier {
+		widths = stroke.FlattenWidths(p.Points, widths)
+	}
+	if len(widths) != len(points) {
+		widths = make([]float32, len(points))
+		for i := range widths {
+			widths[i] = p.Stroke
+		}
+	}
+	if p.Transform != nil {
+		scaled := make([]float32, len(widths))
+		for i, w := range widths {
+			scaled[i] = w * p.Transform.Scale()
+		}
+		widths = scaled
+	}
+	return points, widths
+}
+
+// MaxWidth returns the widest the path gets
+func (p Path) MaxWidth() float32 {
+	w := p.Stroke
+	if len(p.Widths) > 0 {
+		w = slices.Max(p.Widths)
+	}
+	if p.Transform != nil {
+		w *= p.Transform.Scale()
+	}
+	return w
+}
+
+// widthPoints returns how many entries Widths must have
+func (p Path) widthPoints() int {
+	if p.Bezier {
+		return (len(p.Points)-1)/3 + 1
+	}
+	return len(p.Points)
 }
 
 // Clock represents a logical clock for CRDT operations
@@ -32,6 +120,13 @@ func (c *Clock) Tick() int64 {
 	return c.counter
 }
 
+// Now returns the last value the clock gave or saw
+func (c *Clock) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counter
+}
+
 // Update updates the clock based on a received timestamp
 func (c *Clock) Update(timestamp int64) {
 	c.mu.Lock()
@@ -41,21 +136,97 @@ func (c *Clock) Update(timestamp int64) {
 	}
 }
 
+// Operation types
+const (
+	OpAdd    = "add"    // adds Path to the board, or brings it back if added again later
+	OpClear  = "clear"  // hides every path of OwnerID ("all" for everyone) added before it
+	OpDelete = "delete" // hides the path Target if it was added before
+	// OpTransform places the path Target with Transform; the newest transform
+	// of a path wins. Transforms are kept apart from the points, so moving a
+	// stroke sends six numbers rather than every point.
+	OpTransform = "transform"
+	// OpLayer creates or changes the layer Layer; the newest version of a
+	// layer wins
+	OpLayer = "layer"
+	// OpSetLayer puts the path Target on the layer LayerID; the newest move
+	// of a path wins
+	OpSetLayer = "set_layer"
+	// OpRestack stacks the path Target at height Z; the newest restack of a
+	// path wins
+	OpRestack = "restack"
+	// OpFrame creates, changes or deletes the frame Frame; the newest version
+	// of a frame wins
+	OpFrame = "frame"
+)
+
 // PathOperation represents a CRDT operation for a drawing path
 type PathOperation struct {
-	ID        string    `json:"id"`
-	SiteID    string    `json:"site_id"`
-	Timestamp int64     `json:"timestamp"`
-	Path      Path      `json:"path"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string     `json:"id"`
+	SiteID    string     `json:"site_id"`
+	Seq       int64      `json:"seq"`       // Per-site sequence number, starting at 1
+	Timestamp int64      `json:"timestamp"` // Lamport time, used to order concurrent operations
+	Type      string     `json:"type"`
+	Path      *Path      `json:"path,omitempty"`
+	OwnerID   string     `json:"owner_id,omitempty"`
+	Target    string     `json:"target,omitempty"` // Path ID a delete or transform applies to
+	Transform *Transform `json:"transform,omitempty"`
+	Layer     *Layer     `json:"layer,omitempty"`
+	Frame     *Frame     `json:"frame,omitempty"`
+	LayerID   string     `json:"layer_id,omitempty"` // Layer a set_layer puts Target on
+	Z         float64    `json:"z,omitempty"`        // Height a restack puts Target at
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Change describes how applying an operation altered the visible board.
+type Change struct {
+	Added   []Path
+	Removed []string
+	Layers  []Layer // Every layer, bottom first, if they changed
+	Frames  []Frame // Every frame, in page order, if they changed
+}
+
+// stamp totally orders operations: by Lamport time, then by site ID.
+type stamp struct {
+	ts   int64
+	site string
+}
+
+func (s stamp) after(o stamp) bool {
+	if s.ts != o.ts {
+		return s.ts > o.ts
+	}
+	return s.site > o.site
+}
+
+func opStamp(op PathOperation) stamp {
+	return stamp{ts: op.Timestamp, site: op.SiteID}
 }
 
 // WhiteboardState is our CRDT data structure.
 type WhiteboardState struct {
-	siteID     string                    // A unique ID for this user's session
-	clock      Clock                     // This user's logical clock
-	paths      map[string]Path           // The actual set of paths, indexed by their unique ID
-	operations map[string]PathOperation  // All operations we've seen
+	siteID     string                   // A unique ID for this user's session
+	clock      Clock                    // This user's logical clock
+	seq        int64                    // Last sequence number used for a local operation
+	paths      map[string]Path          // The actual set of paths, indexed by their unique ID
+	added      map[string]stamp         // When each path was added
+	order      []string                 // Path IDs in arrival order
+	position   map[string]int           // Index of each path ID in order
+	index      *spatial.Index           // Bounding boxes of the visible paths
+	clears     map[string]stamp         // Latest clear per owner ("all" for everyone)
+	deleted    map[string]stamp         // Latest delete per path
+	transforms map[string]Transform     // Latest transform per path
+	placed     map[string]stamp         // When each path got its transform
+	layers     map[string]Layer         // Latest version of each layer
+	layerTimes map[string]stamp         // When each layer got its latest version
+	layerOf    map[string]string        // Layer each path was last put on
+	layered    map[string]stamp         // When each path was put on its layer
+	heights    map[string]float64       // Latest stacking height per path
+	stacked    map[string]stamp         // When each path got its height
+	frames     map[string]Frame         // Latest version of each frame
+	frameTimes map[string]stamp         // When each frame got its latest version
+	history    history                  // Undo and redo stacks of the local user
+	operations map[string]PathOperation // All operations we've seen
+	vector     StateVector              // Contiguous sequence numbers seen per site
 	mu         sync.RWMutex
 }
 
@@ -68,83 +239,330 @@ func NewWhiteboardState() *WhiteboardState {
 	return &WhiteboardState{
 		siteID:     siteID,
 		paths:      make(map[string]Path),
+		added:      make(map[string]stamp),
+		position:   make(map[string]int),
+		index:      spatial.NewIndex(spatial.DefaultCellSize),
+		clears:     make(map[string]stamp),
+		deleted:    make(map[string]stamp),
+		transforms: make(map[string]Transform),
+		placed:     make(map[string]stamp),
+		layers:     make(map[string]Layer),
+		layerTimes: make(map[string]stamp),
+		layerOf:    make(map[string]string),
+		layered:    make(map[string]stamp),
+		heights:    make(map[string]float64),
+		stacked:    make(map[string]stamp),
+		frames:     make(map[string]Frame),
+		frameTimes: make(map[string]stamp),
 		operations: make(map[string]PathOperation),
+		vector:     make(StateVector),
+	}
+}
+
+func operationID(siteID string, seq int64) string {
+	return fmt.Sprintf("%s:%d", siteID, seq)
+}
+
+// newLocalOperation stamps an operation with our site ID, sequence number and clock.
+// Callers must hold ws.mu.
+func (ws *WhiteboardState) newLocalOperation(opType string) PathOperation {
+	ws.seq++
+	return PathOperation{
+		ID:        operationID(ws.siteID, ws.seq),
+		SiteID:    ws.siteID,
+		Seq:       ws.seq,
+		Timestamp: ws.clock.Tick(),
+		Type:      opType,
+		CreatedAt: time.Now(),
 	}
 }
 
 // AddLocalPath takes a path drawn by the local user, assigns it a unique ID,
-// adds it to the state, and returns the full path to be broadcast.
-func (ws *WhiteboardState) AddLocalPath(p Path) Path {
+// adds it to the state, and returns the operation to be broadcast.
+func (ws *WhiteboardState) AddLocalPath(p Path) PathOperation {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
+	op := ws.newLocalOperation(OpAdd)
 	// Generate a unique ID using our site ID and logical clock.
-	timestamp := ws.clock.Tick()
-	p.ID = fmt.Sprintf("path-%s-%d", ws.siteID, timestamp)
-	
-	// Create operation
-	op := PathOperation{
-		ID:        p.ID,
-		SiteID:    ws.siteID,
-		Timestamp: timestamp,
-		Path:      p,
-		CreatedAt: time.Now(),
-	}
-	
-	ws.paths[p.ID] = p
-	ws.operations[p.ID] = op
-	
+	p.ID = fmt.Sprintf("path-%s-%d", ws.siteID, op.Timestamp)
+	p.Z = float64(op.Timestamp)
+	op.Path = &p
+	ws.applyLocked(op)
+	ws.history.record(historyEntry{
+		undo: []edit{{typ: OpDelete, target: p.ID, owner: p.OwnerID}},
+		redo: []edit{{typ: OpAdd, path: p}},
+	})
+
 	log.Printf("[CRDT] Local path added: %s", p.ID)
-	return p
+	return op
 }
 
-// AddRemotePath takes a path received from the network, merges it into our state,
-// and returns true if this was a new path that should be drawn.
-func (ws *WhiteboardState) AddRemotePath(p Path) bool {
+// ClearLocal deletes every visible path owned by ownerID, except those on
+// locked layers, and returns the operations to be broadcast.
+func (ws *WhiteboardState) ClearLocal(ownerID string) ([]PathOperation, Change) {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 
-	// Check if we already have this path.
-	if _, exists := ws.paths[p.ID]; exists {
-		log.Printf("[CRDT] Path %s already exists, ignoring", p.ID)
-		return false // It's a duplicate, do nothing.
-	}
-
-	// Extract timestamp from ID for clock synchronization
-	// Format: "path-siteID-timestamp"
-	var remoteSiteID string
-	var remoteTimestamp int64
-	fmt.Sscanf(p.ID, "path-%s-%d", &remoteSiteID, &remoteTimestamp)
-	
-	// Update our logical clock
-	ws.clock.Update(remoteTimestamp)
-	
-	// Create operation record
-	op := PathOperation{
-		ID:        p.ID,
-		SiteID:    remoteSiteID,
-		Timestamp: remoteTimestamp,
-		Path:      p,
-		CreatedAt: time.Now(),
+	// Undo brings back exactly the paths this clear hid
+	entry := historyEntry{}
+	for _, id := range ws.order {
+		p := ws.paths[id]
+		if p.OwnerID != ownerID || !ws.visibleLocked(id) {
+			continue
+		}
+		if l, ok := ws.layerLocked(p.LayerID()); ok && l.Locked {
+			continue
+		}
+		entry.undo = append(entry.undo, edit{typ: OpAdd, path: p})
+		entry.redo = append(entry.redo, edit{typ: OpDelete, target: id, owner: ownerID})
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+
+	log.Printf("[CRDT] Local clear for owner: %s", ownerID)
+	return ops, change
+}
+
+// DeleteLocal hides the given paths on behalf of ownerID and returns the
+// operations to be broadcast.
+func (ws *WhiteboardState) DeleteLocal(ownerID string, pathIDs []string) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{}
+	for _, id := range pathIDs {
+		if ws.visibleLocked(id) {
+			entry.undo = append(entry.undo, edit{typ: OpAdd, path: ws.paths[id]})
+			entry.redo = append(entry.redo, edit{typ: OpDelete, target: id, owner: ownerID})
+		}
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	return ops, change
+}
+
+// ImportPaths replaces the board with paths, layers and frames loaded from a
+// file, keeping their IDs. It returns the operations to be broadcast.
+func (ws *WhiteboardState) ImportPaths(paths []Path, layers []Layer, frames []Frame) []PathOperation {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ops := make([]PathOperation, 0, len(layers)+len(frames)+len(paths)+1)
+	for _, l := range layers {
+		layer := l
+		op := ws.newLocalOperation(OpLayer)
+		op.OwnerID = "all"
+		op.Layer = &layer
+		ws.applyLocked(op)
+		ops = append(ops, op)
+	}
+	// Pages of the board that are not in the file go
+	frames = slices.Clone(frames)
+	loaded := make(map[string]bool, len(frames))
+	for _, f := range frames {
+		loaded[f.ID] = true
+	}
+	for _, f := range ws.framesLocked() {
+		if !loaded[f.ID] {
+			f.Deleted = true
+			frames = append(frames, f)
+		}
+	}
+	for _, f := range frames {
+		frame := f
+		op := ws.newLocalOperation(OpFrame)
+		op.OwnerID = "all"
+		op.Frame = &frame
+		ws.applyLocked(op)
+		ops = append(ops, op)
+	}
+
+	clear := ws.newLocalOperation(OpClear)
+	clear.OwnerID = "all"
+	ws.applyLocked(clear)
+	ops = append(ops, clear)
+
+	// Restacked from the bottom up, so paths drawn later go above them
+	paths = slices.Clone(paths)
+	sort.SliceStable(paths, func(i, j int) bool { return paths[i].Z < paths[j].Z })
+	for _, p := range paths {
+		path := p
+		op := ws.newLocalOperation(OpAdd)
+		path.Z = float64(op.Timestamp)
+		op.Path = &path
+		ws.applyLocked(op)
+		ops = append(ops, op)
+	}
+
+	log.Printf("[CRDT] Imported %d paths", len(paths))
+	return ops
+}
+
+// Apply merges an operation received from the network into our state.
+// It returns false if the operation was already known.
+func (ws *WhiteboardState) Apply(op PathOperation) (Change, bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, exists := ws.operations[op.ID]; exists {
+		return Change{}, false // It's a duplicate, do nothing.
+	}
+	ws.clock.Update(op.Timestamp)
+	return ws.applyLocked(op), true
+}
+
+// applyLocked records op and updates the visible paths. Callers must hold ws.mu.
+func (ws *WhiteboardState) applyLocked(op PathOperation) Change {
+	ws.operations[op.ID] = op
+	ws.advanceVector(op.SiteID)
+
+	var change Change
+	s := opStamp(op)
+	switch op.Type {
+	case OpAdd:
+		if op.Path == nil {
+			break
+		}
+		id := op.Path.ID
+		prev, exists := ws.added[id]
+		if exists && !s.after(prev) {
+			break // A newer add of the same path has already been applied
+		}
+		wasVisible := ws.visibleLocked(id)
+		if t := op.Path.Transform; t != nil {
+			ws.setTransformLocked(id, *t, s)
+		}
+		if op.Path.Layer != "" {
+			ws.setLayerLocked(id, op.Path.Layer, s)
+		}
+		if op.Path.Z != 0 {
+			ws.setHeightLocked(id, op.Path.Z, s)
+		}
+		ws.paths[id] = ws.placedLocked(*op.Path)
+		ws.added[id] = s
+		if !exists {
+			ws.position[id] = len(ws.order)
+			ws.order = append(ws.order, id)
+		}
+		if wasVisible {
+			change.Removed = append(change.Removed, id)
+		}
+		if ws.visibleLocked(id) {
+			change.Added = append(change.Added, ws.paths[id])
+		}
+	case OpClear:
+		visibleBefore := make(map[string]bool)
+		for id, p := range ws.paths {
+			if op.OwnerID == "all" || p.OwnerID == op.OwnerID {
+				visibleBefore[id] = ws.visibleLocked(id)
+			}
+		}
+		if prev, ok := ws.clears[op.OwnerID]; !ok || s.after(prev) {
+			ws.clears[op.OwnerID] = s
+		}
+		for _, id := range ws.order {
+			if visibleBefore[id] && !ws.visibleLocked(id) {
+				change.Removed = append(change.Removed, id)
+			}
+		}
+	case OpDelete:
+		visibleBefore := ws.visibleLocked(op.Target)
+		if prev, ok := ws.deleted[op.Target]; !ok || s.after(prev) {
+			ws.deleted[op.Target] = s
+		}
+		if visibleBefore && !ws.visibleLocked(op.Target) {
+			change.Removed = append(change.Removed, op.Target)
+		}
+	case OpTransform:
+		if op.Transform == nil || !ws.setTransformLocked(op.Target, *op.Transform, s) {
+			break
+		}
+		p, ok := ws.paths[op.Target]
+		if !ok {
+			break // Placed once it is added
+		}
+		ws.paths[op.Target] = ws.placedLocked(p)
+		if ws.visibleLocked(op.Target) {
+			change.Removed = append(change.Removed, op.Target)
+			change.Added = append(change.Added, ws.paths[op.Target])
+		}
+	case OpSetLayer, OpRestack:
+		moved := false
+		if op.Type == OpSetLayer {
+			moved = ws.setLayerLocked(op.Target, op.LayerID, s)
+		} else {
+			moved = ws.setHeightLocked(op.Target, op.Z, s)
+		}
+		if !moved {
+			break
+		}
+		p, ok := ws.paths[op.Target]
+		if !ok {
+			break // Placed once it is added
+		}
+		ws.paths[op.Target] = ws.placedLocked(p)
+		if ws.visibleLocked(op.Target) {
+			change.Removed = append(change.Removed, op.Target)
+			change.Added = append(change.Added, ws.paths[op.Target])
+		}
+	case OpLayer:
+		if op.Layer == nil {
+			break
+		}
+		if prev, ok := ws.layerTimes[op.Layer.ID]; ok && !s.after(prev) {
+			break
+		}
+		ws.layers[op.Layer.ID] = *op.Layer
+		ws.layerTimes[op.Layer.ID] = s
+		change.Layers = ws.layersLocked()
+	case OpFrame:
+		if op.Frame == nil {
+			break
+		}
+		if prev, ok := ws.frameTimes[op.Frame.ID]; ok && !s.after(prev) {
+			break
+		}
+		ws.frames[op.Frame.ID] = *op.Frame
+		ws.frameTimes[op.Frame.ID] = s
+		change.Frames = ws.framesLocked()
+	default:
+		log.Printf("[CRDT] Unknown operation type: %s", op.Type)
 	}
+	ws.indexChangeLocked(change)
+	return change
+}
 
-	// Add to our state
-	ws.paths[p.ID] = p
-	ws.operations[p.ID] = op
-	
-	log.Printf("[CRDT] Remote path added: %s from site %s", p.ID, remoteSiteID)
-	return true // Signal that the UI should be updated.
+// visibleLocked reports whether a path survives every clear and delete. Callers must hold ws.mu.
+func (ws *WhiteboardState) visibleLocked(pathID string) bool {
+	added, ok := ws.added[pathID]
+	if !ok {
+		return false
+	}
+	if c, ok := ws.clears["all"]; ok && c.after(added) {
+		return false
+	}
+	if c, ok := ws.clears[ws.paths[pathID].OwnerID]; ok && c.after(added) {
+		return false
+	}
+	if d, ok := ws.deleted[pathID]; ok && d.after(added) {
+		return false
+	}
+	return true
 }
 
-// GetAllPaths returns all paths in the current state
+// GetAllPaths returns all visible paths, bottom first
 func (ws *WhiteboardState) GetAllPaths() []Path {
 	ws.mu.RLock()
 	defer ws.mu.RUnlock()
-	
-	paths := make([]Path, 0, len(ws.paths))
-	for _, path := range ws.paths {
-		paths = append(paths, path)
+
+	paths := make([]Path, 0, len(ws.order))
+	for _, id := range ws.order {
+		if ws.visibleLocked(id) {
+			paths = append(paths, ws.paths[id])
+		}
 	}
+	SortStacked(paths)
 	return paths
 }
 
@@ -160,37 +578,22 @@ func (ws *WhiteboardState) GetOperationCount() int {
 	return len(ws.operations)
 }
 
-// RemovePath removes a path (for future delete operations)
-func (ws *WhiteboardState) RemovePath(pathID string) bool {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
-	
-	if _, exists := ws.paths[pathID]; exists {
-		delete(ws.paths, pathID)
-		log.Printf("[CRDT] Path removed: %s", pathID)
-		return true
-	}
-	return false
-}
-
 // Merge merges another whiteboard state into this one (for conflict resolution)
 func (ws *WhiteboardState) Merge(other *WhiteboardState) []Path {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
-	
 	newPaths := make([]Path, 0)
-	
-	// Merge all operations from the other state
-	for opID, op := range other.operations {
-		if _, exists := ws.operations[opID]; !exists {
-			// This is a new operation
-			ws.operations[opID] = op
-			ws.paths[op.Path.ID] = op.Path
-			ws.clock.Update(op.Timestamp)
-			newPaths = append(newPaths, op.Path)
-			log.Printf("[CRDT] Merged operation: %s", opID)
-		}
-	}
-	
+	for _, op := range other.MissingOperations(ws.StateVector()) {
+		if change, applied := ws.Apply(op); applied {
+			newPaths = append(newPaths, change.Added...)
+			log.Printf("[CRDT] Merged operation: %s", op.ID)
+		}
+	}
 	return newPaths
-}
\ No newline at end of file
+}
+
+// sortOperations orders operations by Lamport time so that replaying them
+// preserves causality.
+func sortOperations(ops []PathOperation) {
+	sort.Slice(ops, func(i, j int) bool {
+		return opStamp(ops[j]).after(opStamp(ops[i]))
+	})
+}
@@ -0,0 +1,211 @@
+package state
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"fyne.io/fyne/v2"
+)
+
+// newSite returns an empty whiteboard with a fixed site ID
+func newSite(id string) *WhiteboardState {
+	ws := NewWhiteboardState()
+	ws.siteID = id
+	return ws
+}
+
+// drawAt adds a short stroke of owner's at x
+func drawAt(ws *WhiteboardState, owner string, x float32) PathOperation {
+	return ws.AddLocalPath(Path{
+		OwnerID: owner,
+		Points:  []fyne.Position{{X: x, Y: 0}, {X: x + 10, Y: 10}},
+		Color:   "#000000ff",
+		Stroke:  2,
+	})
+}
+
+// clearAll clears owner's paths as ws sees them
+func clearAll(ws *WhiteboardState, owner string) []PathOperation {
+	ops, _ := ws.ClearLocal(owner)
+	return ops
+}
+
+// concurrentEdits returns the operations of two sites drawing and clearing
+// while only partly aware of each other, in the order they were made
+func concurrentEdits() []PathOperation {
+	alice, bob := newSite("alice"), newSite("bob")
+	var ops []PathOperation
+	ops = append(ops, drawAt(alice, "alice", 0), drawAt(alice, "alice", 20), drawAt(bob, "bob", 40))
+	bob.Apply(ops[0]) // Bob has seen only Alice's first stroke when clearing hers
+	ops = append(ops, clearAll(bob, "alice")...)
+	ops = append(ops, drawAt(bob, "bob", 60))
+	ops = append(ops, clearAll(alice, "alice")...)
+	ops = append(ops, drawAt(alice, "alice", 80))
+	return ops
+}
+
+// visible returns what a whiteboard shows, by ID
+func visible(ws *WhiteboardState) []Path {
+	paths := ws.GetAllPaths()
+	sort.Slice(paths, func(i, j int) bool { return paths[i].ID < paths[j].ID })
+	return paths
+}
+
+func applyAll(ops []PathOperation) *WhiteboardState {
+	ws := newSite("observer")
+	for _, op := range ops {
+		ws.Apply(op)
+	}
+	return ws
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	ops := concurrentEdits()
+	want := visible(applyAll(ops))
+
+	ws := newSite("observer")
+	for _, op := range ops {
+		if _, applied := ws.Apply(op); !applied {
+			t.Fatalf("first Apply(%s) was not applied", op.ID)
+		}
+		if change, applied := ws.Apply(op); applied || len(change.Added) > 0 || len(change.Removed) > 0 {
+			t.Errorf("second Apply(%s) = %+v, %v; want no change", op.ID, change, applied)
+		}
+	}
+	if got := visible(ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("applying every operation twice shows %v, want %v", got, want)
+	}
+}
+
+func TestApplyOrderIndependent(t *testing.T) {
+	ops := concurrentEdits()
+	want := visible(applyAll(ops))
+	if len(want) != 3 {
+		t.Fatalf("in order, %d paths are visible, want Bob's two and Alice's last", len(want))
+	}
+
+	reversed := slices.Clone(ops)
+	slices.Reverse(reversed)
+	bySite := slices.Clone(ops)
+	sort.SliceStable(bySite, func(i, j int) bool { return bySite[i].SiteID > bySite[j].SiteID })
+	orders := map[string][]PathOperation{
+		"reversed":     reversed,
+		"bob first":    bySite,
+		"clears first": append(slices.Clone(ops[3:]), ops[:3]...),
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		shuffled := slices.Clone(ops)
+		rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		orders[fmt.Sprintf("shuffled %d", seed)] = shuffled
+	}
+	for name, order := range orders {
+		t.Run(name, func(t *testing.T) {
+			if got := visible(applyAll(order)); !reflect.DeepEqual(got, want) {
+				t.Errorf("shows %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStateVector(t *testing.T) {
+	ops := concurrentEdits()
+	of := func(site string, seqs ...int64) []PathOperation {
+		var picked []PathOperation
+		for _, op := range ops {
+			if op.SiteID == site && slices.Contains(seqs, op.Seq) {
+				picked = append(picked, op)
+			}
+		}
+		return picked
+	}
+	all := StateVector{}
+	for _, op := range ops {
+		all[op.SiteID] = max(all[op.SiteID], op.Seq)
+	}
+
+	tests := []struct {
+		name    string
+		applied []PathOperation
+		want    StateVector
+	}{
+		{"nothing", nil, StateVector{}},
+		{"contiguous", of("alice", 1, 2), StateVector{"alice": 2}},
+		{"out of order", append(of("bob", 2), of("bob", 1)...), StateVector{"bob": 2}},
+		{"gap", of("alice", 1, 3), StateVector{"alice": 1}},
+		{"missing first", of("alice", 2, 3), StateVector{}},
+		{"everything", ops, all},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyAll(tt.applied).StateVector(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StateVector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingOperations(t *testing.T) {
+	ops := concurrentEdits()
+	full := applyAll(ops)
+	count := func(site string) int64 {
+		var n int64
+		for _, op := range ops {
+			if op.SiteID == site {
+				n++
+			}
+		}
+		return n
+	}
+
+	tests := []struct {
+		name string
+		sv   StateVector
+		want int
+	}{
+		{"empty vector", StateVector{}, len(ops)},
+		{"part of alice", StateVector{"alice": 2}, len(ops) - 2},
+		{"all of bob", StateVector{"bob": count("bob")}, int(count("alice"))},
+		{"up to date", StateVector{"alice": count("alice"), "bob": count("bob")}, 0},
+		{"ahead", StateVector{"alice": 100, "bob": 100}, 0},
+		{"unknown site", StateVector{"carol": 5}, len(ops)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing := full.MissingOperations(tt.sv)
+			if len(missing) != tt.want {
+				t.Fatalf("MissingOperations(%v) returned %d operations, want %d", tt.sv, len(missing), tt.want)
+			}
+			for i, op := range missing {
+				if op.Seq <= tt.sv[op.SiteID] {
+					t.Errorf("MissingOperations(%v) returned %s, which the peer has", tt.sv, op.ID)
+				}
+				if i > 0 && opStamp(missing[i-1]).after(opStamp(op)) {
+					t.Errorf("MissingOperations(%v) returned %s before %s", tt.sv, missing[i-1].ID, op.ID)
+				}
+			}
+
+			// Shipping them brings the peer up to date
+			peer := newSite("peer")
+			for _, op := range ops {
+				if op.Seq <= tt.sv[op.SiteID] {
+					peer.Apply(op)
+				}
+			}
+			for _, op := range missing {
+				peer.Apply(op)
+			}
+			if got, want := visible(peer), visible(full); !reflect.DeepEqual(got, want) {
+				t.Errorf("after syncing, the peer shows %v, want %v", got, want)
+			}
+			if got, want := peer.StateVector(), full.StateVector(); !reflect.DeepEqual(got, want) {
+				t.Errorf("after syncing, the peer's vector is %v, want %v", got, want)
+			}
+		})
+	}
+}
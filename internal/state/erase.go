@@ -0,0 +1,256 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"fyne.io/fyne/v2"
+)
+
+func distance(a, b fyne.Position) float32 {
+	return float32(math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)))
+}
+
+// distanceToSegment returns the distance from p to the segment a-b
+func distanceToSegment(p, a, b fyne.Position) float32 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return distance(p, a)
+	}
+	t := ((p.X-a.X)*dx + (p.Y-a.Y)*dy) / lengthSq
+	t = float32(math.Max(0, math.Min(1, float64(t))))
+	return distance(p, fyne.NewPos(a.X+t*dx, a.Y+t*dy))
+}
+
+// segmentsIntersect reports whether the segments a-b and c-d cross
+func segmentsIntersect(a, b, c, d fyne.Position) bool {
+	cross := func(o, p, q fyne.Position) float32 {
+		return (p.X-o.X)*(q.Y-o.Y) - (p.Y-o.Y)*(q.X-o.X)
+	}
+	d1, d2 := cross(c, d, a), cross(c, d, b)
+	d3, d4 := cross(a, b, c), cross(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// segmentDistance returns the distance between the segments a-b and c-d
+func segmentDistance(a, b, c, d fyne.Position) float32 {
+	if segmentsIntersect(a, b, c, d) {
+		return 0
+	}
+	return min(distanceToSegment(a, c, d), distanceToSegment(b, c, d),
+		distanceToSegment(c, a, b), distanceToSegment(d, a, b))
+}
+
+// segments returns the segments of a polyline; a single point is a segment
+// of length zero.
+func segments(points []fyne.Position) [][2]fyne.Position {
+	if len(points) == 1 {
+		return [][2]fyne.Position{{points[0], points[0]}}
+	}
+	segs := make([][2]fyne.Position, 0, len(points))
+	for i := 0; i+1 < len(points); i++ {
+		segs = append(segs, [2]fyne.Position{points[i], points[i+1]})
+	}
+	return segs
+}
+
+// distanceToTrail returns the distance from p to the closest segment of trail
+func distanceToTrail(p fyne.Position, trail []fyne.Position) float32 {
+	best := float32(math.MaxFloat32)
+	for _, s := range segments(trail) {
+		best = min(best, distanceToSegment(p, s[0], s[1]))
+	}
+	return best
+}
+
+// InsidePolygon reports whether p lies inside polygon, by the even-odd rule.
+// The polygon is closed between its last and first point.
+func InsidePolygon(p fyne.Position, polygon []fyne.Position) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, c := polygon[i], polygon[j]
+		if (a.Y > p.Y) != (c.Y > p.Y) && p.X < (c.X-a.X)*(p.Y-a.Y)/(c.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PathTouches reports whether the eraser, dragged along trail with the given
+// radius, touches any part of the stroke p. Text and image elements are
+// touched anywhere inside their box.
+func PathTouches(p Path, trail []fyne.Position, radius float32) bool {
+	points := p.Polyline()
+	if len(points) == 0 || len(trail) == 0 {
+		return false
+	}
+	if p.Text != nil {
+		for _, pt := range trail {
+			if p.textContains(pt, radius) {
+				return true
+			}
+		}
+	}
+	if p.Image != nil {
+		for _, pt := range trail {
+			if InsidePolygon(pt, points) {
+				return true
+			}
+		}
+	}
+	reach := radius + p.MaxWidth()/2
+	for _, ps := range segments(points) {
+		for _, ts := range segments(trail) {
+			if segmentDistance(ps[0], ps[1], ts[0], ts[1]) <= reach {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxEraseSamples bounds how many points along a path are tested against the
+// eraser, however long the path is
+const maxEraseSamples = 100000
+
+// polylineLength returns the length of a polyline
+func polylineLength(points []fyne.Position) float32 {
+	var length float32
+	for i := 1; i < len(points); i++ {
+		length += distance(points[i-1], points[i])
+	}
+	return length
+}
+
+// densify inserts points so that no two neighbours are more than step apart,
+// interpolating the widths along with them. vertex tells which of the points
+// returned were in points already.
+func densify(points []fyne.Position, widths []float32, step float32) (dense []fyne.Position, denseWidths []float32, vertex []bool) {
+	if len(points) < 2 || step <= 0 {
+		vertex = make([]bool, len(points))
+		for i := range vertex {
+			vertex[i] = true
+		}
+		return points, widths, vertex
+	}
+	dense, denseWidths, vertex = []fyne.Position{points[0]}, []float32{widths[0]}, []bool{true}
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		n := int(distance(a, b) / step)
+		for k := 1; k <= n; k++ {
+			t := float32(k) / float32(n+1)
+			dense = append(dense, fyne.NewPos(a.X+t*(b.X-a.X), a.Y+t*(b.Y-a.Y)))
+			denseWidths = append(denseWidths, widths[i-1]+t*(widths[i]-widths[i-1]))
+			vertex = append(vertex, false)
+		}
+		dense = append(dense, b)
+		denseWidths = append(denseWidths, widths[i])
+		vertex = append(vertex, true)
+	}
+	return dense, denseWidths, vertex
+}
+
+// SplitPath cuts away the parts of p the eraser touches and returns what is
+// left over as plain polylines with the colour, stroke and widths of p, but
+// no ID, owner or transform. Pieces keep the points of p plus one where each
+// cut is made, and are split further where they would have more points than
+// the limits allow. Pieces of a single point are dropped.
+func SplitPath(p Path, trail []fyne.Position, radius float32) []Path {
+	points, widths := p.PolylineWidths()
+	step := max(radius/2, 1, polylineLength(points)/maxEraseSamples)
+	points, widths, vertex := densify(points, widths, step)
+	kept := make([]bool, len(points))
+	for i, pt := range points {
+		kept[i] = distanceToTrail(pt, trail) > radius+widths[i]/2
+	}
+	stroke := p.Stroke // Pieces have the transform of p baked in
+	if p.Transform != nil {
+		stroke *= p.Transform.Scale()
+	}
+
+	var pieces []Path
+	var run Path
+	flush := func() {
+		if len(run.Points) > 1 {
+			if len(p.Widths) == 0 {
+				run.Widths = nil
+			}
+			pieces = append(pieces, run)
+		}
+		run = Path{Color: p.Color, Stroke: stroke}
+	}
+	add := func(pt fyne.Position, width float32) {
+		if n := len(run.Points); n == DefaultLimits.MaxPointsPerPath {
+			last, lastWidth := run.Points[n-1], run.Widths[n-1]
+			flush() // The next piece carries on from where this one ends
+			run.Points, run.Widths = append(run.Points, last), append(run.Widths, lastWidth)
+		}
+		run.Points = append(run.Points, pt)
+		run.Widths = append(run.Widths, width)
+	}
+	flush()
+	for i, pt := range points {
+		switch {
+		case !kept[i]:
+			flush()
+		case vertex[i] || !kept[i-1] || !kept[i+1]: // Points in between only mark a cut
+			add(pt, widths[i])
+		}
+	}
+	flush()
+	return pieces
+}
+
+// EraseLocal erases every visible path the eraser touches on behalf of
+// ownerID. A whole-stroke erase deletes the paths; a partial erase replaces
+// each of them with the pieces left over, which belong to ownerID. Text,
+// images and filled shapes can't be cut and are always deleted whole. It
+// returns the operations to be broadcast.
+func (ws *WhiteboardState) EraseLocal(ownerID string, trail []fyne.Position, radius float32, partial bool) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{}
+	var restores, removals []edit // Undo re-adds originals after deleting pieces
+	for _, id := range ws.nearTrailLocked(trail, radius) {
+		p := ws.paths[id]
+		if ws.untouchableLocked(p) || !PathTouches(p, trail, radius) {
+			continue
+		}
+		entry.redo = append(entry.redo, edit{typ: OpDelete, target: id, owner: ownerID})
+		restores = append(restores, edit{typ: OpAdd, path: p})
+		if !partial || p.Text != nil || p.Image != nil || (p.Shape != nil && p.Shape.Fill != "") {
+			continue
+		}
+		for _, piece := range SplitPath(p, trail, radius) {
+			piece.ID = fmt.Sprintf("path-%s-%d", ws.siteID, ws.clock.Tick())
+			piece.OwnerID = ownerID
+			piece.Layer, piece.Z = p.Layer, p.Z // Pieces stay where the path was stacked
+			if err := ValidatePath(piece, DefaultLimits); err != nil {
+				log.Printf("[CRDT] Dropping erased piece: %v", err)
+				continue
+			}
+			entry.redo = append(entry.redo, edit{typ: OpAdd, path: piece})
+			removals = append(removals, edit{typ: OpDelete, target: piece.ID, owner: ownerID})
+		}
+	}
+	entry.undo = append(removals, restores...)
+
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	if len(ops) > 0 {
+		log.Printf("[CRDT] Local erase with %d operations", len(ops))
+	}
+	return ops, change
+}
+
+// Path returns a path the state knows of, even if it is not visible
+func (ws *WhiteboardState) Path(id string) (Path, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	p, ok := ws.paths[id]
+	return p, ok
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"unicode/utf8"
+)
+
+// Frames are the pages of a board: named areas of the canvas, listed in
+// their own order. Like layers, a frame is a record whose newest version
+// wins. A deleted frame stays behind as a tombstone, which is itself just the
+// newest version: a change stamped before the delete loses to it, one stamped
+// after brings the page back, as undoing the delete does. Which paths are on
+// a page follows from where they are, not from a field of theirs.
+
+// Longest a frame's ID and name may be, in bytes
+const (
+	MaxFrameID   = 64
+	MaxFrameName = 64
+)
+
+// Frame is a page of the board
+type Frame struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"` // Order in the page list; ties go by ID
+	X        float32 `json:"x"`
+	Y        float32 `json:"y"`
+	Width    float32 `json:"width"`
+	Height   float32 `json:"height"`
+	Deleted  bool    `json:"deleted,omitempty"`
+}
+
+// Contains reports whether the board position x, y is on f
+func (f Frame) Contains(x, y float32) bool {
+	return x >= f.X && x <= f.X+f.Width && y >= f.Y && y <= f.Y+f.Height
+}
+
+func validateFrame(f Frame, limits Limits) error {
+	if f.ID == "" || len(f.ID) > MaxFrameID {
+		return fmt.Errorf("frame has an invalid ID %q", f.ID)
+	}
+	if f.Name == "" || len(f.Name) > MaxFrameName || !utf8.ValidString(f.Name) {
+		return fmt.Errorf("frame %s has an invalid name", f.ID)
+	}
+	if !isFinite(float32(f.Position)) {
+		return fmt.Errorf("frame %s has an invalid position", f.ID)
+	}
+	for _, v := range []float32{f.X, f.Y, f.X + f.Width, f.Y + f.Height} {
+		if !isFinite(v) || v < -limits.MaxCoordinate || v > limits.MaxCoordinate {
+			return fmt.Errorf("frame %s is out of bounds", f.ID)
+		}
+	}
+	if f.Width <= 0 || f.Height <= 0 {
+		return fmt.Errorf("frame %s has no area", f.ID)
+	}
+	return nil
+}
+
+// SortFrames puts frames in page order
+func SortFrames(frames []Frame) {
+	sort.Slice(frames, func(i, j int) bool {
+		if frames[i].Position != frames[j].Position {
+			return frames[i].Position < frames[j].Position
+		}
+		return frames[i].ID < frames[j].ID
+	})
+}
+
+// Frames returns the frames that are not deleted, in page order
+func (ws *WhiteboardState) Frames() []Frame {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.framesLocked()
+}
+
+// framesLocked returns the frames that are not deleted, in page order.
+// Callers must hold ws.mu.
+func (ws *WhiteboardState) framesLocked() []Frame {
+	frames := make([]Frame, 0, len(ws.frames))
+	for _, f := range ws.frames {
+		if !f.Deleted {
+			frames = append(frames, f)
+		}
+	}
+	SortFrames(frames)
+	return frames
+}
+
+// SetFrameLocal creates, changes or deletes a frame on behalf of ownerID and
+// returns the operation to be broadcast
+func (ws *WhiteboardState) SetFrameLocal(ownerID string, f Frame) (PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	op := ws.newLocalOperation(OpFrame)
+	op.OwnerID = ownerID
+	op.Frame = &f
+	change := ws.applyLocked(op)
+	log.Printf("[CRDT] Local frame change: %s", f.ID)
+	return op, change
+}
+
+// DeleteFrameLocal deletes a frame and the paths on it on behalf of ownerID,
+// as one action to undo, and returns the operations to be broadcast
+func (ws *WhiteboardState) DeleteFrameLocal(ownerID, frameID string, pathIDs []string) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	f, ok := ws.frames[frameID]
+	if !ok || f.Deleted {
+		return nil, Change{}
+	}
+	entry := historyEntry{}
+	for _, id := range pathIDs {
+		if ws.visibleLocked(id) {
+			entry.undo = append(entry.undo, edit{typ: OpAdd, path: ws.paths[id]})
+			entry.redo = append(entry.redo, edit{typ: OpDelete, target: id, owner: ownerID})
+		}
+	}
+	deleted := f
+	deleted.Deleted = true
+	entry.undo = append(entry.undo, edit{typ: OpFrame, owner: ownerID, frame: f})
+	entry.redo = append(entry.redo, edit{typ: OpFrame, owner: ownerID, frame: deleted})
+
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	log.Printf("[CRDT] Local delete of frame %s with %d paths", frameID, len(ops)-1)
+	return ops, change
+}
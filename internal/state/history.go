@@ -0,0 +1,134 @@
+package state
+
+// MaxUndoDepth bounds how many local actions can be undone
+const MaxUndoDepth = 100
+
+// edit is the intent of an operation, turned into a fresh operation each time
+// it is undone or redone.
+type edit struct {
+	typ       string
+	path      Path      // For OpAdd
+	target    string    // For OpDelete, OpTransform, OpSetLayer and OpRestack
+	owner     string    // User a delete, transform, move, restack or frame change acts for
+	transform Transform // For OpTransform
+	layer     string    // For OpSetLayer
+	z         float64   // For OpRestack
+	frame     Frame     // For OpFrame
+}
+
+// historyEntry holds the edits that revert and reapply one local action.
+type historyEntry struct {
+	undo []edit
+	redo []edit
+	key  string // Element an edit changed, for merging an editing session
+}
+
+// history is the undo/redo stack of the local user. It only holds our own
+// actions, and undoing one only touches the paths that action affected, so
+// whatever others drew in between is left alone.
+type history struct {
+	undo []historyEntry
+	redo []historyEntry
+}
+
+// record pushes a new local action, dropping anything that could be redone
+func (h *history) record(entry historyEntry) {
+	if len(entry.undo) == 0 {
+		return
+	}
+	h.undo = append(h.undo, entry)
+	if len(h.undo) > MaxUndoDepth {
+		h.undo = h.undo[1:]
+	}
+	h.redo = nil
+}
+
+// extend merges entry into the last action if that changed the same element,
+// keeping the older undo and the newer redo. Otherwise it records entry.
+func (h *history) extend(entry historyEntry) {
+	if n := len(h.undo); n > 0 && entry.key != "" && h.undo[n-1].key == entry.key {
+		h.undo[n-1].redo = entry.redo
+		h.redo = nil
+		return
+	}
+	h.record(entry)
+}
+
+// applyEditsLocked turns edits into local operations and applies them.
+// Callers must hold ws.mu.
+func (ws *WhiteboardState) applyEditsLocked(edits []edit) ([]PathOperation, Change) {
+	ops := make([]PathOperation, 0, len(edits))
+	var change Change
+	for _, e := range edits {
+		op := ws.newLocalOperation(e.typ)
+		switch e.typ {
+		case OpAdd:
+			p := e.path
+			p.Transform = nil // Keep wherever the path has been placed since
+			if _, known := ws.paths[p.ID]; known {
+				// and on whatever layer it was put on, as high as it was stacked
+				p.Layer, p.Z = "", 0
+			} else if p.Z == 0 {
+				p.Z = float64(op.Timestamp) // A new path goes on top
+			}
+			op.Path = &p
+		case OpDelete:
+			op.Target = e.target
+			op.OwnerID = e.owner
+		case OpTransform:
+			t := e.transform
+			op.Target = e.target
+			op.OwnerID = e.owner
+			op.Transform = &t
+		case OpSetLayer:
+			op.Target = e.target
+			op.OwnerID = e.owner
+			op.LayerID = e.layer
+		case OpRestack:
+			op.Target = e.target
+			op.OwnerID = e.owner
+			op.Z = e.z
+		case OpFrame:
+			f := e.frame
+			op.OwnerID = e.owner
+			op.Frame = &f
+		}
+		c := ws.applyLocked(op)
+		change.Removed = append(change.Removed, c.Removed...)
+		change.Added = append(change.Added, c.Added...)
+		if c.Frames != nil {
+			change.Frames = c.Frames
+		}
+		ops = append(ops, op)
+	}
+	return ops, change
+}
+
+// Undo reverts the last local action with new operations, which are returned
+// to be broadcast. It returns no operations if there is nothing to undo.
+func (ws *WhiteboardState) Undo() ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if len(ws.history.undo) == 0 {
+		return nil, Change{}
+	}
+	entry := ws.history.undo[len(ws.history.undo)-1]
+	ws.history.undo = ws.history.undo[:len(ws.history.undo)-1]
+	ws.history.redo = append(ws.history.redo, entry)
+	return ws.applyEditsLocked(entry.undo)
+}
+
+// Redo reapplies the last undone local action.
+func (ws *WhiteboardState) Redo() ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if len(ws.history.redo) == 0 {
+		return nil, Change{}
+	}
+	entry := ws.history.redo[len(ws.history.redo)-1]
+	ws.history.redo = ws.history.redo[:len(ws.history.redo)-1]
+	ws.history.undo = append(ws.history.undo, entry)
+	return ws.applyEditsLocked(entry.redo)
+}
@@ -0,0 +1,121 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"fyne.io/fyne/v2"
+)
+
+// Largest image an element may show, in pixels per side and in all. An image
+// takes four bytes per pixel once decoded.
+const (
+	MaxImageSide   = 8192
+	MaxImagePixels = 16 << 20
+)
+
+// Most pages a background document may have
+const MaxBackgroundPages = 1000
+
+// MissingImageColor fills the box of an image whose picture is not there yet
+const MissingImageColor = "#e0e0e0ff"
+
+// Image turns a path into a picture. Its two points are the top left and
+// bottom right corners the picture is stretched over, before the path's
+// transform. The picture itself is an asset, fetched by its hash.
+type Image struct {
+	Asset  string `json:"asset"` // Hex SHA-256 of the PNG or JPEG file
+	Width  int    `json:"width"` // Size of the picture, in pixels
+	Height int    `json:"height"`
+	Page   int    `json:"page,omitempty"` // Page of the background document this shows, from 1
+}
+
+// IsAssetHash reports whether s is the form assets are named by: a lowercase
+// hex SHA-256
+func IsAssetHash(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ImageSizeAllowed reports whether an image of the given size in pixels is
+// within MaxImageSide and MaxImagePixels
+func ImageSizeAllowed(width, height int) bool {
+	return width > 0 && height > 0 && width <= MaxImageSide && height <= MaxImageSide &&
+		width*height <= MaxImagePixels
+}
+
+func validateImage(p Path) error {
+	img := p.Image
+	if len(p.Points) != 2 || p.Bezier || len(p.Widths) > 0 || p.Shape != nil || p.Text != nil {
+		return fmt.Errorf("image %s must have exactly two points and no widths, shape or text", p.ID)
+	}
+	if !IsAssetHash(img.Asset) {
+		return fmt.Errorf("image %s has an invalid asset %q", p.ID, img.Asset)
+	}
+	if !ImageSizeAllowed(img.Width, img.Height) {
+		return fmt.Errorf("image %s has invalid size %dx%d", p.ID, img.Width, img.Height)
+	}
+	if p.Points[0].X >= p.Points[1].X || p.Points[0].Y >= p.Points[1].Y {
+		return fmt.Errorf("image %s must go from its top left to its bottom right corner", p.ID)
+	}
+	if img.Page < 0 || img.Page > MaxBackgroundPages {
+		return fmt.Errorf("image %s has invalid page %d", p.ID, img.Page)
+	}
+	return nil
+}
+
+// IsBackground reports whether p is a page of the background document
+func (p Path) IsBackground() bool {
+	return p.Image != nil && p.Image.Page > 0
+}
+
+// SortPages puts the pages of the background document in page order
+func SortPages(pages []Path) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Image.Page < pages[j].Image.Page
+	})
+}
+
+// ImageTransform returns the transform placing the pixels of an image
+// element on the board, its own transform included
+func (p Path) ImageTransform() Transform {
+	lo, hi := p.Points[0], p.Points[1]
+	t := Transform{
+		A: (hi.X - lo.X) / float32(p.Image.Width),
+		D: (hi.Y - lo.Y) / float32(p.Image.Height),
+		E: lo.X,
+		F: lo.Y,
+	}
+	if p.Transform != nil {
+		t = t.Then(*p.Transform)
+	}
+	return t
+}
+
+// NewImagePath returns an image element showing img with its top left corner
+// at pos, size units wide and as high as keeps its proportions
+func NewImagePath(id, ownerID string, img Image, pos fyne.Position, width float32) Path {
+	height := width * float32(img.Height) / float32(img.Width)
+	return Path{
+		ID:      id,
+		OwnerID: ownerID,
+		Points:  []fyne.Position{pos, pos.AddXY(width, height)},
+		Color:   "#00000000",
+		Stroke:  1,
+		Image:   &img,
+	}
+}
+
+func equalImages(a, b *Image) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"sort"
+
+	"fyne.io/fyne/v2"
+
+	"MyLocalBoard/internal/spatial"
+)
+
+// PathBounds returns the bounding box of a path, stroke included
+func PathBounds(p Path) (spatial.Rect, bool) {
+	return spatial.BoundsOf(toSpatial(p.Polyline()), p.MaxWidth()/2)
+}
+
+func toSpatial(points []fyne.Position) []spatial.Point {
+	out := make([]spatial.Point, len(points))
+	for i, pt := range points {
+		out[i] = spatial.Point(pt)
+	}
+	return out
+}
+
+// indexChangeLocked keeps the spatial index in step with the visible paths.
+// Callers must hold ws.mu.
+func (ws *WhiteboardState) indexChangeLocked(change Change) {
+	for _, id := range change.Removed {
+		ws.index.Remove(id)
+	}
+	for _, p := range change.Added {
+		if box, ok := PathBounds(p); ok {
+			ws.index.Insert(p.ID, box)
+		}
+	}
+}
+
+// sortByOrderLocked puts path IDs in arrival order. Callers must hold ws.mu.
+func (ws *WhiteboardState) sortByOrderLocked(ids []string) {
+	sort.Slice(ids, func(i, j int) bool { return ws.position[ids[i]] < ws.position[ids[j]] })
+}
+
+// nearTrailLocked returns the visible paths whose bounding boxes come within
+// radius of trail, in arrival order. Callers must hold ws.mu.
+func (ws *WhiteboardState) nearTrailLocked(trail []fyne.Position, radius float32) []string {
+	found := make(map[string]struct{})
+	for _, s := range segments(trail) {
+		for _, id := range ws.index.QuerySegment(spatial.Point(s[0]), spatial.Point(s[1]), radius) {
+			found[id] = struct{}{}
+		}
+	}
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	ws.sortByOrderLocked(ids)
+	return ids
+}
+
+// PathsInRect returns the visible paths whose bounding boxes intersect r,
+// in arrival order
+func (ws *WhiteboardState) PathsInRect(r spatial.Rect) []Path {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	ids := ws.index.QueryRect(r)
+	ws.sortByOrderLocked(ids)
+	paths := make([]Path, len(ids))
+	for i, id := range ids {
+		paths[i] = ws.paths[id]
+	}
+	return paths
+}
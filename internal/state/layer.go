@@ -0,0 +1,219 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"unicode/utf8"
+)
+
+// Layers group elements so they can be hidden, locked and stacked together.
+// A layer is a record whose newest version wins, like a text element. Which
+// layer a path is on is kept apart from the path, like its transform, so
+// moving a path to another layer sends only the layer's ID.
+
+// DefaultLayer holds the paths that name no layer. It is there from the start.
+const DefaultLayer = "default"
+
+// BackgroundLayer holds the pages of a document opened as background
+const BackgroundLayer = "background"
+
+// Longest a layer's ID and name may be, in bytes
+const (
+	MaxLayerID   = 64
+	MaxLayerName = 64
+)
+
+// Layer is a named group of paths, drawn together
+type Layer struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"` // Stacking order, bottom first; ties go by ID
+	Hidden   bool    `json:"hidden,omitempty"`
+	Locked   bool    `json:"locked,omitempty"` // Paths on it can't be added, changed or removed
+}
+
+// BaseLayer returns the default layer as it is before anyone changes it
+func BaseLayer() Layer {
+	return Layer{ID: DefaultLayer, Name: "Layer 1"}
+}
+
+// LayerID returns the layer p is on
+func (p Path) LayerID() string {
+	if p.Layer == "" {
+		return DefaultLayer
+	}
+	return p.Layer
+}
+
+func validateLayer(l Layer) error {
+	if l.ID == "" || len(l.ID) > MaxLayerID {
+		return fmt.Errorf("layer has an invalid ID %q", l.ID)
+	}
+	if l.Name == "" || len(l.Name) > MaxLayerName || !utf8.ValidString(l.Name) {
+		return fmt.Errorf("layer %s has an invalid name", l.ID)
+	}
+	if !isFinite(float32(l.Position)) {
+		return fmt.Errorf("layer %s has an invalid position", l.ID)
+	}
+	return nil
+}
+
+// SortLayers puts layers in stacking order, bottom first
+func SortLayers(layers []Layer) {
+	sort.Slice(layers, func(i, j int) bool {
+		if layers[i].Position != layers[j].Position {
+			return layers[i].Position < layers[j].Position
+		}
+		return layers[i].ID < layers[j].ID
+	})
+}
+
+// Layers returns every layer, bottom first
+func (ws *WhiteboardState) Layers() []Layer {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	return ws.layersLocked()
+}
+
+// layersLocked returns every layer, bottom first. Callers must hold ws.mu.
+func (ws *WhiteboardState) layersLocked() []Layer {
+	layers := make([]Layer, 0, len(ws.layers)+1)
+	if _, ok := ws.layers[DefaultLayer]; !ok {
+		layers = append(layers, BaseLayer())
+	}
+	for _, l := range ws.layers {
+		layers = append(layers, l)
+	}
+	SortLayers(layers)
+	return layers
+}
+
+// layerLocked returns the layer with the given ID, or false if there is no
+// such layer. Callers must hold ws.mu.
+func (ws *WhiteboardState) layerLocked(id string) (Layer, bool) {
+	if l, ok := ws.layers[id]; ok {
+		return l, true
+	}
+	if id == DefaultLayer {
+		return BaseLayer(), true
+	}
+	return Layer{}, false
+}
+
+// setLayerLocked records layer as the one path id is on if s is newer than
+// the move it had. Callers must hold ws.mu.
+func (ws *WhiteboardState) setLayerLocked(id, layer string, s stamp) bool {
+	if prev, ok := ws.layered[id]; ok && !s.after(prev) {
+		return false
+	}
+	ws.layerOf[id] = layer
+	ws.layered[id] = s
+	return true
+}
+
+// untouchableLocked reports whether p is on a locked or hidden layer, which
+// erasing passes over. Callers must hold ws.mu.
+func (ws *WhiteboardState) untouchableLocked(p Path) bool {
+	l, ok := ws.layerLocked(p.LayerID())
+	return ok && (l.Locked || l.Hidden)
+}
+
+// SetLayerLocal creates or changes a layer on behalf of ownerID and returns
+// the operation to be broadcast
+func (ws *WhiteboardState) SetLayerLocal(ownerID string, l Layer) (PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	op := ws.newLocalOperation(OpLayer)
+	op.OwnerID = ownerID
+	op.Layer = &l
+	change := ws.applyLocked(op)
+	log.Printf("[CRDT] Local layer change: %s", l.ID)
+	return op, change
+}
+
+// MoveToLayerLocal puts each visible path in ids on layer, on behalf of
+// ownerID, and returns the operations to be broadcast. The whole move undoes
+// at once.
+func (ws *WhiteboardState) MoveToLayerLocal(ownerID string, ids []string, layer string) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{}
+	for _, id := range ids {
+		if !ws.visibleLocked(id) || ws.paths[id].LayerID() == layer {
+			continue
+		}
+		entry.undo = append(entry.undo, edit{typ: OpSetLayer, target: id, owner: ownerID, layer: ws.paths[id].LayerID()})
+		entry.redo = append(entry.redo, edit{typ: OpSetLayer, target: id, owner: ownerID, layer: layer})
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	if len(ops) > 0 {
+		log.Printf("[CRDT] Local move of %d paths to layer %s", len(ops), layer)
+	}
+	return ops, change
+}
+
+// ChangesLock reports whether op would lock or unlock a layer, which includes
+// adding a layer that starts out locked
+func (ws *WhiteboardState) ChangesLock(op PathOperation) bool {
+	if op.Type != OpLayer || op.Layer == nil {
+		return false
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	l, ok := ws.layerLocked(op.Layer.ID)
+	return op.Layer.Locked != (ok && l.Locked)
+}
+
+// LockedLayer returns the locked layer op would add, change or remove paths
+// on, if there is one. Changes to layers themselves are left to ChangesLock.
+func (ws *WhiteboardState) LockedLayer(op PathOperation) (Layer, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	locked := func(id string) (Layer, bool) {
+		l, ok := ws.layerLocked(id)
+		return l, ok && l.Locked
+	}
+	current := func(pathID string) (Layer, bool) {
+		if !ws.visibleLocked(pathID) {
+			return Layer{}, false
+		}
+		return locked(ws.paths[pathID].LayerID())
+	}
+	switch op.Type {
+	case OpAdd:
+		if op.Path == nil {
+			return Layer{}, false
+		}
+		// A new version of a path stays on the layer it is on, unless it names another
+		known, ok := ws.paths[op.Path.ID]
+		if ok {
+			if l, isLocked := locked(known.LayerID()); isLocked {
+				return l, true
+			}
+		}
+		if !ok || op.Path.Layer != "" {
+			return locked(op.Path.LayerID())
+		}
+	case OpDelete, OpTransform, OpRestack:
+		return current(op.Target)
+	case OpSetLayer:
+		if l, ok := current(op.Target); ok {
+			return l, true
+		}
+		return locked(op.LayerID)
+	case OpClear:
+		for _, id := range ws.order {
+			if p := ws.paths[id]; op.OwnerID == "all" || p.OwnerID == op.OwnerID {
+				if l, ok := current(id); ok {
+					return l, true
+				}
+			}
+		}
+	}
+	return Layer{}, false
+}
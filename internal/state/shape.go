@@ -0,0 +1,179 @@
+package state
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"fyne.io/fyne/v2"
+)
+
+// Shape kinds
+const (
+	ShapeRect    = "rect"
+	ShapeEllipse = "ellipse"
+	ShapeLine    = "line"
+	ShapeArrow   = "arrow"
+)
+
+// Shape turns a path into a geometric shape. Its two points are the corners
+// of a rectangle or ellipse, or the ends of a line or arrow (pointing at the
+// second). Shapes are drawn with the path's colour and stroke.
+type Shape struct {
+	Kind   string  `json:"kind"`
+	Fill   string  `json:"fill,omitempty"`   // Colour inside rectangles and ellipses, none if empty
+	Radius float32 `json:"radius,omitempty"` // Corner radius of rectangles
+}
+
+// Segments used to draw curves of shapes
+const (
+	ellipseSegments = 48
+	cornerSegments  = 8
+)
+
+// Arrowhead proportions
+const (
+	arrowHeadAngle  = 28 * math.Pi / 180
+	arrowHeadLength = 4 // Times the stroke width
+	minArrowHead    = 12
+)
+
+func validateShape(p Path, limits Limits) error {
+	s := p.Shape
+	switch s.Kind {
+	case ShapeRect, ShapeEllipse, ShapeLine, ShapeArrow:
+	default:
+		return fmt.Errorf("path %s has unknown shape %q", p.ID, s.Kind)
+	}
+	if len(p.Points) != 2 || p.Bezier || len(p.Widths) > 0 {
+		return fmt.Errorf("shape %s must have exactly two points and no widths", p.ID)
+	}
+	if s.Fill != "" && !IsValidColor(s.Fill) {
+		return fmt.Errorf("shape %s has invalid fill %q", p.ID, s.Fill)
+	}
+	if !isFinite(s.Radius) || s.Radius < 0 || s.Radius > limits.MaxCoordinate {
+		return fmt.Errorf("shape %s has an invalid corner radius", p.ID)
+	}
+	return nil
+}
+
+// shapeOutline returns the polyline a shape is stroked along
+func (p Path) shapeOutline() []fyne.Position {
+	if len(p.Points) != 2 {
+		return p.Points
+	}
+	a, b := p.Points[0], p.Points[1]
+	switch p.Shape.Kind {
+	case ShapeRect:
+		return closeLoop(roundedRect(a, b, p.Shape.Radius))
+	case ShapeEllipse:
+		return closeLoop(ellipse(a, b))
+	case ShapeArrow:
+		return arrow(a, b, max(minArrowHead, arrowHeadLength*p.Stroke))
+	default:
+		return []fyne.Position{a, b}
+	}
+}
+
+// FillPolygon returns the area to fill inside a shape or sticky note, and
+// its colour
+func (p Path) FillPolygon() ([]fyne.Position, string, bool) {
+	fill, c, ok := p.untransformedFill()
+	if ok && p.Transform != nil {
+		fill = p.Transform.applyAll(fill)
+	}
+	return fill, c, ok
+}
+
+func (p Path) untransformedFill() ([]fyne.Position, string, bool) {
+	if p.Text != nil && p.Text.Note != "" && len(p.Points) == 2 {
+		return roundedRect(p.Points[0], p.Points[1], NoteCornerRadius), p.Text.Note, true
+	}
+	if p.Shape == nil || p.Shape.Fill == "" || len(p.Points) != 2 {
+		return nil, "", false
+	}
+	switch p.Shape.Kind {
+	case ShapeRect:
+		return roundedRect(p.Points[0], p.Points[1], p.Shape.Radius), p.Shape.Fill, true
+	case ShapeEllipse:
+		return ellipse(p.Points[0], p.Points[1]), p.Shape.Fill, true
+	}
+	return nil, "", false
+}
+
+func closeLoop(points []fyne.Position) []fyne.Position {
+	return append(points, points[0])
+}
+
+// roundedRect returns the corners of the rectangle spanned by a and b,
+// clockwise on screen, with corners rounded by radius
+func roundedRect(a, b fyne.Position, radius float32) []fyne.Position {
+	lo := fyne.NewPos(min(a.X, b.X), min(a.Y, b.Y))
+	hi := fyne.NewPos(max(a.X, b.X), max(a.Y, b.Y))
+	r := min(radius, (hi.X-lo.X)/2, (hi.Y-lo.Y)/2)
+	if r <= 0 {
+		return []fyne.Position{lo, {X: hi.X, Y: lo.Y}, hi, {X: lo.X, Y: hi.Y}}
+	}
+	// Corner centres, starting top left, with the angle each arc starts at
+	corners := []struct {
+		c     fyne.Position
+		start float64
+	}{
+		{fyne.NewPos(lo.X+r, lo.Y+r), math.Pi},
+		{fyne.NewPos(hi.X-r, lo.Y+r), 1.5 * math.Pi},
+		{fyne.NewPos(hi.X-r, hi.Y-r), 0},
+		{fyne.NewPos(lo.X+r, hi.Y-r), 0.5 * math.Pi},
+	}
+	points := make([]fyne.Position, 0, 4*(cornerSegments+1))
+	for _, corner := range corners {
+		for k := 0; k <= cornerSegments; k++ {
+			angle := corner.start + math.Pi/2*float64(k)/cornerSegments
+			points = append(points, fyne.NewPos(
+				corner.c.X+r*float32(math.Cos(angle)),
+				corner.c.Y+r*float32(math.Sin(angle)),
+			))
+		}
+	}
+	return points
+}
+
+// ellipse returns points around the ellipse inside the box spanned by a and b
+func ellipse(a, b fyne.Position) []fyne.Position {
+	c := fyne.NewPos((a.X+b.X)/2, (a.Y+b.Y)/2)
+	rx, ry := float32(math.Abs(float64(b.X-a.X)))/2, float32(math.Abs(float64(b.Y-a.Y)))/2
+	points := make([]fyne.Position, ellipseSegments)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / ellipseSegments
+		points[i] = fyne.NewPos(c.X+rx*float32(math.Cos(angle)), c.Y+ry*float32(math.Sin(angle)))
+	}
+	return points
+}
+
+// arrow returns a line from a to b with a head at b, drawn as one polyline
+// that runs out to each barb and back
+func arrow(a, b fyne.Position, head float32) []fyne.Position {
+	angle := math.Atan2(float64(a.Y-b.Y), float64(a.X-b.X))
+	barb := func(turn float64) fyne.Position {
+		return fyne.NewPos(
+			b.X+head*float32(math.Cos(angle+turn)),
+			b.Y+head*float32(math.Sin(angle+turn)),
+		)
+	}
+	return []fyne.Position{a, b, barb(arrowHeadAngle), b, barb(-arrowHeadAngle)}
+}
+
+// equalShapes reports whether two optional shapes are the same
+func equalShapes(a, b *Shape) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// SameContent reports whether two paths look exactly alike
+func SameContent(a, b Path) bool {
+	return a.OwnerID == b.OwnerID && a.Color == b.Color && a.Stroke == b.Stroke &&
+		a.Bezier == b.Bezier && equalShapes(a.Shape, b.Shape) && equalTexts(a.Text, b.Text) &&
+		equalImages(a.Image, b.Image) &&
+		slices.Equal(a.Points, b.Points) && slices.Equal(a.Widths, b.Widths)
+}
@@ -1,8 +1,11 @@
 package state
 
 import (
+	"strconv"
 	"sync"
 	"time"
+
+	"MyLocalBoard/internal/spatial"
 )
 
 // DrawingArea represents a rectangular area on the canvas
@@ -30,6 +33,7 @@ type SpaceRegion struct {
 // SpaceManager handles drawing area allocation and conflict resolution
 type SpaceManager struct {
 	regions       []SpaceRegion
+	index         *spatial.Index         // Region areas, keyed by their index in regions
 	allocatedAreas map[string]DrawingArea // clientID -> assigned area
 	mu            sync.RWMutex
 }
@@ -37,6 +41,7 @@ type SpaceManager struct {
 func NewSpaceManager() *SpaceManager {
 	return &SpaceManager{
 		regions:        make([]SpaceRegion, 0),
+		index:          spatial.NewIndex(spatial.DefaultCellSize),
 		allocatedAreas: make(map[string]DrawingArea),
 	}
 }
@@ -104,18 +109,20 @@ func (sm *SpaceManager) ClaimSpace(pathID, owner string, points []Position) {
 		PathIDs:   []string{pathID},
 	}
 
-	// Merge with existing region if owned by same user
-	merged := false
-	for i, existingRegion := range sm.regions {
-		if existingRegion.Owner == owner && sm.regionsOverlap(region.Area, existingRegion.Area) {
-			// Merge regions
-			sm.regions[i] = sm.mergeRegions(existingRegion, region)
-			merged = true
-			break
+	// Merge with the earliest overlapping region of the same user
+	target := -1
+	for _, key := range sm.index.QueryRect(areaRect(region.Area)) {
+		i, _ := strconv.Atoi(key)
+		if sm.regions[i].Owner == owner && (target < 0 || i < target) {
+			target = i
 		}
 	}
 
-	if !merged {
+	if target >= 0 {
+		sm.regions[target] = sm.mergeRegions(sm.regions[target], region)
+		sm.index.Insert(strconv.Itoa(target), areaRect(sm.regions[target].Area))
+	} else {
+		sm.index.Insert(strconv.Itoa(len(sm.regions)), areaRect(region.Area))
 		sm.regions = append(sm.regions, region)
 	}
 }
@@ -159,18 +166,12 @@ func (sm *SpaceManager) CanDrawInArea(clientID string, points []Position) bool {
 
 // Helper methods
 
-func (sm *SpaceManager) isAreaOccupied(area DrawingArea) bool {
-	for _, region := range sm.regions {
-		if sm.regionsOverlap(area, region.Area) {
-			return true
-		}
-	}
-	return false
+func areaRect(area DrawingArea) spatial.Rect {
+	return spatial.Rect{MinX: area.X, MinY: area.Y, MaxX: area.X + area.Width, MaxY: area.Y + area.Height}
 }
 
-func (sm *SpaceManager) regionsOverlap(a, b DrawingArea) bool {
-	return !(a.X+a.Width < b.X || b.X+b.Width < a.X || 
-		     a.Y+a.Height < b.Y || b.Y+b.Height < a.Y)
+func (sm *SpaceManager) isAreaOccupied(area DrawingArea) bool {
+	return len(sm.index.QueryRect(areaRect(area))) > 0
 }
 
 func (sm *SpaceManager) pointInArea(point Position, area DrawingArea) bool {
@@ -179,8 +180,9 @@ func (sm *SpaceManager) pointInArea(point Position, area DrawingArea) bool {
 }
 
 func (sm *SpaceManager) pointInOccupiedRegion(point Position, excludeOwner string) bool {
-	for _, region := range sm.regions {
-		if region.Owner != excludeOwner && sm.pointInArea(point, region.Area) {
+	for _, key := range sm.index.QueryPoint(spatial.Point(point), 0) {
+		i, _ := strconv.Atoi(key)
+		if sm.regions[i].Owner != excludeOwner {
 			return true
 		}
 	}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"log"
+	"math"
+	"sort"
+)
+
+// Paths are stacked by their height Z, which every peer agrees on, rather
+// than by when they arrived. A new path goes at the Lamport time of its add,
+// above everything its author could see. Bringing paths to the front or
+// sending them to the back gives them a new height, kept apart from the path
+// like its transform. Paths at the same height go by ID.
+
+// Below reports whether a is drawn under b on the same layer
+func Below(a, b Path) bool {
+	if a.Z != b.Z {
+		return a.Z < b.Z
+	}
+	return a.ID < b.ID
+}
+
+// SortStacked puts paths in stacking order, bottom first
+func SortStacked(paths []Path) {
+	sort.Slice(paths, func(i, j int) bool { return Below(paths[i], paths[j]) })
+}
+
+// setHeightLocked records z as the height of path id if s is newer than the
+// height it had. Callers must hold ws.mu.
+func (ws *WhiteboardState) setHeightLocked(id string, z float64, s stamp) bool {
+	if prev, ok := ws.stacked[id]; ok && !s.after(prev) {
+		return false
+	}
+	ws.heights[id] = z
+	ws.stacked[id] = s
+	return true
+}
+
+// RestackLocal brings each visible path in ids to the front, or sends it to
+// the back, on behalf of ownerID, and returns the operations to be
+// broadcast. The paths keep their order among themselves, and the whole
+// change undoes at once.
+func (ws *WhiteboardState) RestackLocal(ownerID string, ids []string, front bool) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var moving []Path
+	for _, id := range ids {
+		if ws.visibleLocked(id) {
+			moving = append(moving, ws.paths[id])
+		}
+	}
+	SortStacked(moving)
+
+	// New paths go at the clock's time, so the front is above that too
+	top, bottom := float64(ws.clock.Now()), 0.0
+	for _, id := range ws.order {
+		if ws.visibleLocked(id) {
+			z := ws.paths[id].Z
+			top, bottom = math.Max(top, z), math.Min(bottom, z)
+		}
+	}
+	entry := historyEntry{}
+	for i, p := range moving {
+		z := top + float64(i+1)
+		if !front {
+			z = bottom - float64(len(moving)-i)
+		}
+		entry.undo = append(entry.undo, edit{typ: OpRestack, target: p.ID, owner: ownerID, z: p.Z})
+		entry.redo = append(entry.redo, edit{typ: OpRestack, target: p.ID, owner: ownerID, z: z})
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	if len(ops) > 0 {
+		log.Printf("[CRDT] Local restack of %d paths", len(ops))
+	}
+	return ops, change
+}
@@ -0,0 +1,196 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+)
+
+// Text alignments
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+// Limits of text elements
+const (
+	MaxTextLength = 10000 // Bytes of text in one element
+	MinTextSize   = 4
+	MaxTextSize   = 400
+	LineSpacing   = 1.3 // Line height relative to the text size
+)
+
+// Sticky note layout, in board units
+const (
+	NotePadding      float32 = 12 // Space between a note's edge and its text
+	NoteCornerRadius float32 = 4
+)
+
+// Text turns a path into a text box, or with Note set into a sticky note.
+// Its two points are the corners of the box the text wraps in, and the
+// path's colour is the colour of the text.
+type Text struct {
+	Content string  `json:"content"`
+	Size    float32 `json:"size"`
+	Align   string  `json:"align,omitempty"` // AlignLeft if empty
+	Note    string  `json:"note,omitempty"`  // Background colour of a sticky note
+}
+
+func validateText(p Path) error {
+	t := p.Text
+	if len(p.Points) != 2 || p.Bezier || len(p.Widths) > 0 || p.Shape != nil {
+		return fmt.Errorf("text %s must have exactly two points and no widths or shape", p.ID)
+	}
+	if len(t.Content) > MaxTextLength || !utf8.ValidString(t.Content) {
+		return fmt.Errorf("text %s is not valid UTF-8 of at most %d bytes", p.ID, MaxTextLength)
+	}
+	if !isFinite(t.Size) || t.Size < MinTextSize || t.Size > MaxTextSize {
+		return fmt.Errorf("text %s has invalid size %v", p.ID, t.Size)
+	}
+	switch t.Align {
+	case "", AlignLeft, AlignCenter, AlignRight:
+	default:
+		return fmt.Errorf("text %s has unknown alignment %q", p.ID, t.Align)
+	}
+	if t.Note != "" && !IsValidColor(t.Note) {
+		return fmt.Errorf("text %s has invalid note colour %q", p.ID, t.Note)
+	}
+	return nil
+}
+
+// TextBox returns the top left corner and size of a text element's box
+func (p Path) TextBox() (fyne.Position, fyne.Size) {
+	if len(p.Points) != 2 {
+		return fyne.Position{}, fyne.Size{}
+	}
+	a, b := p.Points[0], p.Points[1]
+	return fyne.NewPos(min(a.X, b.X), min(a.Y, b.Y)),
+		fyne.NewSize(max(a.X, b.X)-min(a.X, b.X), max(a.Y, b.Y)-min(a.Y, b.Y))
+}
+
+// textContains reports whether pt lies within radius of a text element's box
+func (p Path) textContains(pt fyne.Position, radius float32) bool {
+	pos, size := p.TextBox()
+	return pt.X >= pos.X-radius && pt.X <= pos.X+size.Width+radius &&
+		pt.Y >= pos.Y-radius && pt.Y <= pos.Y+size.Height+radius
+}
+
+// TextLine is one line of a text element, positioned in board units
+type TextLine struct {
+	Text string
+	Pos  fyne.Position // Top left corner
+}
+
+// LayoutText wraps the text of p into its box and aligns the lines. measure
+// returns how wide text is at a size. Lines that do not fit into a sticky
+// note are left out.
+func LayoutText(p Path, measure func(text string, size float32) float32) []TextLine {
+	t := p.Text
+	pos, size := p.TextBox()
+	pad := float32(0)
+	if t.Note != "" {
+		pad = NotePadding
+	}
+	width := max(size.Width-2*pad, t.Size)
+	lineHeight := t.Size * LineSpacing
+	wrapped := WrapText(t.Content, width, func(s string) float32 { return measure(s, t.Size) })
+	lines := make([]TextLine, 0, len(wrapped))
+	for i, text := range wrapped {
+		y := pos.Y + pad + float32(i)*lineHeight
+		if t.Note != "" && y+lineHeight > pos.Y+size.Height-pad {
+			break
+		}
+		x := pos.X + pad
+		switch t.Align {
+		case AlignCenter:
+			x += (width - measure(text, t.Size)) / 2
+		case AlignRight:
+			x += width - measure(text, t.Size)
+		}
+		lines = append(lines, TextLine{Text: text, Pos: fyne.NewPos(x, y)})
+	}
+	return lines
+}
+
+// WrapText breaks text into the lines it is drawn as: at newlines, and
+// between words wherever a line would get wider than width. measure returns
+// how wide a piece of text is. Words wider than the box are broken anywhere.
+func WrapText(text string, width float32, measure func(string) float32) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if measure(candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = word
+			for measure(line) > width && utf8.RuneCountInString(line) > 1 {
+				cut := fitRunes(line, width, measure)
+				lines = append(lines, line[:cut])
+				line = line[cut:]
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// fitRunes returns the byte length of the longest prefix of s, at least one
+// rune, that fits into width
+func fitRunes(s string, width float32, measure func(string) float32) int {
+	_, first := utf8.DecodeRuneInString(s)
+	cut := first
+	for i := range s {
+		if i > 0 && measure(s[:i]) > width {
+			break
+		}
+		cut = max(i, first)
+	}
+	return cut
+}
+
+// equalTexts reports whether two optional texts are the same
+func equalTexts(a, b *Text) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// EditLocal adds or replaces the text element p on behalf of the local user,
+// keeping its ID, and returns the operation to be broadcast. Concurrent edits
+// of one element are settled by the last writer. continued merges the edit
+// into the previous undo step if that edited the same element, so a whole
+// editing session undoes at once.
+func (ws *WhiteboardState) EditLocal(p Path, continued bool) (PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{key: p.ID, redo: []edit{{typ: OpAdd, path: p}}}
+	if prev, ok := ws.paths[p.ID]; ok && ws.visibleLocked(p.ID) {
+		entry.undo = []edit{{typ: OpAdd, path: prev}}
+	} else {
+		entry.undo = []edit{{typ: OpDelete, target: p.ID, owner: p.OwnerID}}
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	if continued {
+		ws.history.extend(entry)
+	} else {
+		ws.history.record(entry)
+	}
+
+	log.Printf("[CRDT] Local edit of text: %s", p.ID)
+	return ops[0], change
+}
@@ -0,0 +1,216 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"fyne.io/fyne/v2"
+)
+
+// Transform is an affine map of board positions:
+//
+//	x' = A*x + C*y + E
+//	y' = B*x + D*y + F
+type Transform struct {
+	A float32 `json:"a"`
+	B float32 `json:"b"`
+	C float32 `json:"c"`
+	D float32 `json:"d"`
+	E float32 `json:"e"`
+	F float32 `json:"f"`
+}
+
+// Identity leaves positions where they are
+func Identity() Transform {
+	return Transform{A: 1, D: 1}
+}
+
+// Translate moves positions by dx, dy
+func Translate(dx, dy float32) Transform {
+	return Transform{A: 1, D: 1, E: dx, F: dy}
+}
+
+// ScaleAbout scales positions by sx, sy away from the fixed point c
+func ScaleAbout(c fyne.Position, sx, sy float32) Transform {
+	return Transform{A: sx, D: sy, E: c.X - sx*c.X, F: c.Y - sy*c.Y}
+}
+
+// RotateAbout turns positions by angle radians, clockwise on screen, around c
+func RotateAbout(c fyne.Position, angle float64) Transform {
+	sin, cos := float32(math.Sin(angle)), float32(math.Cos(angle))
+	return Transform{
+		A: cos, B: sin, C: -sin, D: cos,
+		E: c.X - cos*c.X + sin*c.Y,
+		F: c.Y - sin*c.X - cos*c.Y,
+	}
+}
+
+// Then returns the transform applying t first and u second
+func (t Transform) Then(u Transform) Transform {
+	return Transform{
+		A: u.A*t.A + u.C*t.B,
+		B: u.B*t.A + u.D*t.B,
+		C: u.A*t.C + u.C*t.D,
+		D: u.B*t.C + u.D*t.D,
+		E: u.A*t.E + u.C*t.F + u.E,
+		F: u.B*t.E + u.D*t.F + u.F,
+	}
+}
+
+// Apply maps a position
+func (t Transform) Apply(p fyne.Position) fyne.Position {
+	return fyne.NewPos(t.A*p.X+t.C*p.Y+t.E, t.B*p.X+t.D*p.Y+t.F)
+}
+
+// Scale returns how much t scales lengths on average, which is how much
+// stroke widths grow
+func (t Transform) Scale() float32 {
+	return float32(math.Sqrt(math.Abs(float64(t.A*t.D - t.B*t.C))))
+}
+
+// applyAll maps positions into a new slice
+func (t Transform) applyAll(points []fyne.Position) []fyne.Position {
+	out := make([]fyne.Position, len(points))
+	for i, p := range points {
+		out[i] = t.Apply(p)
+	}
+	return out
+}
+
+// Largest scale factor a transform may have, which keeps strokes in bounds
+const maxTransformScale = 1000
+
+func validateTransform(t Transform, limits Limits) error {
+	for _, f := range []float32{t.A, t.B, t.C, t.D} {
+		if !isFinite(f) || f < -maxTransformScale || f > maxTransformScale {
+			return fmt.Errorf("transform scales too much")
+		}
+	}
+	for _, f := range []float32{t.E, t.F} {
+		if !isFinite(f) || f < -limits.MaxCoordinate || f > limits.MaxCoordinate {
+			return fmt.Errorf("transform moves out of bounds")
+		}
+	}
+	if t.Scale() < 1e-3 {
+		return fmt.Errorf("transform collapses the path")
+	}
+	return nil
+}
+
+// TransformPath returns p moved by t on top of its current transform. Text
+// elements get new points instead, as text is always drawn upright.
+func TransformPath(p Path, t Transform) Path {
+	if p.Text != nil {
+		return transformText(p, t)
+	}
+	prev := Identity()
+	if p.Transform != nil {
+		prev = *p.Transform
+	}
+	next := prev.Then(t)
+	p.Transform = &next
+	return p
+}
+
+// transformText moves and scales the box of a text element by t, and its
+// text along with it; rotating only moves it.
+func transformText(p Path, t Transform) Path {
+	pos, size := p.TextBox()
+	corners := t.applyAll([]fyne.Position{pos, pos.AddXY(size.Width, 0), pos.AddXY(0, size.Height), pos.AddXY(size.Width, size.Height)})
+	lo, hi := corners[0], corners[0]
+	for _, c := range corners[1:] {
+		lo = fyne.NewPos(min(lo.X, c.X), min(lo.Y, c.Y))
+		hi = fyne.NewPos(max(hi.X, c.X), max(hi.Y, c.Y))
+	}
+	text := *p.Text
+	text.Size = min(max(text.Size*t.Scale(), MinTextSize), MaxTextSize)
+	p.Text = &text
+	p.Points = []fyne.Position{lo, hi}
+	return p
+}
+
+// TransformLocal applies t on top of the current placement of each visible
+// path in ids, on behalf of ownerID, and returns the operations to be
+// broadcast. Text elements get new points instead of a transform. The whole
+// change undoes at once.
+func (ws *WhiteboardState) TransformLocal(ownerID string, ids []string, t Transform) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{}
+	for _, id := range ids {
+		if !ws.visibleLocked(id) {
+			continue
+		}
+		p := ws.paths[id]
+		moved := TransformPath(p, t)
+		if p.Text != nil {
+			entry.undo = append(entry.undo, edit{typ: OpAdd, path: p})
+			entry.redo = append(entry.redo, edit{typ: OpAdd, path: moved})
+			continue
+		}
+		prev := Identity()
+		if p.Transform != nil {
+			prev = *p.Transform
+		}
+		entry.undo = append(entry.undo, edit{typ: OpTransform, target: id, owner: ownerID, transform: prev})
+		entry.redo = append(entry.redo, edit{typ: OpTransform, target: id, owner: ownerID, transform: *moved.Transform})
+	}
+	ops, change := ws.applyEditsLocked(entry.redo)
+	ws.history.record(entry)
+	if len(ops) > 0 {
+		log.Printf("[CRDT] Local transform of %d paths", len(ops))
+	}
+	return ops, change
+}
+
+// AddLocalPaths adds several paths drawn or pasted by the local user as one
+// action, keeping their IDs, and returns the operations to be broadcast.
+// Unlike edits, a path's Transform is applied along with it.
+func (ws *WhiteboardState) AddLocalPaths(paths []Path) ([]PathOperation, Change) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	entry := historyEntry{}
+	ops := make([]PathOperation, 0, len(paths))
+	var change Change
+	for _, p := range paths {
+		op := ws.newLocalOperation(OpAdd)
+		path := p
+		path.Z = float64(op.Timestamp)
+		op.Path = &path
+		c := ws.applyLocked(op)
+		change.Removed = append(change.Removed, c.Removed...)
+		change.Added = append(change.Added, c.Added...)
+		ops = append(ops, op)
+		entry.undo = append(entry.undo, edit{typ: OpDelete, target: p.ID, owner: p.OwnerID})
+		entry.redo = append(entry.redo, edit{typ: OpAdd, path: p})
+	}
+	ws.history.record(entry)
+	log.Printf("[CRDT] Local paths added: %d", len(paths))
+	return ops, change
+}
+
+// setTransformLocked records t as the transform of a path if s is newer than
+// the one it has. Callers must hold ws.mu.
+func (ws *WhiteboardState) setTransformLocked(id string, t Transform, s stamp) bool {
+	if prev, ok := ws.placed[id]; ok && !s.after(prev) {
+		return false
+	}
+	ws.transforms[id] = t
+	ws.placed[id] = s
+	return true
+}
+
+// placedLocked returns p with its latest transform, on the layer it was last
+// put on and at its latest height. Callers must hold ws.mu.
+func (ws *WhiteboardState) placedLocked(p Path) Path {
+	p.Transform = nil
+	if t, ok := ws.transforms[p.ID]; ok && t != Identity() {
+		p.Transform = &t
+	}
+	p.Layer = ws.layerOf[p.ID]
+	p.Z = ws.heights[p.ID]
+	return p
+}
@@ -0,0 +1,182 @@
+package state
+
+import (
+	"fmt"
+	"math"
+)
+
+// Limits bound what a peer may put on the board
+type Limits struct {
+	MaxPointsPerPath int
+	MaxCoordinate    float32 // Largest absolute X or Y
+	MaxStroke        float32
+	MaxClockLead     int64 // How far ahead of our clock an operation may be stamped
+}
+
+// DefaultLimits are enforced by the host on everything clients send
+var DefaultLimits = Limits{
+	MaxPointsPerPath: 10000,
+	MaxCoordinate:    1000000,
+	MaxStroke:        200,
+	MaxClockLead:     1 << 20,
+}
+
+// IsValidColor reports whether c is a colour paths may be drawn with
+func IsValidColor(c string) bool {
+	_, err := ParseColor(c)
+	return err == nil
+}
+
+func isFinite(f float32) bool {
+	return !math.IsNaN(float64(f)) && !math.IsInf(float64(f), 0)
+}
+
+// CheckTimestamp rejects an operation stamped further ahead of our clock than
+// limits allow. Applying one would pull our clock up to its stamp, and it
+// would win every last-writer-wins decision from then on.
+func (ws *WhiteboardState) CheckTimestamp(op PathOperation, limits Limits) error {
+	if op.Timestamp-ws.clock.Now() > limits.MaxClockLead {
+		return fmt.Errorf("operation %s is stamped too far ahead of our clock", op.ID)
+	}
+	return nil
+}
+
+// ValidateOperation checks that an operation is well formed and within limits.
+// It does not check who is allowed to send it.
+func ValidateOperation(op PathOperation, limits Limits) error {
+	if op.SiteID == "" || op.Seq <= 0 || op.ID != operationID(op.SiteID, op.Seq) {
+		return fmt.Errorf("malformed operation ID %q", op.ID)
+	}
+	if op.Timestamp <= 0 {
+		return fmt.Errorf("operation %s has no timestamp", op.ID)
+	}
+
+	switch op.Type {
+	case OpAdd:
+		if op.Path == nil {
+			return fmt.Errorf("add operation %s has no path", op.ID)
+		}
+		return ValidatePath(*op.Path, limits)
+	case OpClear:
+		if op.OwnerID == "" {
+			return fmt.Errorf("clear operation %s has no owner", op.ID)
+		}
+		return nil
+	case OpDelete:
+		if op.Target == "" || op.OwnerID == "" {
+			return fmt.Errorf("delete operation %s needs a target and an owner", op.ID)
+		}
+		return nil
+	case OpTransform:
+		if op.Target == "" || op.OwnerID == "" || op.Transform == nil {
+			return fmt.Errorf("transform operation %s needs a target, an owner and a transform", op.ID)
+		}
+		if err := validateTransform(*op.Transform, limits); err != nil {
+			return fmt.Errorf("operation %s: %w", op.ID, err)
+		}
+		return nil
+	case OpSetLayer:
+		if op.Target == "" || op.OwnerID == "" || op.LayerID == "" || len(op.LayerID) > MaxLayerID {
+			return fmt.Errorf("set_layer operation %s needs a target, an owner and a layer", op.ID)
+		}
+		return nil
+	case OpRestack:
+		if op.Target == "" || op.OwnerID == "" || math.IsNaN(op.Z) || math.IsInf(op.Z, 0) {
+			return fmt.Errorf("restack operation %s needs a target, an owner and a height", op.ID)
+		}
+		return nil
+	case OpFrame:
+		if op.Frame == nil || op.OwnerID == "" {
+			return fmt.Errorf("frame operation %s needs a frame and an owner", op.ID)
+		}
+		if err := validateFrame(*op.Frame, limits); err != nil {
+			return fmt.Errorf("operation %s: %w", op.ID, err)
+		}
+		return nil
+	case OpLayer:
+		if op.Layer == nil || op.OwnerID == "" {
+			return fmt.Errorf("layer operation %s needs a layer and an owner", op.ID)
+		}
+		if err := validateLayer(*op.Layer); err != nil {
+			return fmt.Errorf("operation %s: %w", op.ID, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation type %q", op.Type)
+	}
+}
+
+// ValidatePath checks a path against limits
+func ValidatePath(p Path, limits Limits) error {
+	if p.ID == "" {
+		return fmt.Errorf("path has no ID")
+	}
+	if len(p.Points) == 0 {
+		return fmt.Errorf("path %s has no points", p.ID)
+	}
+	if len(p.Points) > limits.MaxPointsPerPath {
+		return fmt.Errorf("path %s has %d points, the limit is %d", p.ID, len(p.Points), limits.MaxPointsPerPath)
+	}
+	if p.Shape != nil {
+		if err := validateShape(p, limits); err != nil {
+			return err
+		}
+	}
+	if p.Text != nil {
+		if err := validateText(p); err != nil {
+			return err
+		}
+	}
+	if p.Image != nil {
+		if err := validateImage(p); err != nil {
+			return err
+		}
+	}
+	if len(p.Layer) > MaxLayerID {
+		return fmt.Errorf("path %s has an invalid layer", p.ID)
+	}
+	if math.IsNaN(p.Z) || math.IsInf(p.Z, 0) {
+		return fmt.Errorf("path %s has an invalid height", p.ID)
+	}
+	if p.Transform != nil {
+		if p.Text != nil {
+			return fmt.Errorf("text %s cannot be transformed", p.ID)
+		}
+		if err := validateTransform(*p.Transform, limits); err != nil {
+			return fmt.Errorf("path %s: %w", p.ID, err)
+		}
+	}
+	if p.Bezier && (len(p.Points) < 4 || (len(p.Points)-1)%3 != 0) {
+		return fmt.Errorf("path %s has %d points, which are not whole Bézier segments", p.ID, len(p.Points))
+	}
+	if len(p.Widths) > 0 && len(p.Widths) != p.widthPoints() {
+		return fmt.Errorf("path %s has %d widths for %d points", p.ID, len(p.Widths), p.widthPoints())
+	}
+	for _, w := range p.Widths {
+		if !isFinite(w) || w <= 0 || w > limits.MaxStroke {
+			return fmt.Errorf("path %s has a width out of bounds", p.ID)
+		}
+	}
+	for _, pt := range p.Points {
+		if !isFinite(pt.X) || !isFinite(pt.Y) ||
+			pt.X < -limits.MaxCoordinate || pt.X > limits.MaxCoordinate ||
+			pt.Y < -limits.MaxCoordinate || pt.Y > limits.MaxCoordinate {
+			return fmt.Errorf("path %s has a point out of bounds", p.ID)
+		}
+	}
+	if !isFinite(p.Stroke) || p.Stroke <= 0 || p.Stroke > limits.MaxStroke {
+		return fmt.Errorf("path %s has invalid stroke %v", p.ID, p.Stroke)
+	}
+	if !IsValidColor(p.Color) {
+		return fmt.Errorf("path %s has unknown colour %q", p.ID, p.Color)
+	}
+	return nil
+}
+
+// OperationOwner returns the user an operation acts for
+func OperationOwner(op PathOperation) string {
+	if op.Type == OpAdd && op.Path != nil {
+		return op.Path.OwnerID
+	}
+	return op.OwnerID
+}
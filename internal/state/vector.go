@@ -0,0 +1,62 @@
+package state
+
+// StateVector records, per site, the highest sequence number up to which every
+// operation from that site has been seen. Peers exchange state vectors so that
+// each side only ships the operations the other one is missing.
+type StateVector map[string]int64
+
+// advanceVector moves the vector entry for siteID past every contiguous
+// operation we hold. Callers must hold ws.mu.
+func (ws *WhiteboardState) advanceVector(siteID string) {
+	next := ws.vector[siteID] + 1
+	for {
+		if _, ok := ws.operations[operationID(siteID, next)]; !ok {
+			break
+		}
+		ws.vector[siteID] = next
+		next++
+	}
+}
+
+// StateVector returns a copy of the state vector of this whiteboard.
+func (ws *WhiteboardState) StateVector() StateVector {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	sv := make(StateVector, len(ws.vector))
+	for site, seq := range ws.vector {
+		sv[site] = seq
+	}
+	return sv
+}
+
+// MissingOperations returns the operations a peer with state vector sv has not
+// seen, in causal order.
+func (ws *WhiteboardState) MissingOperations(sv StateVector) []PathOperation {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	missing := make([]PathOperation, 0)
+	for _, op := range ws.operations {
+		if op.Seq > sv[op.SiteID] {
+			missing = append(missing, op)
+		}
+	}
+	sortOperations(missing)
+	return missing
+}
+
+// MissingLocalOperations is like MissingOperations but only returns the
+// operations created on this site.
+func (ws *WhiteboardState) MissingLocalOperations(sv StateVector) []PathOperation {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	missing := make([]PathOperation, 0)
+	for seq := sv[ws.siteID] + 1; seq <= ws.seq; seq++ {
+		if op, ok := ws.operations[operationID(ws.siteID, seq)]; ok {
+			missing = append(missing, op)
+		}
+	}
+	return missing
+}
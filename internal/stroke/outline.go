@@ -0,0 +1,77 @@
+package stroke
+
+import (
+	"math"
+	"slices"
+
+	"fyne.io/fyne/v2"
+)
+
+// Pressure simulated from drawing speed: fast strokes thin out to
+// minPressure, reached at fastSpeed pixels per second.
+const (
+	minPressure float32 = 0.4
+	fastSpeed   float32 = 2500
+)
+
+// SpeedPressure returns the pressure a pen moving at speed, in pixels per
+// second, is taken to have, from minPressure up to 1.
+func SpeedPressure(speed float32) float32 {
+	return max(minPressure, 1-(1-minPressure)*min(speed/fastSpeed, 1))
+}
+
+// capSegments is how many edges approximate a round cap or joint
+const capSegments = 12
+
+// Outline returns the filled shape of a stroke through points, with the
+// given width at each point, as polygons: a disc at every point and a quad
+// joining each pair of neighbours. All polygons wind the same way, so a
+// nonzero fill draws their union in one go without doubled up coverage.
+func Outline(points []fyne.Position, widths []float32) [][]fyne.Position {
+	polygons := make([][]fyne.Position, 0, 2*len(points))
+	EachOutlinePolygon(points, widths, func(polygon []fyne.Position) {
+		polygons = append(polygons, slices.Clone(polygon))
+	})
+	return polygons
+}
+
+// EachOutlinePolygon calls fn with each polygon of Outline. The slice is
+// reused between calls, which keeps drawing many strokes free of garbage.
+func EachOutlinePolygon(points []fyne.Position, widths []float32, fn func(polygon []fyne.Position)) {
+	buf := make([]fyne.Position, capSegments)
+	for i, c := range points {
+		r := widths[i] / 2
+		for k := range buf {
+			buf[k] = fyne.NewPos(c.X+r*unitCircle[k].X, c.Y+r*unitCircle[k].Y)
+		}
+		fn(buf)
+	}
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		ra, rb := widths[i]/2, widths[i+1]/2
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := float32(math.Hypot(float64(dx), float64(dy)))
+		if length == 0 {
+			continue
+		}
+		// The quad winds like the discs: clockwise on screen
+		nx, ny := -dy/length, dx/length
+		fn(append(buf[:0],
+			fyne.Position{X: a.X - nx*ra, Y: a.Y - ny*ra},
+			fyne.Position{X: b.X - nx*rb, Y: b.Y - ny*rb},
+			fyne.Position{X: b.X + nx*rb, Y: b.Y + ny*rb},
+			fyne.Position{X: a.X + nx*ra, Y: a.Y + ny*ra},
+		))
+		buf = buf[:capSegments]
+	}
+}
+
+// unitCircle holds the corners of a disc of radius 1
+var unitCircle = func() [capSegments]fyne.Position {
+	var pts [capSegments]fyne.Position
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / capSegments
+		pts[i] = fyne.NewPos(float32(math.Cos(a)), float32(math.Sin(a)))
+	}
+	return pts
+}()
@@ -0,0 +1,209 @@
+// Package stroke turns raw pointer input into clean strokes: it smooths
+// points while drawing, simplifies the finished stroke and can fit it with
+// cubic Béziers.
+package stroke
+
+import (
+	"math"
+
+	"fyne.io/fyne/v2"
+)
+
+// Options configure the input pipeline
+type Options struct {
+	Smoothing int     // Raw points averaged per drawn point, 1 to turn smoothing off
+	Tolerance float32 // Largest distance simplification may move the stroke, in pixels
+	Bezier    bool    // Store finished strokes as cubic Bézier segments
+	Pressure  bool    // Vary the width with drawing speed, as pen pressure would
+}
+
+// DefaultOptions suit handwriting with a mouse or touchpad. Strokes are kept
+// as the points they are drawn through; Bézier fitting is left to turn on.
+func DefaultOptions() Options {
+	return Options{Smoothing: 4, Tolerance: 0.75, Pressure: true}
+}
+
+// Smoother averages the last few raw positions, taking the jitter out of
+// pointer input while the stroke is drawn.
+type Smoother struct {
+	window int
+	recent []fyne.Position
+}
+
+// NewSmoother creates a smoother averaging over window points
+func NewSmoother(window int) *Smoother {
+	return &Smoother{window: max(window, 1)}
+}
+
+// Add takes a raw position and returns the smoothed one
+func (s *Smoother) Add(p fyne.Position) fyne.Position {
+	s.recent = append(s.recent, p)
+	if len(s.recent) > s.window {
+		s.recent = s.recent[1:]
+	}
+	var sum fyne.Position
+	for _, r := range s.recent {
+		sum = sum.Add(r)
+	}
+	n := float32(len(s.recent))
+	return fyne.NewPos(sum.X/n, sum.Y/n)
+}
+
+func distance(a, b fyne.Position) float32 {
+	return float32(math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)))
+}
+
+// distanceToLine returns the distance from p to the segment a-b
+func distanceToLine(p, a, b fyne.Position) float32 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	lengthSq := dx*dx + dy*dy
+	if lengthSq == 0 {
+		return distance(p, a)
+	}
+	t := max(0, min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/lengthSq))
+	return distance(p, fyne.NewPos(a.X+t*dx, a.Y+t*dy))
+}
+
+// Simplify drops points with Ramer–Douglas–Peucker, so that the result never
+// strays more than tolerance from the original. The ends are always kept.
+func Simplify(points []fyne.Position, tolerance float32) []fyne.Position {
+	return pick(points, SimplifyIndices(points, tolerance))
+}
+
+// SimplifyIndices is Simplify, returning the indices of the points it keeps
+func SimplifyIndices(points []fyne.Position, tolerance float32) []int {
+	if len(points) < 3 || tolerance <= 0 {
+		indices := make([]int, len(points))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+
+	// Iterative, as long strokes would recurse deeply
+	type span struct{ from, to int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		farthest, farDist := -1, tolerance
+		for i := s.from + 1; i < s.to; i++ {
+			if d := distanceToLine(points[i], points[s.from], points[s.to]); d > farDist {
+				farthest, farDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, span{s.from, farthest}, span{farthest, s.to})
+		}
+	}
+
+	indices := make([]int, 0, len(points)/4+2)
+	for i := range points {
+		if keep[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// pick returns the elements of s at indices
+func pick[T any](s []T, indices []int) []T {
+	if len(s) == 0 {
+		return s
+	}
+	picked := make([]T, len(indices))
+	for i, idx := range indices {
+		picked[i] = s[idx]
+	}
+	return picked
+}
+
+// FitBeziers turns a polyline into cubic Bézier segments through its points,
+// with Catmull-Rom tangents. The result holds the start point followed by two
+// control points and an end point per segment, so 3n+1 points for n segments.
+func FitBeziers(points []fyne.Position) []fyne.Position {
+	if len(points) < 3 {
+		return points
+	}
+	curves := make([]fyne.Position, 0, 3*len(points)-2)
+	curves = append(curves, points[0])
+	last := len(points) - 1
+	for i := 0; i < last; i++ {
+		prev, p0, p1, next := points[max(i-1, 0)], points[i], points[i+1], points[min(i+2, last)]
+		c1 := fyne.NewPos(p0.X+(p1.X-prev.X)/6, p0.Y+(p1.Y-prev.Y)/6)
+		c2 := fyne.NewPos(p1.X-(next.X-p0.X)/6, p1.Y-(next.Y-p0.Y)/6)
+		curves = append(curves, c1, c2, p1)
+	}
+	return curves
+}
+
+// flattenStep is the length of the lines curves are drawn with, in board units
+const flattenStep float32 = 4
+
+// maxFlattenSteps bounds the lines a single curve segment is drawn with
+const maxFlattenSteps = 32
+
+// flattenSteps returns how many lines the curve segment starting at
+// curves[i] is drawn with
+func flattenSteps(curves []fyne.Position, i int) int {
+	p0, c1, c2, p1 := curves[i], curves[i+1], curves[i+2], curves[i+3]
+	length := distance(p0, c1) + distance(c1, c2) + distance(c2, p1)
+	return max(1, min(maxFlattenSteps, int(length/flattenStep)))
+}
+
+// Flatten turns Bézier segments as made by FitBeziers back into a polyline
+func Flatten(curves []fyne.Position) []fyne.Position {
+	if len(curves) < 4 {
+		return curves
+	}
+	points := []fyne.Position{curves[0]}
+	for i := 0; i+3 < len(curves); i += 3 {
+		p0, c1, c2, p1 := curves[i], curves[i+1], curves[i+2], curves[i+3]
+		steps := flattenSteps(curves, i)
+		for k := 1; k <= steps; k++ {
+			t := float32(k) / float32(steps)
+			u := 1 - t
+			a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+			points = append(points, fyne.NewPos(
+				a*p0.X+b*c1.X+c*c2.X+d*p1.X,
+				a*p0.Y+b*c1.Y+c*c2.Y+d*p1.Y,
+			))
+		}
+	}
+	return points
+}
+
+// FlattenWidths returns the widths along the polyline Flatten makes of
+// curves, given a width per curve end point. Widths change linearly along
+// each segment.
+func FlattenWidths(curves []fyne.Position, widths []float32) []float32 {
+	if len(widths) == 0 || len(curves) < 4 {
+		return widths
+	}
+	flat := []float32{widths[0]}
+	for i := 0; i+3 < len(curves); i += 3 {
+		w0, w1 := widths[i/3], widths[min(i/3+1, len(widths)-1)]
+		steps := flattenSteps(curves, i)
+		for k := 1; k <= steps; k++ {
+			t := float32(k) / float32(steps)
+			flat = append(flat, w0+(w1-w0)*t)
+		}
+	}
+	return flat
+}
+
+// Finish simplifies a finished stroke drawn at the given zoom scale and, if
+// the options ask for it, fits it with Béziers. widths, if any, has one entry
+// per point and is thinned out with them. It reports whether the result is
+// Bézier segments; their widths belong to the curve end points.
+func Finish(points []fyne.Position, widths []float32, opts Options, scale float32) ([]fyne.Position, []float32, bool) {
+	kept := SimplifyIndices(points, opts.Tolerance/max(scale, 1e-6))
+	simplified, widths := pick(points, kept), pick(widths, kept)
+	if !opts.Bezier || len(simplified) < 3 {
+		return simplified, widths, false
+	}
+	return FitBeziers(simplified), widths, true
+}
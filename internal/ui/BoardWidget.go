@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"encoding/json"
 	"image/color"
 	"io"
 	"log"
@@ -10,48 +9,135 @@ import (
 	"fmt"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/assets"
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/stroke"
 )
 
-// Path struct (unchanged)
-type Path struct {
-	ID      string          `json:"id"`
-	OwnerID string          `json:"owner_id"`
-	Points  []fyne.Position `json:"points"`
-	Color   string          `json:"color"`
-	Stroke  float32         `json:"stroke"`
-}
+// Path is the CRDT path, shared with the network and file formats
+type Path = state.Path
 
 type BoardWidget struct {
 	widget.BaseWidget
 	paths           []*Path
+	generation      uint64 // Bumped whenever paths changes, so the renderer knows to resync
+	lastAppend      uint64 // Generation of the last change that only appended a path
+	top             *Path  // Highest stacked path taken in, which may be gone since
+	index           *spatial.Index // Bounding boxes of paths, by path ID
+	byID            map[string]*Path
+	boundsMu        sync.Mutex
+	bounds          contentBox
 	mu              sync.RWMutex
-	currentPath     *Path
-	panX, panY      float32
-	drawing         bool
+	viewport        Viewport
+	tools           []Tool
+	activeTool      Tool
+	toolActive      bool // The active tool is between Pressed and Released
 	currentColor    string
 	currentStroke   float32
+	StrokeOptions   stroke.Options // How pen input is smoothed and simplified
+	SnapToGrid      bool    // Shapes snap to a GridSize grid
+	ShapeFill       bool    // Rectangles and ellipses are filled with a tint of their colour
+	CornerRadius    float32 // Corner radius of new rectangles
+	TextSize        float32 // Size of new text, in board units
+	TextAlign       string  // Alignment of new text
+	editing         *editSession // Text element being edited in place, if any
+	editor          *textEditor
+	locks           map[string]heldLock // Elements others are editing, by ID
+	selection       []string // IDs of the selected paths
+	pastes          int      // Pastes since the last copy, to offset each one
+	pointer         fyne.Position // Last pointer position over the widget
+	pointerIn       bool
 	LocalClientID   string
+	CanLockLayers   bool // Layers can be locked and unlocked, and so the background changed
+	Assets          *assets.Store // Pictures of the image elements
 	OnNewPath       func(p Path)
 	OnClear         func()
+	OnErase         func(trail []fyne.Position, radius float32, partial bool)
+	OnUndo          func()
+	OnRedo          func()
 	OnSave          func() []Path
-	OnLoad          func(paths []Path)
+	OnLoad          func(paths []Path, layers []state.Layer, frames []state.Frame)
+	OnToolChanged   func(t Tool)
+	// OnEditPath is called with each new version of a text element being
+	// edited; continued is set for all but the first of an editing session
+	OnEditPath      func(p Path, continued bool)
+	OnDeletePaths   func(ids []string)
+	OnAddPaths      func(paths []Path)
+	OnTransform     func(ids []string, t state.Transform)
+	OnLockChanged   func(lock EditLock)
+	OnLayerChanged  func(l state.Layer)
+	OnMoveToLayer   func(ids []string, layer string)
+	OnRestack       func(ids []string, front bool)
+	OnFrameChanged  func(f state.Frame)
+	OnDeletePage    func(id string, pathIDs []string)
+	OnPresent       func(presenting bool)
+	OnSummon        func(view ViewRect)
+	// OnViewportChanged is called with the visible part of the board whenever it changes
+	OnViewportChanged func(view ViewRect)
 	statusBar       *widget.Label
+	latencyLabel    *widget.Label
+	zoomLabel       *widget.Label
+	minimap         *Minimap
+	pageNav         *pageNavigator
+	layers          []state.Layer  // Bottom first
+	layerIndex      map[string]int // Place of each layer in layers
+	activeLayer     string         // Layer new paths go on
+	layerList       *widget.List
+	frames          []state.Frame // Pages, in page order
+	frameList       *widget.List
+	hereFrame       string              // Page in view when the lists were last refreshed
+	following       string              // Participant whose page we go to, if any
+	presenter       string              // Participant everyone follows, possibly us
+	detached        bool                // We moved away from the presenter's view
+	steering        bool                // The view is moving by itself, not by our hand
+	glide           *fyne.Animation
+	presentButton   *widget.Button
+	returnButton    *widget.Button
+	remoteViews     map[string]ViewRect // What other participants are looking at
+	participants    []Participant
+	participantList *widget.List
 }
 
 var _ fyne.Widget = (*BoardWidget)(nil)
 var _ fyne.Draggable = (*BoardWidget)(nil)
 var _ desktop.Mouseable = (*BoardWidget)(nil)
+var _ desktop.Cursorable = (*BoardWidget)(nil)
 
 func NewBoardWidget() *BoardWidget {
 	b := &BoardWidget{
 		paths:         make([]*Path, 0),
-		currentColor:  "black",
+		index:         spatial.NewIndex(spatial.DefaultCellSize),
+		byID:          make(map[string]*Path),
+		currentColor:  colorToString(color.Black),
 		currentStroke: 3.0,
+		StrokeOptions: stroke.DefaultOptions(),
 		statusBar:     widget.NewLabel("Ready"),
+		latencyLabel:  widget.NewLabel(""),
+		zoomLabel:     widget.NewLabel("100%"),
+		viewport:      Viewport{Scale: 1},
+		remoteViews:   make(map[string]ViewRect),
+		locks:         make(map[string]heldLock),
+		TextSize:      DefaultTextSize,
+		TextAlign:     state.AlignLeft,
+		tools:         defaultTools(),
+		Assets:        assets.NewStore(assets.DefaultDir()),
+		CanLockLayers: true,
 	}
+	b.Assets.OnAdded = b.assetArrived
+	b.activeTool = b.tools[0]
+	b.participantList = b.newParticipantList()
+	b.presentButton, b.returnButton = b.newPresenterButtons()
+	b.minimap = newMinimap(b)
+	b.pageNav = newPageNavigator(b)
+	b.layers = []state.Layer{state.BaseLayer()}
+	b.layerIndex = map[string]int{state.DefaultLayer: 0}
+	b.activeLayer = state.DefaultLayer
+	b.layerList = b.newLayerList()
+	b.frameList = b.newFrameList()
 	b.ExtendBaseWidget(b)
 	return b
 }
@@ -85,16 +171,22 @@ func (b *BoardWidget) clearPathsByOwner(ownerID string) {
 	
 	if ownerID == "all" {
 		b.paths = make([]*Path, 0)
+		b.index.Clear()
+		clear(b.byID)
 	} else {
 		filteredPaths := make([]*Path, 0)
 		for _, path := range b.paths {
 			if path.OwnerID != ownerID { 
 				filteredPaths = append(filteredPaths, path) 
+			} else {
+				b.unindexPath(path.ID)
 			}
 		}
 		b.paths = filteredPaths
 	}
-	b.Refresh()
+	b.generation++
+	fyne.Do(b.Refresh)
+	b.pathsChanged()
 }
 
 // Thread-safe UI update methods
@@ -102,14 +194,115 @@ func (b *BoardWidget) AddRemotePath(p Path) {
 	b.mu.Lock()
 	pathCopy := p // Make a copy
 	b.paths = append(b.paths, &pathCopy)
+	b.indexPath(&pathCopy)
+	b.raiseTop(&pathCopy)
+	b.generation++
+	b.lastAppend = b.generation
 	b.mu.Unlock()
-	b.Refresh()
+	fyne.Do(b.Refresh)
+	b.pathsChanged()
+}
+
+// UpdatePaths shows new versions of paths in place, and adds the paths not
+// on the board yet on top
+func (b *BoardWidget) UpdatePaths(paths []Path) {
+	if len(paths) == 0 {
+		return
+	}
+	b.mu.Lock()
+	fresh := make(map[string]*Path, len(paths))
+	for _, p := range paths {
+		pathCopy := p
+		fresh[p.ID] = &pathCopy
+	}
+	for i, old := range b.paths {
+		if p, ok := fresh[old.ID]; ok {
+			b.unindexPath(old.ID)
+			b.paths[i] = p
+			b.indexPath(p)
+			b.raiseTop(p)
+			delete(fresh, old.ID)
+		}
+	}
+	appended := 0
+	for _, p := range paths {
+		if pathPtr, ok := fresh[p.ID]; ok {
+			b.paths = append(b.paths, pathPtr)
+			b.indexPath(pathPtr)
+			b.raiseTop(pathPtr)
+			delete(fresh, p.ID)
+			appended++
+		}
+	}
+	b.generation++
+	if appended == 1 && len(paths) == 1 {
+		b.lastAppend = b.generation
+	}
+	b.mu.Unlock()
+	fyne.Do(b.Refresh)
+	b.pathsChanged()
+}
+
+// setPaths replaces every path on the board
+func (b *BoardWidget) setPaths(paths []*Path) {
+	b.mu.Lock()
+	b.paths = paths
+	b.index.Clear()
+	clear(b.byID)
+	b.top = nil
+	for _, p := range paths {
+		b.indexPath(p)
+		b.raiseTop(p)
+	}
+	b.generation++
+	b.mu.Unlock()
+}
+
+// indexPath files p in the spatial index. Callers must hold b.mu.
+func (b *BoardWidget) indexPath(p *Path) {
+	b.byID[p.ID] = p
+	if box, ok := state.PathBounds(*p); ok {
+		b.index.Insert(p.ID, box)
+	}
+}
+
+// unindexPath removes a path from the spatial index. Callers must hold b.mu.
+func (b *BoardWidget) unindexPath(id string) {
+	delete(b.byID, id)
+	b.index.Remove(id)
 }
 
 func (b *BoardWidget) ClearRemote(ownerID string) {
 	b.clearPathsByOwner(ownerID)
 }
 
+// RemovePaths removes the paths with the given IDs
+func (b *BoardWidget) RemovePaths(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	b.mu.Lock()
+	filteredPaths := make([]*Path, 0, len(b.paths))
+	for _, path := range b.paths {
+		if !remove[path.ID] {
+			filteredPaths = append(filteredPaths, path)
+		}
+	}
+	for _, id := range ids {
+		b.unindexPath(id)
+	}
+	b.paths = filteredPaths
+	b.generation++
+	b.mu.Unlock()
+	fyne.Do(b.Refresh)
+	b.pathsChanged()
+}
+
 func (b *BoardWidget) SetStatus(text string) {
 	// Use a goroutine to safely update status from any thread
 	go func() {
@@ -124,6 +317,20 @@ func (b *BoardWidget) ClearPaths() {
 	}
 }
 
+// Undo reverts the local user's last action
+func (b *BoardWidget) Undo() {
+	if b.OnUndo != nil {
+		b.OnUndo()
+	}
+}
+
+// Redo reapplies the local user's last undone action
+func (b *BoardWidget) Redo() {
+	if b.OnRedo != nil {
+		b.OnRedo()
+	}
+}
+
 func (b *BoardWidget) SaveToFile(writer fyne.URIWriteCloser) {
 	defer func() {
 		if err := writer.Close(); err != nil {
@@ -142,14 +349,7 @@ func (b *BoardWidget) SaveToFile(writer fyne.URIWriteCloser) {
 	pathsToSave := b.OnSave()
 	log.Printf("SaveToFile: Got %d paths to save", len(pathsToSave))
 	
-	jsonData, err := json.MarshalIndent(pathsToSave, "", "  ")
-	if err != nil { 
-		log.Printf("SaveToFile: Error marshaling: %v", err)
-		b.SetStatus("Error saving file")
-		return 
-	}
-	
-	if _, err := writer.Write(jsonData); err != nil { 
+	if err := writeBoardFile(writer, pathsToSave, b.Layers(), b.Frames(), b.Assets); err != nil { 
 		log.Printf("SaveToFile: Error writing: %v", err)
 		b.SetStatus("Error writing file")
 	} else {
@@ -184,27 +384,38 @@ func (b *BoardWidget) LoadFromFile(reader fyne.URIReadCloser) {
 	
 	log.Printf("LoadFromFile: Read %d bytes from file", len(jsonData))
 	
-	// Parse JSON
-	var loadedPaths []Path
-	if err := json.Unmarshal(jsonData, &loadedPaths); err != nil { 
-		log.Printf("LoadFromFile: Error unmarshaling JSON: %v", err)
+	// Parse the paths, and take in the pictures of images
+	loadedPaths, loadedLayers, loadedFrames, err := readBoardFile(jsonData, b.Assets)
+	if err != nil { 
+		log.Printf("LoadFromFile: Error parsing file: %v", err)
 		b.SetStatus("Error parsing file - invalid format")
 		return 
 	}
 	
 	log.Printf("LoadFromFile: Successfully parsed %d paths from file", len(loadedPaths))
 	
+	// Older files use colour names; store everything as hex from now on
+	for i := range loadedPaths {
+		if c, err := state.ParseColor(loadedPaths[i].Color); err == nil {
+			loadedPaths[i].Color = state.ColorToHex(c)
+		}
+	}
+	
 	// Clear current paths and add loaded ones
-	b.mu.Lock()
-	b.paths = make([]*Path, 0, len(loadedPaths))
+	paths := make([]*Path, 0, len(loadedPaths))
 	for _, path := range loadedPaths {
 		pathCopy := path
-		b.paths = append(b.paths, &pathCopy)
+		paths = append(paths, &pathCopy)
 	}
-	b.mu.Unlock()
+	b.setPaths(paths)
+	if loadedLayers != nil {
+		b.SetLayers(loadedLayers)
+	}
+	b.SetFrames(loadedFrames)
 	
 	// Refresh the UI
 	b.Refresh()
+	b.pathsChanged()
 	
 	// Update status
 	b.SetStatus(fmt.Sprintf("Loaded %d drawings", len(loadedPaths)))
@@ -212,21 +423,13 @@ func (b *BoardWidget) LoadFromFile(reader fyne.URIReadCloser) {
 	
 	// Call network sync callback if needed
 	if b.OnLoad != nil {
-		b.OnLoad(loadedPaths)
+		b.OnLoad(loadedPaths, loadedLayers, loadedFrames)
 	}
 }
 
-// Convert color.Color to string representation
+// Convert color.Color to the "#RRGGBBAA" form paths are stored with
 func colorToString(c color.Color) string {
-	r, g, b, _ := c.RGBA()
-	if r == 65535 && g == 0 && b == 0 {
-		return "red"
-	} else if r == 0 && g == 0 && b == 65535 {
-		return "blue"
-	} else if r == 0 && g == 65535 && b == 0 {
-		return "green"
-	}
-	return "black"
+	return state.ColorToHex(c)
 }
 
 func (b *BoardWidget) SetColor(c color.Color) { 
@@ -237,113 +440,108 @@ func (b *BoardWidget) SetStroke(s float32) {
 	b.currentStroke = s 
 }
 
+// RegisterTool adds a tool to the board, replacing any tool of the same name
+func (b *BoardWidget) RegisterTool(t Tool) {
+	for i, existing := range b.tools {
+		if existing.Name() == t.Name() {
+			b.tools[i] = t
+			return
+		}
+	}
+	b.tools = append(b.tools, t)
+}
+
+// Tools returns the registered tools in the order they were added
+func (b *BoardWidget) Tools() []Tool {
+	return b.tools
+}
+
+// ActiveTool returns the tool handling the pointer
+func (b *BoardWidget) ActiveTool() Tool {
+	return b.activeTool
+}
+
+// SetTool activates the tool with the given name
+func (b *BoardWidget) SetTool(name string) {
+	for _, t := range b.tools {
+		if t.Name() != name {
+			continue
+		}
+		if b.toolActive {
+			b.activeTool.Released(b)
+			b.toolActive = false
+		}
+		b.FinishEditing()
+		if _, selecting := t.(*selectTool); !selecting {
+			b.selection = nil
+		}
+		b.activeTool = t
+		if b.OnToolChanged != nil {
+			b.OnToolChanged(t)
+		}
+		b.Refresh()
+		return
+	}
+}
+
+// TypedKey deletes or drops the selection on Delete or Escape, and otherwise
+// selects the tool whose shortcut is the key, if any
+func (b *BoardWidget) TypedKey(e *fyne.KeyEvent) {
+	switch e.Name {
+	case fyne.KeyDelete, fyne.KeyBackspace:
+		b.DeleteSelection()
+		return
+	case fyne.KeyEscape:
+		b.selection = nil
+		b.Refresh()
+		return
+	}
+	for _, t := range b.tools {
+		if t.Shortcut() == e.Name {
+			b.SetTool(t.Name())
+			return
+		}
+	}
+}
+
+// Cursor shows the active tool's cursor over the board
+func (b *BoardWidget) Cursor() desktop.Cursor {
+	return b.activeTool.Cursor()
+}
+
 func (b *BoardWidget) MouseDown(e *desktop.MouseEvent) {
 	if e.Button == desktop.MouseButtonPrimary {
-		b.drawing = true
-		adjustedPos := fyne.NewPos(e.Position.X-b.panX, e.Position.Y-b.panY)
-		b.currentPath = &Path{
-			ID:      generateID(),
-			OwnerID: b.LocalClientID,
-			Points:  []fyne.Position{adjustedPos},
-			Color:   b.currentColor,
-			Stroke:  b.currentStroke,
-		}
+		b.toolActive = true
+		b.activeTool.Pressed(b, b.toBoard(e.Position))
 		b.Refresh()
 	}
 }
 
 func (b *BoardWidget) MouseUp(e *desktop.MouseEvent) {
-	if e.Button == desktop.MouseButtonPrimary && b.drawing {
-		b.drawing = false
-		if b.currentPath != nil && len(b.currentPath.Points) > 1 {
-			if b.OnNewPath != nil { 
-				b.OnNewPath(*b.currentPath) 
-			}
-		}
-		b.currentPath = nil
+	if e.Button == desktop.MouseButtonPrimary && b.toolActive {
+		b.toolActive = false
+		b.activeTool.Released(b)
 		b.Refresh()
 	}
 }
 
 func (b *BoardWidget) Dragged(e *fyne.DragEvent) {
-	if b.drawing && b.currentPath != nil {
-		adjustedPos := fyne.NewPos(e.Position.X-b.panX, e.Position.Y-b.panY)
-		b.currentPath.Points = append(b.currentPath.Points, adjustedPos)
-		b.Refresh()
-	} else if !b.drawing {
-		b.panX += e.Dragged.DX
-		b.panY += e.Dragged.DY
+	if b.toolActive {
+		b.activeTool.Dragged(b, b.toBoard(e.Position), e.Dragged)
 		b.Refresh()
 	}
 }
 
-func (b *BoardWidget) CreateRenderer() fyne.WidgetRenderer {
-	r := &boardWidgetRenderer{board: b}
-	r.background = canvas.NewRectangle(color.White)
-	return r
-}
-
-type boardWidgetRenderer struct { 
-	board      *BoardWidget
-	background *canvas.Rectangle 
-}
-
-func (r *boardWidgetRenderer) Objects() []fyne.CanvasObject {
-    r.board.mu.RLock()
-    defer r.board.mu.RUnlock()
-    
-    objects := []fyne.CanvasObject{r.background}
-    pathsToRender := make([]*Path, len(r.board.paths))
-    copy(pathsToRender, r.board.paths)
-    
-    if r.board.drawing && r.board.currentPath != nil { 
-    	pathsToRender = append(pathsToRender, r.board.currentPath) 
-    }
-    
-    for _, p := range pathsToRender {
-        if p == nil {
-            continue
-        }
-        
-        var pathColor color.Color = color.Black
-        if p.Color == "red" { 
-        	pathColor = color.RGBA{R: 255, A: 255}
-        } else if p.Color == "blue" { 
-        	pathColor = color.RGBA{B: 255, A: 255}
-        } else if p.Color == "green" { 
-        	pathColor = color.RGBA{G: 255, A: 255} 
-        }
-        
-        if len(p.Points) > 1 {
-            for i := 0; i < len(p.Points)-1; i++ {
-                segment := canvas.NewLine(pathColor)
-                segment.StrokeWidth = p.Stroke
-                segment.Position1 = fyne.NewPos(p.Points[i].X+r.board.panX, p.Points[i].Y+r.board.panY)
-                segment.Position2 = fyne.NewPos(p.Points[i+1].X+r.board.panX, p.Points[i+1].Y+r.board.panY)
-                objects = append(objects, segment)
-            }
-        }
-    }
-    return objects
-}
-
-func (r *boardWidgetRenderer) Refresh() { 
-	canvas.Refresh(r.board) 
-}
-
-func (b *BoardWidget) MouseIn(*desktop.MouseEvent) {}
-func (b *BoardWidget) MouseOut() {}
-func (b *BoardWidget) MouseMoved(*desktop.MouseEvent) {}
+func (b *BoardWidget) MouseIn(e *desktop.MouseEvent) {
+	b.pointer, b.pointerIn = e.Position, true
+}
+
+func (b *BoardWidget) MouseOut() {
+	b.pointerIn = false
+}
+
+// MouseMoved remembers where the pointer is, which is where pasted text goes
+func (b *BoardWidget) MouseMoved(e *desktop.MouseEvent) {
+	b.pointer = e.Position
+}
 func (b *BoardWidget) DragEnd() {}
-func (r *boardWidgetRenderer) Destroy() {}
-func (r *boardWidgetRenderer) Layout(size fyne.Size) { 
-	r.background.Resize(size) 
-}
-func (r *boardWidgetRenderer) MinSize() fyne.Size { 
-	return fyne.NewSize(300, 300) 
-}
-func (b *BoardWidget) Scrolled(e *fyne.ScrollEvent) { 
-	b.panX += e.Scrolled.DX
-	b.panY += e.Scrolled.DY
-	b.Refresh() 
-}
\ No newline at end of file
@@ -1,15 +1,14 @@
 package ui
 
 import (
-	"image/color"
 	"log"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/storage"
-	"fyne.io/fyne/v2/widget"
 )
 
 func RunApp(shareLink string, board *BoardWidget) {
@@ -24,67 +23,86 @@ func RunApp(shareLink string, board *BoardWidget) {
 	}
 	
 	content := container.NewBorder(
-		createToolbar(board, window),
-		board.statusBar,
-		nil, nil,
-		board,
+		NewToolbar(board, window),
+		board.statusArea(),
+		nil,
+		container.NewVSplit(board.participantsPanel(), container.NewVSplit(board.pagesPanel(window), board.layersPanel(window))),
+		container.NewStack(board, board.minimapOverlay(), board.presenterOverlay()),
 	)
 
 	window.SetContent(content)
+	window.SetOnDropped(board.DropFiles)
+	addShortcuts(board, window)
 	log.Println("Starting Fyne UI...")
 	window.ShowAndRun()
 }
 
-func createToolbar(board *BoardWidget, window fyne.Window) *fyne.Container {
-	saveBtn := widget.NewButton("Save", func() {
-		log.Println("Save button clicked")
-		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
-			if writer == nil || err != nil { 
-				log.Printf("Save dialog cancelled or error: %v", err)
-				return 
-			}
-			log.Printf("Saving to file: %s", writer.URI().String())
-			board.SaveToFile(writer)
-		}, window)
-		saveDialog.SetFileName("mysession.board")
-		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".board"}))
-		saveDialog.Show()
+// addShortcuts registers the board's keyboard shortcuts on window
+func addShortcuts(board *BoardWidget, window fyne.Window) {
+	window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+		board.Undo()
 	})
-	
-	loadBtn := widget.NewButton("Load", func() {
-		log.Println("Load button clicked")
-		loadDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
-			if reader == nil || err != nil { 
-				log.Printf("Load dialog cancelled or error: %v", err)
-				return 
-			}
-			log.Printf("Loading from file: %s", reader.URI().String())
-			
-			// Critical fix: Run the load operation in a separate goroutine
-			// to prevent blocking the UI thread
-			go func() {
-				board.LoadFromFile(reader)
-			}()
-		}, window)
-		loadDialog.SetFilter(storage.NewExtensionFileFilter([]string{".board"}))
-		loadDialog.Show()
+	window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierShortcutDefault | fyne.KeyModifierShift}, func(fyne.Shortcut) {
+		board.Redo()
 	})
-	
-	return container.NewHBox(
-		widget.NewLabel("Colors:"),
-		widget.NewButton("Black", func() { board.SetColor(color.Black) }),
-		widget.NewButton("Red", func() { board.SetColor(color.RGBA{R: 255, A: 255}) }),
-		widget.NewButton("Blue", func() { board.SetColor(color.RGBA{B: 255, A: 255}) }),
-		widget.NewButton("Green", func() { board.SetColor(color.RGBA{G: 255, A: 255}) }),
-		widget.NewSeparator(),
-		widget.NewLabel("Stroke:"),
-		widget.NewButton("Thin", func() { board.SetStroke(1.0) }),
-		widget.NewButton("Medium", func() { board.SetStroke(3.0) }),
-		widget.NewButton("Thick", func() { board.SetStroke(6.0) }),
-		widget.NewSeparator(),
-		widget.NewButton("Clear My Drawings", func() { board.ClearPaths() }),
-		widget.NewSeparator(),
-		saveBtn,
-		loadBtn,
-	)
-}
\ No newline at end of file
+	for key, zoom := range map[fyne.KeyName]func(){
+		fyne.KeyEqual: board.ZoomIn,
+		fyne.KeyMinus: board.ZoomOut,
+		fyne.Key0:     board.ResetZoom,
+		fyne.Key1:     board.ZoomToFit,
+	} {
+		window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: key, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+			zoom()
+		})
+	}
+	for key, action := range map[fyne.KeyName]func(){
+		fyne.KeyC:            board.CopySelection,
+		fyne.KeyX:            board.CutSelection,
+		fyne.KeyV:            board.Paste,
+		fyne.KeyD:            board.DuplicateSelection,
+		fyne.KeyRightBracket: board.BringToFront,
+		fyne.KeyLeftBracket:  board.SendToBack,
+	} {
+		window.Canvas().AddShortcut(&desktop.CustomShortcut{KeyName: key, Modifier: fyne.KeyModifierShortcutDefault}, func(fyne.Shortcut) {
+			action()
+		})
+	}
+	// Plain keys select tools
+	window.Canvas().SetOnTypedKey(board.TypedKey)
+}
+
+// showSaveDialog asks where to save the board and saves it there
+func showSaveDialog(board *BoardWidget, window fyne.Window) {
+	log.Println("Save button clicked")
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if writer == nil || err != nil { 
+			log.Printf("Save dialog cancelled or error: %v", err)
+			return 
+		}
+		log.Printf("Saving to file: %s", writer.URI().String())
+		board.SaveToFile(writer)
+	}, window)
+	saveDialog.SetFileName("mysession.board")
+	saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".board"}))
+	saveDialog.Show()
+}
+
+// showLoadDialog asks for a board file and loads it
+func showLoadDialog(board *BoardWidget, window fyne.Window) {
+	log.Println("Load button clicked")
+	loadDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if reader == nil || err != nil { 
+			log.Printf("Load dialog cancelled or error: %v", err)
+			return 
+		}
+		log.Printf("Loading from file: %s", reader.URI().String())
+		
+		// Critical fix: Run the load operation in a separate goroutine
+		// to prevent blocking the UI thread
+		go func() {
+			board.LoadFromFile(reader)
+		}()
+	}, window)
+	loadDialog.SetFilter(storage.NewExtensionFileFilter([]string{".board"}))
+	loadDialog.Show()
+}
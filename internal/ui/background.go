@@ -0,0 +1,307 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/state"
+)
+
+// A document opened as background is a stack of image elements, one per
+// page, marked with their page numbers. They sit on their own locked layer
+// at the bottom, so everyone can draw on them without moving them by
+// accident. There is no PDF renderer here, so a PDF is opened from its pages
+// rendered to images beforehand and saved next to it, the way pdftoppm names
+// them: doc-1.png, doc-2.png...
+
+// Width of background pages and the gap between them, in board units
+const (
+	backgroundPageWidth float32 = 800
+	backgroundPageGap           = 2 * GridSize
+)
+
+// OpenBackground replaces the background document with the PNG or JPEG
+// files pages, stacked top to bottom, and shows the first page
+func (b *BoardWidget) OpenBackground(pages [][]byte) error {
+	if b.OnAddPaths == nil {
+		return fmt.Errorf("opening a background is not available")
+	}
+	if !b.CanLockLayers {
+		return fmt.Errorf("only the host can open a background")
+	}
+	if len(pages) == 0 || len(pages) > state.MaxBackgroundPages {
+		return fmt.Errorf("a background must have between 1 and %d pages", state.MaxBackgroundPages)
+	}
+	images := make([]state.Image, len(pages))
+	for i, data := range pages {
+		img, err := b.Assets.Add(data)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		images[i] = img
+	}
+	layer := b.unlockBackground()
+	b.deleteBackground()
+
+	view := b.VisibleRect()
+	pos := fyne.NewPos(view.X+view.Width/2-backgroundPageWidth/2, view.Y+backgroundPageGap)
+	paths := make([]Path, len(images))
+	for i, img := range images {
+		img.Page = i + 1
+		paths[i] = state.NewImagePath("background-"+generateID(), b.LocalClientID, img, pos, backgroundPageWidth)
+		paths[i].Layer = state.BackgroundLayer
+		pos.Y = paths[i].Points[1].Y + backgroundPageGap
+	}
+	log.Printf("Opening a background of %d pages", len(paths))
+	b.OnAddPaths(paths)
+	layer.Locked = true
+	b.setLayer(layer)
+	b.ShowPage(1)
+	return nil
+}
+
+// RemoveBackground deletes every page of the background document
+func (b *BoardWidget) RemoveBackground() {
+	if !b.CanLockLayers {
+		b.SetStatus("Only the host can remove the background")
+		return
+	}
+	layer := b.unlockBackground()
+	b.deleteBackground()
+	layer.Locked = true
+	b.setLayer(layer)
+}
+
+// unlockBackground shows and unlocks the background layer, adding it under
+// the others if there is none yet, so its pages can be changed. It returns
+// the layer as it is now.
+func (b *BoardWidget) unlockBackground() state.Layer {
+	b.mu.RLock()
+	layer, ok := b.layer(state.BackgroundLayer)
+	if !ok {
+		layer = state.Layer{ID: state.BackgroundLayer, Name: "Background", Position: b.layers[0].Position - 1}
+	}
+	b.mu.RUnlock()
+	layer.Hidden, layer.Locked = false, false
+	b.setLayer(layer)
+	return layer
+}
+
+// deleteBackground deletes every page of the background document
+func (b *BoardWidget) deleteBackground() {
+	var ids []string
+	b.mu.RLock()
+	for _, p := range b.paths {
+		if p.IsBackground() {
+			ids = append(ids, p.ID)
+		}
+	}
+	b.mu.RUnlock()
+	if len(ids) > 0 && b.OnDeletePaths != nil {
+		b.OnDeletePaths(ids)
+	}
+}
+
+// backgroundPages returns the pages of the background document, in page order
+func (b *BoardWidget) backgroundPages() []Path {
+	b.mu.RLock()
+	var pages []Path
+	for _, p := range b.paths {
+		if p.IsBackground() {
+			pages = append(pages, *p)
+		}
+	}
+	b.mu.RUnlock()
+	state.SortPages(pages)
+	return pages
+}
+
+// ShowPage fits the view to page n of the background document, counting from 1
+func (b *BoardWidget) ShowPage(n int) {
+	pages := b.backgroundPages()
+	if n < 1 || n > len(pages) {
+		return
+	}
+	box, ok := state.PathBounds(pages[n-1])
+	if !ok {
+		return
+	}
+	b.fitView(fyne.NewPos(box.MinX, box.MinY), fyne.NewPos(box.MaxX, box.MaxY))
+}
+
+// NextPage shows the page after the one in view
+func (b *BoardWidget) NextPage() {
+	b.ShowPage(b.pageNav.current + 1)
+}
+
+// PreviousPage shows the page before the one in view
+func (b *BoardWidget) PreviousPage() {
+	b.ShowPage(b.pageNav.current - 1)
+}
+
+// pathsChanged brings what follows the board's paths up to date: the minimap
+// and the page navigator
+func (b *BoardWidget) pathsChanged() {
+	fyne.Do(func() {
+		b.minimap.Refresh()
+		b.pageNav.refreshPages()
+	})
+}
+
+// pageNavigator steps through the pages of the background document, and
+// tells which one is in view. It is hidden while there is no background.
+type pageNavigator struct {
+	board   *BoardWidget
+	box     *fyne.Container
+	label   *widget.Label
+	pages   []ViewRect // Area of each page, in page order
+	current int        // Page in view, from 1, or 0 if none is
+}
+
+func newPageNavigator(b *BoardWidget) *pageNavigator {
+	n := &pageNavigator{board: b, label: widget.NewLabel("")}
+	n.box = container.NewHBox(
+		widget.NewButtonWithIcon("", theme.NavigateBackIcon(), b.PreviousPage),
+		n.label,
+		widget.NewButtonWithIcon("", theme.NavigateNextIcon(), b.NextPage),
+		widget.NewButtonWithIcon("", theme.ContentClearIcon(), b.RemoveBackground),
+	)
+	n.box.Hide()
+	return n
+}
+
+// refreshPages picks up the pages of the background document after the
+// board's paths changed
+func (n *pageNavigator) refreshPages() {
+	n.pages = n.pages[:0]
+	for _, p := range n.board.backgroundPages() {
+		if box, ok := state.PathBounds(p); ok {
+			n.pages = append(n.pages, ViewRect{X: box.MinX, Y: box.MinY, Width: box.MaxX - box.MinX, Height: box.MaxY - box.MinY})
+		}
+	}
+	if len(n.pages) == 0 {
+		n.current = 0
+		n.box.Hide()
+		return
+	}
+	n.box.Show()
+	n.followView()
+}
+
+// followView updates which page is in view: the one under the middle of the
+// view, or else the last one that was
+func (n *pageNavigator) followView() {
+	if len(n.pages) == 0 {
+		return
+	}
+	middle := n.board.toBoard(n.board.center())
+	for i, page := range n.pages {
+		if middle.X >= page.X && middle.X <= page.X+page.Width && middle.Y >= page.Y && middle.Y <= page.Y+page.Height {
+			n.current = i + 1
+			break
+		}
+	}
+	n.current = min(n.current, len(n.pages))
+	n.label.SetText(fmt.Sprintf("Document page %d of %d", n.current, len(n.pages)))
+}
+
+// backgroundFiles returns the page images of the file at uri: the image
+// itself, or the rendered pages of a PDF, in page order
+func backgroundFiles(uri fyne.URI) ([]fyne.URI, error) {
+	if !strings.EqualFold(uri.Extension(), ".pdf") {
+		if !isImageFile(uri) {
+			return nil, fmt.Errorf("%s is not a PDF, PNG or JPEG file", uri.Name())
+		}
+		return []fyne.URI{uri}, nil
+	}
+	base := strings.TrimSuffix(uri.Name(), uri.Extension())
+	parent, err := storage.Parent(uri)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := storage.List(parent)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[int]fyne.URI)
+	for _, entry := range entries {
+		if n, ok := renderedPage(entry, base); ok && pages[n] == nil {
+			pages[n] = entry
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no rendered pages of %s were found next to it. Render them as images first, for example with: pdftoppm -png %s %s", uri.Name(), uri.Name(), base)
+	}
+	var files []fyne.URI
+	for _, n := range slices.Sorted(maps.Keys(pages)) {
+		files = append(files, pages[n])
+	}
+	return files, nil
+}
+
+// renderedPage returns the page number of uri if it is a rendered page of
+// the document named base, as base-1.png or base-01.png
+func renderedPage(uri fyne.URI, base string) (int, bool) {
+	if !isImageFile(uri) {
+		return 0, false
+	}
+	digits, ok := strings.CutPrefix(strings.TrimSuffix(uri.Name(), uri.Extension()), base+"-")
+	if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	return n, err == nil && n > 0
+}
+
+// openBackgroundFile opens the image or rendered PDF at uri as background
+func (b *BoardWidget) openBackgroundFile(uri fyne.URI) error {
+	files, err := backgroundFiles(uri)
+	if err != nil {
+		return err
+	}
+	if len(files) > state.MaxBackgroundPages {
+		return fmt.Errorf("%s has more than %d pages", uri.Name(), state.MaxBackgroundPages)
+	}
+	pages := make([][]byte, len(files))
+	for i, file := range files {
+		reader, err := storage.Reader(file)
+		if err != nil {
+			return err
+		}
+		pages[i], err = readImageFile(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name(), err)
+		}
+	}
+	return b.OpenBackground(pages)
+}
+
+// ShowOpenBackgroundDialog asks for a PDF or image to annotate and opens it
+// as background
+func ShowOpenBackgroundDialog(board *BoardWidget, window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if reader == nil || err != nil {
+			log.Printf("Open background dialog cancelled or error: %v", err)
+			return
+		}
+		uri := reader.URI()
+		reader.Close()
+		if err := board.openBackgroundFile(uri); err != nil {
+			dialog.ShowError(err, window)
+		}
+	}, window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter(append([]string{".pdf"}, imageExtensions...)))
+	openDialog.Show()
+}
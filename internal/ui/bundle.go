@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"MyLocalBoard/internal/assets"
+	"MyLocalBoard/internal/state"
+)
+
+// Board files are zip archives holding the paths as board.json, the layers
+// as layers.json, the pages as frames.json and the pictures of image
+// elements under assets/, named by their hash. Files saved before images
+// existed are a bare JSON array of paths, which loading still reads.
+const (
+	boardEntry  = "board.json"
+	layersEntry = "layers.json"
+	framesEntry = "frames.json"
+	assetsEntry = "assets/"
+)
+
+// writeBoardFile writes paths, layers and frames to w as a board file, with
+// the pictures the paths show
+func writeBoardFile(w io.Writer, paths []Path, layers []state.Layer, frames []state.Frame, store *assets.Store) error {
+	archive := zip.NewWriter(w)
+	entries := []struct {
+		name string
+		v    any
+	}{{boardEntry, paths}, {layersEntry, layers}, {framesEntry, frames}}
+	for _, e := range entries {
+		entry, err := archive.Create(e.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(e.v); err != nil {
+			return err
+		}
+	}
+	written := make(map[string]bool)
+	for _, p := range paths {
+		if p.Image == nil || written[p.Image.Asset] {
+			continue
+		}
+		written[p.Image.Asset] = true
+		data, ok := store.Get(p.Image.Asset)
+		if !ok {
+			log.Printf("Saving without the picture of %s, which has not arrived", p.ID)
+			continue
+		}
+		// Pictures are compressed already
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: assetsEntry + p.Image.Asset, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// readBoardFile returns the paths, layers and frames of a board file, adding
+// the pictures it holds to store. Files from before layers or frames have
+// none.
+func readBoardFile(data []byte, store *assets.Store) ([]Path, []state.Layer, []state.Frame, error) {
+	var paths []Path
+	var layers []state.Layer
+	var frames []state.Frame
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		// Not an archive, so a file from before images
+		if err := json.Unmarshal(data, &paths); err != nil {
+			return nil, nil, nil, err
+		}
+		return paths, nil, nil, nil
+	}
+	found := false
+	for _, f := range archive.File {
+		switch {
+		case f.Name == boardEntry, f.Name == layersEntry, f.Name == framesEntry:
+			r, err := f.Open()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			switch f.Name {
+			case boardEntry:
+				err = json.NewDecoder(r).Decode(&paths)
+				found = true
+			case layersEntry:
+				err = json.NewDecoder(r).Decode(&layers)
+			default:
+				err = json.NewDecoder(r).Decode(&frames)
+			}
+			r.Close()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		case strings.HasPrefix(f.Name, assetsEntry):
+			r, err := f.Open()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			asset, err := io.ReadAll(io.LimitReader(r, assets.MaxAssetBytes+1))
+			r.Close()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if _, err := store.Add(asset); err != nil {
+				log.Printf("Skipping %s: %v", f.Name, err)
+			}
+		}
+	}
+	if !found {
+		return nil, nil, nil, fmt.Errorf("no %s in the board file", boardEntry)
+	}
+	return paths, layers, frames, nil
+}
@@ -0,0 +1,211 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"golang.design/x/clipboard"
+
+	"MyLocalBoard/internal/export"
+	"MyLocalBoard/internal/state"
+)
+
+// Fyne's clipboard only holds text, so images go through the system
+// clipboard instead. A copied selection goes on it as a PNG image, which
+// documents and chats take; where there is no system clipboard, it goes on
+// Fyne's as an SVG image, which drawing tools and many editors take. The
+// paths themselves ride along in the image's metadata, so pasting into a
+// board gets them back exactly as they were. Other images paste as image
+// elements, and any other text as a text element.
+
+// clipboardFormat marks board paths in the metadata of a copied image
+const clipboardFormat = "mylocalboard/paths"
+
+// Where the board's paths sit in a copied SVG
+const (
+	metadataStart = `<metadata id="mylocalboard">`
+	metadataEnd   = `</metadata>`
+)
+
+// copyScale is how many pixels a board unit takes up in a copied PNG
+const copyScale = 2
+
+// clipboardData is the metadata of a copied image
+type clipboardData struct {
+	Format string `json:"format"`
+	Paths  []Path `json:"paths"`
+}
+
+var (
+	systemClipboardOnce sync.Once
+	systemClipboardOK   bool
+)
+
+// systemClipboard reports whether the system clipboard can be used for
+// images, setting it up the first time
+func systemClipboard() bool {
+	systemClipboardOnce.Do(func() {
+		if err := clipboard.Init(); err != nil {
+			log.Printf("No system clipboard for images: %v", err)
+			return
+		}
+		systemClipboardOK = true
+	})
+	return systemClipboardOK
+}
+
+// CopySelection puts the selected paths on the clipboard as a PNG image, or
+// as an SVG image if that can't be done
+func (b *BoardWidget) CopySelection() {
+	paths := b.selectedPaths()
+	if len(paths) == 0 {
+		return
+	}
+	data, err := json.Marshal(clipboardData{Format: clipboardFormat, Paths: paths})
+	if err != nil {
+		return
+	}
+	b.pastes = 0
+	if systemClipboard() {
+		image, err := copiedPNG(paths, b.Assets, string(data))
+		if err == nil {
+			clipboard.Write(clipboard.FmtImage, image)
+			b.SetStatus(fmt.Sprintf("Copied %d paths", len(paths)))
+			return
+		}
+		log.Printf("Copying the selection as PNG failed, copying SVG: %v", err)
+	}
+	var svg bytes.Buffer
+	if err := export.ExportToSVG(&svg, paths, b.Assets, string(data)); err != nil {
+		log.Printf("Copying the selection failed: %v", err)
+		return
+	}
+	fyne.CurrentApp().Clipboard().SetContent(svg.String())
+	b.SetStatus(fmt.Sprintf("Copied %d paths", len(paths)))
+}
+
+// copiedPNG draws paths as a PNG image with metadata in it
+func copiedPNG(paths []Path, assets export.Assets, metadata string) ([]byte, error) {
+	var image bytes.Buffer
+	if err := export.ExportToPNG(&image, paths, assets, copyScale); err != nil {
+		return nil, err
+	}
+	return export.AddPNGText(image.Bytes(), clipboardFormat, metadata)
+}
+
+// CutSelection copies the selected paths and deletes them
+func (b *BoardWidget) CutSelection() {
+	b.CopySelection()
+	b.DeleteSelection()
+}
+
+// Paste adds what is on the clipboard. Paths copied from a board are added a
+// little offset each time, so repeated pastes do not pile up. A copied image,
+// or image file, is inserted at the pointer, and other text becomes a text
+// element there.
+func (b *BoardWidget) Paste() {
+	if systemClipboard() {
+		if image := clipboard.Read(clipboard.FmtImage); len(image) > 0 {
+			b.pasteImage(image)
+			return
+		}
+	}
+	content := fyne.CurrentApp().Clipboard().Content()
+	if paths, ok := copiedPaths(content); ok {
+		b.pastePaths(paths)
+		return
+	}
+	if uri, ok := imageFileURI(content); ok {
+		if err := b.insertImageFile(uri, b.pastePosition()); err != nil {
+			b.SetStatus(fmt.Sprintf("Could not paste %s: %v", uri.Name(), err))
+		}
+		return
+	}
+	if strings.TrimSpace(content) != "" {
+		b.pasteText(content)
+	}
+}
+
+// pasteImage adds the paths in a PNG image copied from a board, or else the
+// image itself
+func (b *BoardWidget) pasteImage(image []byte) {
+	if metadata, ok := export.PNGText(image, clipboardFormat); ok {
+		if paths, ok := decodeClipboardData(metadata); ok {
+			b.pastePaths(paths)
+			return
+		}
+	}
+	if err := b.InsertImage(image, b.pastePosition()); err != nil {
+		b.SetStatus(fmt.Sprintf("Could not paste the image: %v", err))
+	}
+}
+
+// pastePaths adds copies of paths, offset by how often they were pasted
+func (b *BoardWidget) pastePaths(paths []Path) {
+	b.pastes++
+	b.addCopies(paths, float32(b.pastes)*GridSize)
+}
+
+// copiedPaths returns the board paths embedded in a copied SVG, if content is one
+func copiedPaths(content string) ([]Path, bool) {
+	start := strings.Index(content, metadataStart)
+	if start < 0 {
+		return nil, false
+	}
+	content = content[start+len(metadataStart):]
+	end := strings.Index(content, metadataEnd)
+	if end < 0 {
+		return nil, false
+	}
+	return decodeClipboardData(html.UnescapeString(content[:end]))
+}
+
+// decodeClipboardData returns the board paths in the metadata of a copied image
+func decodeClipboardData(metadata string) ([]Path, bool) {
+	var data clipboardData
+	if err := json.Unmarshal([]byte(metadata), &data); err != nil || data.Format != clipboardFormat {
+		return nil, false
+	}
+	return data.Paths, true
+}
+
+// pastePosition returns where pasted content goes: at the pointer, or in the
+// middle of the view if the pointer is elsewhere
+func (b *BoardWidget) pastePosition() fyne.Position {
+	if b.pointerIn {
+		return b.toBoard(b.pointer)
+	}
+	return b.toBoard(b.center())
+}
+
+// pasteText adds content as a text element where pasted content goes, and
+// selects it
+func (b *BoardWidget) pasteText(content string) {
+	content = strings.TrimRight(strings.ToValidUTF8(content, ""), " \t\r\n")
+	for len(content) > state.MaxTextLength {
+		_, size := utf8.DecodeLastRuneInString(content)
+		content = content[:len(content)-size]
+	}
+	pos := b.pastePosition()
+	p := Path{
+		ID:      "text-" + generateID(),
+		OwnerID: b.LocalClientID,
+		Points:  []fyne.Position{pos, pos.AddXY(DefaultTextWidth, b.TextSize*state.LineSpacing)},
+		Color:   b.currentColor,
+		Stroke:  1,
+		Text:    &state.Text{Content: content, Size: b.TextSize, Align: b.TextAlign},
+	}
+	fitText(&p)
+	if !b.addPaths([]Path{p}) {
+		return
+	}
+	b.selection = []string{p.ID}
+	b.Refresh()
+}
@@ -1,7 +1,55 @@
 package ui
 
-// This file will contain the UI elements related to exporting,
-// such as the "Export to PDF" button and the file save dialog.
-func ShowExportDialog() {
-	// TODO: Implement file dialog logic that calls the export package
-}
\ No newline at end of file
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"MyLocalBoard/internal/export"
+	"MyLocalBoard/internal/spatial"
+)
+
+// exportScale is the resolution of PNG exports, in pixels per board unit
+const exportScale float32 = 2
+
+// ShowExportDialog asks where to export the board to, as PDF, SVG or PNG by extension
+func ShowExportDialog(board *BoardWidget, window fyne.Window) {
+	exportDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if writer == nil || err != nil {
+			log.Printf("Export dialog cancelled or error: %v", err)
+			return
+		}
+		defer writer.Close()
+
+		// Hidden layers are left out, and the rest stacked as on screen
+		paths := board.drawnPaths()
+		switch ext := writer.URI().Extension(); {
+		case strings.EqualFold(ext, ".pdf") && len(board.Frames()) > 0:
+			// A page per page of the board
+			var pages []spatial.Rect
+			for _, f := range board.Frames() {
+				pages = append(pages, frameRect(f))
+			}
+			err = export.ExportPagesToPDF(writer, paths, pages, board.Assets)
+		case strings.EqualFold(ext, ".pdf"):
+			err = export.ExportToPDF(writer, paths, board.Assets)
+		case strings.EqualFold(ext, ".svg"):
+			err = export.ExportToSVG(writer, paths, board.Assets, "")
+		default:
+			err = export.ExportToPNG(writer, paths, board.Assets, exportScale)
+		}
+		if err != nil {
+			log.Printf("Export failed: %v", err)
+			dialog.ShowError(err, window)
+			return
+		}
+		board.SetStatus(fmt.Sprintf("Exported %d drawings to %s", len(paths), writer.URI().Name()))
+	}, window)
+	exportDialog.SetFileName("board.png")
+	exportDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".pdf", ".svg"}))
+	exportDialog.Show()
+}
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"MyLocalBoard/internal/assets"
+	"MyLocalBoard/internal/state"
+)
+
+// imageExtensions are the files that can be inserted as images
+var imageExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// maxImageViewShare is how much of the view's width a new image may take up
+const maxImageViewShare = 0.6
+
+// assetArrived redraws the board once the picture of an image is there
+func (b *BoardWidget) assetArrived(string) {
+	b.mu.Lock()
+	b.generation++
+	b.mu.Unlock()
+	fyne.Do(b.Refresh)
+}
+
+// InsertImage adds the PNG or JPEG file data as an image element centred on
+// pos. It is shown at its own size, unless that would fill most of the view.
+func (b *BoardWidget) InsertImage(data []byte, pos fyne.Position) error {
+	img, err := b.Assets.Add(data)
+	if err != nil {
+		return err
+	}
+	if b.OnAddPaths == nil {
+		return fmt.Errorf("inserting images is not available")
+	}
+	view := b.VisibleRect()
+	width := float32(img.Width) / b.viewport.Scale
+	if view.Width > 0 {
+		width = min(width, view.Width*maxImageViewShare)
+	}
+	height := width * float32(img.Height) / float32(img.Width)
+	p := state.NewImagePath("image-"+generateID(), b.LocalClientID, img, pos.SubtractXY(width/2, height/2), width)
+	log.Printf("Inserting image %s (%dx%d)", img.Asset, img.Width, img.Height)
+	if !b.addPaths([]Path{p}) {
+		return nil
+	}
+	b.selection = []string{p.ID}
+	b.Refresh()
+	return nil
+}
+
+// insertImageFile inserts the image file at uri centred on pos
+func (b *BoardWidget) insertImageFile(uri fyne.URI, pos fyne.Position) error {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	data, err := readImageFile(reader)
+	if err != nil {
+		return err
+	}
+	return b.InsertImage(data, pos)
+}
+
+// readImageFile reads an image file, up to just past the largest asset
+// allowed, which InsertImage then refuses
+func readImageFile(r io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r, assets.MaxAssetBytes+1))
+}
+
+// isImageFile reports whether uri names a file that can be inserted as an image
+func isImageFile(uri fyne.URI) bool {
+	for _, ext := range imageExtensions {
+		if strings.EqualFold(uri.Extension(), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageFileURI returns the image file text names, as a path or a file URI,
+// which is what copying a file in a file manager puts on the clipboard
+func imageFileURI(text string) (fyne.URI, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, "\r\n") {
+		return nil, false
+	}
+	var uri fyne.URI
+	if strings.HasPrefix(text, "file://") {
+		parsed, err := storage.ParseURI(text)
+		if err != nil {
+			return nil, false
+		}
+		uri = parsed
+	} else if filepath.IsAbs(text) {
+		uri = storage.NewFileURI(text)
+	} else {
+		return nil, false
+	}
+	if !isImageFile(uri) {
+		return nil, false
+	}
+	if exists, err := storage.Exists(uri); err != nil || !exists {
+		return nil, false
+	}
+	return uri, true
+}
+
+// DropFiles inserts the image files dropped at pos, a position in the window,
+// each a little offset from the one before
+func (b *BoardWidget) DropFiles(pos fyne.Position, uris []fyne.URI) {
+	if driver := fyne.CurrentApp().Driver(); driver != nil {
+		pos = pos.Subtract(driver.AbsolutePositionForObject(b))
+	}
+	at := b.toBoard(pos)
+	for _, uri := range uris {
+		if !isImageFile(uri) {
+			continue
+		}
+		if err := b.insertImageFile(uri, at); err != nil {
+			b.SetStatus(fmt.Sprintf("Could not insert %s: %v", uri.Name(), err))
+			continue
+		}
+		at = at.AddXY(GridSize, GridSize)
+	}
+}
+
+// ShowInsertImageDialog asks for an image file and inserts it in the middle of the view
+func ShowInsertImageDialog(board *BoardWidget, window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if reader == nil || err != nil {
+			log.Printf("Insert image dialog cancelled or error: %v", err)
+			return
+		}
+		defer reader.Close()
+		data, err := readImageFile(reader)
+		if err == nil {
+			err = board.InsertImage(data, board.toBoard(board.center()))
+		}
+		if err != nil {
+			dialog.ShowError(err, window)
+		}
+	}, window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter(imageExtensions))
+	openDialog.Show()
+}
@@ -0,0 +1,363 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/state"
+)
+
+// SetLayers replaces the layers, bottom first, and redraws the board in
+// their order. Selected paths that are now hidden or locked are dropped from
+// the selection.
+func (b *BoardWidget) SetLayers(layers []state.Layer) {
+	b.mu.Lock()
+	b.layers = append([]state.Layer(nil), layers...)
+	state.SortLayers(b.layers)
+	b.layerIndex = make(map[string]int, len(b.layers))
+	for i, l := range b.layers {
+		b.layerIndex[l.ID] = i
+	}
+	if _, ok := b.layerIndex[b.activeLayer]; !ok {
+		b.activeLayer = state.DefaultLayer
+	}
+	selection := b.selection[:0]
+	for _, id := range b.selection {
+		if p := b.byID[id]; p != nil && !b.locked(p) {
+			selection = append(selection, id)
+		}
+	}
+	b.selection = selection
+	b.generation++
+	b.mu.Unlock()
+	fyne.Do(b.Refresh)
+	b.pathsChanged()
+	fyne.Do(b.layerList.Refresh)
+}
+
+// Layers returns the layers, bottom first
+func (b *BoardWidget) Layers() []state.Layer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]state.Layer(nil), b.layers...)
+}
+
+// layer returns the layer with the given ID. Callers must hold b.mu.
+func (b *BoardWidget) layer(id string) (state.Layer, bool) {
+	i, ok := b.layerIndex[id]
+	if !ok {
+		return state.Layer{}, false
+	}
+	return b.layers[i], true
+}
+
+// layerRank returns where the layer of p is in the stack, bottom first. Paths
+// on a layer we have not heard of yet go with the default layer. Callers must
+// hold b.mu.
+func (b *BoardWidget) layerRank(p *Path) int {
+	if i, ok := b.layerIndex[p.LayerID()]; ok {
+		return i
+	}
+	return b.layerIndex[state.DefaultLayer]
+}
+
+// hidden reports whether p is on a hidden layer. Callers must hold b.mu.
+func (b *BoardWidget) hidden(p *Path) bool {
+	l, ok := b.layer(p.LayerID())
+	return ok && l.Hidden
+}
+
+// locked reports whether p can't be selected, being on a locked or hidden
+// layer. Callers must hold b.mu.
+func (b *BoardWidget) locked(p *Path) bool {
+	l, ok := b.layer(p.LayerID())
+	return ok && (l.Locked || l.Hidden)
+}
+
+// raiseTop notes p as the highest stacked path if it is. Callers must hold
+// b.mu.
+func (b *BoardWidget) raiseTop(p *Path) {
+	if b.top == nil || state.Below(*b.top, *p) {
+		b.top = p
+	}
+}
+
+// drawnOnTop reports whether p is drawn above everything else on the board
+// once appended. Callers must hold b.mu.
+func (b *BoardWidget) drawnOnTop(p *Path) bool {
+	return p == b.top && b.layerRank(p) == len(b.layers)-1 && !b.hidden(p)
+}
+
+// drawnAbove reports whether p is drawn over q. Callers must hold b.mu.
+func (b *BoardWidget) drawnAbove(p, q *Path) bool {
+	if rp, rq := b.layerRank(p), b.layerRank(q); rp != rq {
+		return rp > rq
+	}
+	return state.Below(*q, *p)
+}
+
+// drawnPaths returns the paths on shown layers, in the order they are drawn
+func (b *BoardWidget) drawnPaths() []Path {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	drawn := b.drawnLocked()
+	paths := make([]Path, len(drawn))
+	for i, p := range drawn {
+		paths[i] = *p
+	}
+	return paths
+}
+
+// drawnLocked returns the paths on shown layers, in the order they are drawn.
+// Callers must hold b.mu.
+func (b *BoardWidget) drawnLocked() []*Path {
+	byLayer := make([][]*Path, len(b.layers))
+	for _, p := range b.paths {
+		if !b.hidden(p) {
+			rank := b.layerRank(p)
+			byLayer[rank] = append(byLayer[rank], p)
+		}
+	}
+	paths := make([]*Path, 0, len(b.paths))
+	for _, layer := range byLayer {
+		sort.Slice(layer, func(i, j int) bool { return state.Below(*layer[i], *layer[j]) })
+		paths = append(paths, layer...)
+	}
+	return paths
+}
+
+// ActiveLayer returns the layer new paths go on
+func (b *BoardWidget) ActiveLayer() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.activeLayer
+}
+
+// SetActiveLayer makes the layer with the given ID the one new paths go on
+func (b *BoardWidget) SetActiveLayer(id string) {
+	b.mu.Lock()
+	if _, ok := b.layerIndex[id]; ok {
+		b.activeLayer = id
+	}
+	b.mu.Unlock()
+}
+
+// onActiveLayer puts the paths that name no layer on the active one. It
+// reports false, and says why, if that layer can't take them.
+func (b *BoardWidget) onActiveLayer(paths []Path) bool {
+	b.mu.RLock()
+	active, _ := b.layer(b.activeLayer)
+	b.mu.RUnlock()
+	if active.Locked || active.Hidden {
+		b.SetStatus(fmt.Sprintf("Layer %q is locked or hidden, pick another one to draw on", active.Name))
+		return false
+	}
+	for i := range paths {
+		if paths[i].Layer == "" {
+			paths[i].Layer = active.ID
+		}
+	}
+	return true
+}
+
+// newPath hands a finished stroke or shape to OnNewPath, on the active layer
+func (b *BoardWidget) newPath(p Path) {
+	paths := []Path{p}
+	if b.OnNewPath != nil && b.onActiveLayer(paths) {
+		b.OnNewPath(paths[0])
+	}
+}
+
+// addPaths hands new paths to OnAddPaths, those that name no layer on the
+// active one. It reports whether they were added.
+func (b *BoardWidget) addPaths(paths []Path) bool {
+	if b.OnAddPaths == nil || !b.onActiveLayer(paths) {
+		return false
+	}
+	b.OnAddPaths(paths)
+	return true
+}
+
+// setLayer hands a new version of a layer to OnLayerChanged
+func (b *BoardWidget) setLayer(l state.Layer) {
+	if b.OnLayerChanged != nil {
+		b.OnLayerChanged(l)
+	}
+}
+
+// AddLayer adds a layer on top of the others and makes it the active one
+func (b *BoardWidget) AddLayer() {
+	b.mu.RLock()
+	top := b.layers[len(b.layers)-1].Position
+	l := state.Layer{ID: "layer-" + generateID(), Name: fmt.Sprintf("Layer %d", len(b.layers)+1), Position: top + 1}
+	b.mu.RUnlock()
+	b.setLayer(l)
+	b.SetActiveLayer(l.ID)
+}
+
+// MoveLayer moves the layer with the given ID up the stack by steps places,
+// or down for negative steps. It goes between its new neighbours, so
+// concurrent moves of other layers still land where they were meant to.
+func (b *BoardWidget) MoveLayer(id string, steps int) {
+	b.mu.RLock()
+	i, ok := b.layerIndex[id]
+	if !ok {
+		b.mu.RUnlock()
+		return
+	}
+	l := b.layers[i]
+	others := make([]state.Layer, 0, len(b.layers)-1)
+	others = append(others, b.layers[:i]...)
+	others = append(others, b.layers[i+1:]...)
+	b.mu.RUnlock()
+
+	to := min(max(i+steps, 0), len(others))
+	if to == i {
+		return
+	}
+	positions := make([]float64, len(others))
+	for j, other := range others {
+		positions[j] = other.Position
+	}
+	l.Position = positionAt(positions, to)
+	b.setLayer(l)
+}
+
+// positionAt returns the position that puts an item at index to among items
+// at positions, in order: between its new neighbours, or past the ends
+func positionAt(positions []float64, to int) float64 {
+	switch {
+	case len(positions) == 0:
+		return 0
+	case to == 0:
+		return positions[0] - 1
+	case to == len(positions):
+		return positions[len(positions)-1] + 1
+	default:
+		return (positions[to-1] + positions[to]) / 2
+	}
+}
+
+// MoveSelectionToLayer puts the selected paths on the layer with the given
+// ID, unless it is locked or hidden
+func (b *BoardWidget) MoveSelectionToLayer(id string) {
+	if len(b.selection) == 0 || b.OnMoveToLayer == nil {
+		return
+	}
+	b.mu.RLock()
+	l, ok := b.layer(id)
+	b.mu.RUnlock()
+	if !ok || l.Locked || l.Hidden {
+		b.SetStatus(fmt.Sprintf("Layer %q is locked or hidden, pick another one to move to", l.Name))
+		return
+	}
+	b.OnMoveToLayer(b.selection, id)
+	b.Refresh()
+}
+
+// layerAt returns the layer shown in a row of the layer list, which runs top
+// first
+func (b *BoardWidget) layerAt(row widget.ListItemID) (state.Layer, bool) {
+	layers := b.Layers()
+	if row < 0 || row >= len(layers) {
+		return state.Layer{}, false
+	}
+	return layers[len(layers)-1-row], true
+}
+
+func (b *BoardWidget) newLayerList() *widget.List {
+	return widget.NewList(
+		func() int {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			return len(b.layers)
+		},
+		func() fyne.CanvasObject {
+			return container.NewHBox(widget.NewCheck("Show", nil), widget.NewCheck("Lock", nil), widget.NewLabel("layer"))
+		},
+		func(row widget.ListItemID, item fyne.CanvasObject) {
+			l, ok := b.layerAt(row)
+			if !ok {
+				return
+			}
+			objects := item.(*fyne.Container).Objects
+			shown, locked, name := objects[0].(*widget.Check), objects[1].(*widget.Check), objects[2].(*widget.Label)
+			shown.OnChanged, locked.OnChanged = nil, nil
+			shown.SetChecked(!l.Hidden)
+			locked.SetChecked(l.Locked)
+			if b.CanLockLayers {
+				locked.Enable()
+			} else {
+				locked.Disable()
+			}
+			shown.OnChanged = func(on bool) {
+				l.Hidden = !on
+				b.setLayer(l)
+			}
+			locked.OnChanged = func(on bool) {
+				l.Locked = on
+				b.setLayer(l)
+			}
+			text := l.Name
+			if l.ID == b.ActiveLayer() {
+				text += " (drawing)"
+			}
+			name.SetText(text)
+		},
+	)
+}
+
+// layersPanel is the side panel listing the layers, top first. Picking one
+// makes it the layer new paths go on; each can be shown or hidden, locked,
+// renamed and moved up or down.
+func (b *BoardWidget) layersPanel(window fyne.Window) fyne.CanvasObject {
+	b.layerList.OnSelected = func(row widget.ListItemID) {
+		if l, ok := b.layerAt(row); ok {
+			b.SetActiveLayer(l.ID)
+			b.layerList.Refresh()
+		}
+	}
+
+	active := func() (state.Layer, bool) {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return b.layer(b.activeLayer)
+	}
+	rename := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+		l, ok := active()
+		if !ok {
+			return
+		}
+		entry := widget.NewEntry()
+		entry.SetText(l.Name)
+		entry.Validator = func(s string) error {
+			if s == "" || len(s) > state.MaxLayerName {
+				return fmt.Errorf("a name has 1 to %d characters", state.MaxLayerName)
+			}
+			return nil
+		}
+		dialog.ShowForm("Rename layer", "Rename", "Cancel", []*widget.FormItem{widget.NewFormItem("Name", entry)}, func(ok bool) {
+			if ok {
+				l.Name = entry.Text
+				b.setLayer(l)
+			}
+		}, window)
+	})
+	move := func(steps int) func() {
+		return func() { b.MoveLayer(b.ActiveLayer(), steps) }
+	}
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("", theme.ContentAddIcon(), b.AddLayer),
+		rename,
+		widget.NewButtonWithIcon("", theme.MoveUpIcon(), move(1)),
+		widget.NewButtonWithIcon("", theme.MoveDownIcon(), move(-1)),
+	)
+	moveSelection := widget.NewButton("Move selection here", func() { b.MoveSelectionToLayer(b.ActiveLayer()) })
+	return container.NewBorder(widget.NewLabel("Layers"), container.NewVBox(buttons, moveSelection), nil, nil, b.layerList)
+}
@@ -0,0 +1,273 @@
+package ui
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/state"
+)
+
+// Minimap size and padding around the content, in pixels
+const (
+	minimapWidth   float32 = 200
+	minimapHeight  float32 = 150
+	minimapPadding float32 = 8
+)
+
+// ViewRect is the part of the board a participant is looking at, in board units
+type ViewRect struct {
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+}
+
+// usable reports whether r is finite and has an area, so a view can be
+// fitted to it
+func (r ViewRect) usable() bool {
+	for _, v := range []float32{r.X, r.Y, r.Width, r.Height} {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return r.Width > 0 && r.Height > 0
+}
+
+func (r ViewRect) union(o ViewRect) ViewRect {
+	x, y := min(r.X, o.X), min(r.Y, o.Y)
+	return ViewRect{
+		X:      x,
+		Y:      y,
+		Width:  max(r.X+r.Width, o.X+o.Width) - x,
+		Height: max(r.Y+r.Height, o.Y+o.Height) - y,
+	}
+}
+
+// remoteViewColors tell remote viewports apart on the minimap
+var remoteViewColors = []color.NRGBA{
+	{R: 230, G: 80, B: 60, A: 255},
+	{R: 60, G: 160, B: 80, A: 255},
+	{R: 150, G: 80, B: 200, A: 255},
+	{R: 230, G: 150, B: 30, A: 255},
+	{R: 40, G: 160, B: 190, A: 255},
+}
+
+func remoteViewColor(id string) color.NRGBA {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return remoteViewColors[h.Sum32()%uint32(len(remoteViewColors))]
+}
+
+// VisibleRect returns the part of the board currently shown
+func (b *BoardWidget) VisibleRect() ViewRect {
+	size := b.Size()
+	topLeft := b.viewport.ToBoard(fyne.NewPos(0, 0))
+	return ViewRect{X: topLeft.X, Y: topLeft.Y, Width: size.Width / b.viewport.Scale, Height: size.Height / b.viewport.Scale}
+}
+
+// SetRemoteViewport shows where another participant is looking. Views
+// without an area are ignored.
+func (b *BoardWidget) SetRemoteViewport(id string, rect ViewRect) {
+	if !rect.usable() {
+		return
+	}
+	b.mu.Lock()
+	b.remoteViews[id] = rect
+	following := b.following == id
+	b.mu.Unlock()
+	fyne.Do(b.minimap.Refresh)
+	fyne.Do(b.participantList.Refresh)
+	if !b.trackPresenter(id, rect) && following {
+		b.followPage(rect)
+	}
+}
+
+// Minimap is an overview of the whole board. It shows every path as its
+// bounding box, the local view and the views of other participants; tapping
+// or dragging on it moves the local view there.
+type Minimap struct {
+	widget.BaseWidget
+	board *BoardWidget
+
+	area       ViewRect // Board area the minimap shows
+	generation uint64   // Board generation area was computed for
+}
+
+var _ fyne.Tappable = (*Minimap)(nil)
+var _ fyne.Draggable = (*Minimap)(nil)
+
+func newMinimap(board *BoardWidget) *Minimap {
+	m := &Minimap{board: board}
+	m.ExtendBaseWidget(m)
+	return m
+}
+
+func (r ViewRect) contains(o ViewRect) bool {
+	return o.X >= r.X && o.Y >= r.Y && o.X+o.Width <= r.X+r.Width && o.Y+o.Height <= r.Y+r.Height
+}
+
+// extent returns the board area the minimap covers and its scale. The area
+// has some slack, so that it, and with it the thumbnail, only changes when the
+// view or content leaves it or paths are removed.
+func (m *Minimap) extent() (ViewRect, float32) {
+	b := m.board
+	needed := b.VisibleRect()
+	if lo, hi, ok := b.contentBounds(); ok {
+		needed = needed.union(ViewRect{X: lo.X, Y: lo.Y, Width: hi.X - lo.X, Height: hi.Y - lo.Y})
+	}
+	b.mu.RLock()
+	for _, view := range b.remoteViews {
+		needed = needed.union(view)
+	}
+	generation, appended := b.generation, b.lastAppend == b.generation
+	b.mu.RUnlock()
+
+	if !m.area.contains(needed) || (m.generation != generation && !appended) {
+		m.area = ViewRect{
+			X:      needed.X - needed.Width/4,
+			Y:      needed.Y - needed.Height/4,
+			Width:  needed.Width * 1.5,
+			Height: needed.Height * 1.5,
+		}
+	}
+	m.generation = generation
+
+	scale := min(
+		(minimapWidth-2*minimapPadding)/max(m.area.Width, 1),
+		(minimapHeight-2*minimapPadding)/max(m.area.Height, 1),
+	)
+	return m.area, scale
+}
+
+// toMinimap converts a board position to a minimap position
+func toMinimap(p fyne.Position, area ViewRect, scale float32) fyne.Position {
+	return fyne.NewPos(minimapPadding+(p.X-area.X)*scale, minimapPadding+(p.Y-area.Y)*scale)
+}
+
+// outline returns a rectangle outlining r on the minimap
+func outline(r ViewRect, area ViewRect, scale float32, stroke color.Color, fill color.Color) *canvas.Rectangle {
+	rect := canvas.NewRectangle(fill)
+	rect.StrokeColor = stroke
+	rect.StrokeWidth = 1
+	rect.Move(toMinimap(fyne.NewPos(r.X, r.Y), area, scale))
+	rect.Resize(fyne.NewSize(max(r.Width*scale, 1), max(r.Height*scale, 1)))
+	return rect
+}
+
+// jumpTo centres the board's view on the board point under pos
+func (m *Minimap) jumpTo(pos fyne.Position) {
+	area, scale := m.extent()
+	target := fyne.NewPos(area.X+(pos.X-minimapPadding)/scale, area.Y+(pos.Y-minimapPadding)/scale)
+	b := m.board
+	v := b.Viewport()
+	center := b.center()
+	v.Offset = fyne.NewPos(center.X-target.X*v.Scale, center.Y-target.Y*v.Scale)
+	b.SetViewport(v)
+}
+
+func (m *Minimap) Tapped(e *fyne.PointEvent) {
+	m.jumpTo(e.Position)
+}
+
+func (m *Minimap) Dragged(e *fyne.DragEvent) {
+	m.jumpTo(e.Position)
+}
+
+func (m *Minimap) DragEnd() {}
+
+func (m *Minimap) CreateRenderer() fyne.WidgetRenderer {
+	r := &minimapRenderer{
+		minimap:   m,
+		thumbnail: image.NewNRGBA(image.Rect(0, 0, int(minimapWidth), int(minimapHeight))),
+	}
+	r.background = canvas.NewRectangle(color.NRGBA{R: 245, G: 245, B: 245, A: 230})
+	r.background.StrokeColor = color.Gray{Y: 150}
+	r.background.StrokeWidth = 1
+	r.image = canvas.NewImageFromImage(r.thumbnail)
+	r.image.ScaleMode = canvas.ImageScalePixels
+	r.image.Resize(fyne.NewSize(minimapWidth, minimapHeight))
+	r.Refresh()
+	return r
+}
+
+// minimapRenderer draws the paths into a thumbnail that is only redrawn when
+// content or the covered area change; the view outlines are cheap to redo.
+type minimapRenderer struct {
+	minimap    *Minimap
+	background *canvas.Rectangle
+	thumbnail  *image.NRGBA
+	image      *canvas.Image
+	objects    []fyne.CanvasObject
+
+	area       ViewRect
+	generation uint64
+}
+
+func (r *minimapRenderer) Refresh() {
+	m := r.minimap
+	b := m.board
+	area, scale := m.extent()
+
+	b.mu.RLock()
+	switch {
+	case area == r.area && r.generation == b.generation:
+	case area == r.area && r.generation+1 == b.generation && b.lastAppend == b.generation && b.drawnOnTop(b.paths[len(b.paths)-1]):
+		r.drawPath(b.paths[len(b.paths)-1], area, scale)
+		r.image.Refresh()
+	default:
+		clear(r.thumbnail.Pix)
+		for _, p := range b.drawnLocked() {
+			r.drawPath(p, area, scale)
+		}
+		r.image.Refresh()
+	}
+	r.area, r.generation = area, b.generation
+
+	objects := []fyne.CanvasObject{r.background, r.image}
+	for id, view := range b.remoteViews {
+		objects = append(objects, outline(view, area, scale, remoteViewColor(id), color.Transparent))
+	}
+	b.mu.RUnlock()
+	objects = append(objects, outline(b.VisibleRect(), area, scale, color.NRGBA{B: 255, A: 255}, color.NRGBA{B: 255, A: 30}))
+
+	r.objects = objects
+	canvas.Refresh(m)
+}
+
+// drawPath fills the bounding box of p into the thumbnail
+func (r *minimapRenderer) drawPath(p *Path, area ViewRect, scale float32) {
+	bounds, ok := state.PathBounds(*p)
+	if !ok {
+		return
+	}
+	from := toMinimap(fyne.NewPos(bounds.MinX, bounds.MinY), area, scale)
+	to := toMinimap(fyne.NewPos(bounds.MaxX, bounds.MaxY), area, scale)
+	box := image.Rect(int(from.X), int(from.Y), int(to.X)+1, int(to.Y)+1).Intersect(r.thumbnail.Rect)
+	boxColor := color.NRGBA{R: 120, G: 120, B: 120, A: 255}
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			r.thumbnail.SetNRGBA(x, y, boxColor)
+		}
+	}
+}
+
+func (r *minimapRenderer) Objects() []fyne.CanvasObject { return r.objects }
+func (r *minimapRenderer) Destroy()                     {}
+func (r *minimapRenderer) Layout(size fyne.Size)        { r.background.Resize(size) }
+func (r *minimapRenderer) MinSize() fyne.Size           { return fyne.NewSize(minimapWidth, minimapHeight) }
+
+// minimapOverlay places the minimap in the bottom right corner, above the board
+func (b *BoardWidget) minimapOverlay() fyne.CanvasObject {
+	return container.NewVBox(
+		layout.NewSpacer(),
+		container.NewHBox(layout.NewSpacer(), b.minimap),
+	)
+}
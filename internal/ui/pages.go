@@ -0,0 +1,378 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+// Size of a new page and the gap between pages laid out side by side, in
+// board units
+const (
+	pageWidth  float32 = 1600
+	pageHeight float32 = 900
+	pageGap            = 4 * GridSize
+)
+
+// frameColor outlines pages on the board
+var frameColor = color.NRGBA{R: 120, G: 120, B: 120, A: 160}
+
+// SetFrames replaces the pages of the board, in page order
+func (b *BoardWidget) SetFrames(frames []state.Frame) {
+	b.mu.Lock()
+	b.frames = append([]state.Frame(nil), frames...)
+	b.mu.Unlock()
+	fyne.Do(b.Refresh)
+	fyne.Do(func() {
+		b.frameList.Refresh()
+		b.participantList.Refresh()
+	})
+}
+
+// Frames returns the pages of the board, in page order
+func (b *BoardWidget) Frames() []state.Frame {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]state.Frame(nil), b.frames...)
+}
+
+// frameOf returns the page under the middle of view. Callers must hold b.mu.
+func (b *BoardWidget) frameOf(view ViewRect) (state.Frame, bool) {
+	x, y := view.X+view.Width/2, view.Y+view.Height/2
+	for _, f := range b.frames {
+		if f.Contains(x, y) {
+			return f, true
+		}
+	}
+	return state.Frame{}, false
+}
+
+// CurrentFrame returns the page in view, if any
+func (b *BoardWidget) CurrentFrame() (state.Frame, bool) {
+	view := b.VisibleRect()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.frameOf(view)
+}
+
+// frameRect returns the area of the board f covers
+func frameRect(f state.Frame) spatial.Rect {
+	return spatial.Rect{MinX: f.X, MinY: f.Y, MaxX: f.X + f.Width, MaxY: f.Y + f.Height}
+}
+
+// ShowFrame fits the view to the page with the given ID
+func (b *BoardWidget) ShowFrame(id string) {
+	for _, f := range b.Frames() {
+		if f.ID == id {
+			b.fitView(fyne.NewPos(f.X, f.Y), fyne.NewPos(f.X+f.Width, f.Y+f.Height))
+			return
+		}
+	}
+}
+
+// setFrame hands a new version of a page to OnFrameChanged
+func (b *BoardWidget) setFrame(f state.Frame) {
+	if b.OnFrameChanged != nil {
+		b.OnFrameChanged(f)
+	}
+}
+
+// newFrame returns a page of the given size right of the others, or in the
+// middle of the view if there are none yet, last in page order
+func (b *BoardWidget) newFrame(name string, width, height float32) state.Frame {
+	view := b.VisibleRect()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	f := state.Frame{
+		ID:     "frame-" + generateID(),
+		Name:   name,
+		X:      view.X + view.Width/2 - width/2,
+		Y:      view.Y + view.Height/2 - height/2,
+		Width:  width,
+		Height: height,
+	}
+	for i, other := range b.frames {
+		if i == 0 || other.X+other.Width+pageGap > f.X {
+			f.X, f.Y = other.X+other.Width+pageGap, other.Y
+		}
+		f.Position = max(f.Position, other.Position+1)
+	}
+	return f
+}
+
+// AddPage adds an empty page after the others and shows it
+func (b *BoardWidget) AddPage() {
+	f := b.newFrame(fmt.Sprintf("Page %d", len(b.Frames())+1), pageWidth, pageHeight)
+	b.setFrame(f)
+	b.ShowFrame(f.ID)
+}
+
+// pagePaths returns the paths whose middle is on f, leaving out those on
+// locked or hidden layers. Callers must hold b.mu.
+func (b *BoardWidget) pagePaths(f state.Frame) []*Path {
+	var paths []*Path
+	for _, id := range b.index.QueryRect(frameRect(f)) {
+		p := b.byID[id]
+		if p == nil || b.locked(p) {
+			continue
+		}
+		if box, ok := state.PathBounds(*p); ok && f.Contains((box.MinX+box.MaxX)/2, (box.MinY+box.MaxY)/2) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// DuplicatePage adds a copy of a page after the others, with a copy of what
+// is on it, and shows it. Paths on locked or hidden layers are not copied.
+func (b *BoardWidget) DuplicatePage(id string) {
+	var source state.Frame
+	found := false
+	for _, f := range b.Frames() {
+		if f.ID == id {
+			source, found = f, true
+		}
+	}
+	if !found {
+		return
+	}
+	f := b.newFrame(source.Name+" copy", source.Width, source.Height)
+	b.mu.RLock()
+	onPage := make(map[*Path]bool)
+	for _, p := range b.pagePaths(source) {
+		onPage[p] = true
+	}
+	var paths []Path
+	for _, p := range b.drawnLocked() {
+		if onPage[p] {
+			paths = append(paths, *p)
+		}
+	}
+	b.mu.RUnlock()
+	copies := b.copiesOf(paths, state.Translate(f.X-source.X, f.Y-source.Y))
+	for i := range copies {
+		copies[i].Layer, copies[i].Z = copies[i].LayerID(), 0 // Each stays on its layer
+	}
+	b.setFrame(f)
+	if len(copies) > 0 && b.OnAddPaths != nil {
+		b.OnAddPaths(copies)
+	}
+	b.ShowFrame(f.ID)
+}
+
+// DeletePage removes a page and what is on it, except paths on locked or
+// hidden layers. Undo brings both back.
+func (b *BoardWidget) DeletePage(id string) {
+	for _, f := range b.Frames() {
+		if f.ID != id {
+			continue
+		}
+		b.mu.RLock()
+		var ids []string
+		for _, p := range b.pagePaths(f) {
+			ids = append(ids, p.ID)
+		}
+		b.mu.RUnlock()
+		if b.OnDeletePage != nil {
+			b.OnDeletePage(id, ids)
+		}
+		return
+	}
+}
+
+// MovePage moves a page later in the page order by steps places, or earlier
+// for negative steps
+func (b *BoardWidget) MovePage(id string, steps int) {
+	frames := b.Frames()
+	for i, f := range frames {
+		if f.ID != id {
+			continue
+		}
+		positions := make([]float64, 0, len(frames)-1)
+		for j, other := range frames {
+			if j != i {
+				positions = append(positions, other.Position)
+			}
+		}
+		to := min(max(i+steps, 0), len(positions))
+		if to != i {
+			f.Position = positionAt(positions, to)
+			b.setFrame(f)
+		}
+		return
+	}
+}
+
+// Follow keeps the view on the page the participant with the given ID is
+// on, whenever they go to another one. An empty ID stops following.
+func (b *BoardWidget) Follow(id string) {
+	b.mu.Lock()
+	b.following = id
+	view, ok := b.remoteViews[id]
+	b.mu.Unlock()
+	fyne.Do(b.participantList.Refresh)
+	if id == "" {
+		b.SetStatus("Stopped following")
+		return
+	}
+	b.SetStatus("Following " + id)
+	if ok {
+		b.followPage(view)
+	}
+}
+
+// followPage shows the page under view, the view of whoever we follow, unless
+// we are on it already
+func (b *BoardWidget) followPage(view ViewRect) {
+	b.mu.RLock()
+	theirs, ok := b.frameOf(view)
+	b.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if ours, ok := b.CurrentFrame(); !ok || ours.ID != theirs.ID {
+		fyne.Do(func() { b.ShowFrame(theirs.ID) })
+	}
+}
+
+// pageOf describes which page a participant is looking at, for the
+// participant list. Callers must hold b.mu.
+func (b *BoardWidget) pageOf(id string) string {
+	view, ok := b.remoteViews[id]
+	if id == b.LocalClientID {
+		view, ok = b.VisibleRect(), true
+	}
+	if !ok {
+		return ""
+	}
+	if f, ok := b.frameOf(view); ok {
+		return f.Name
+	}
+	return ""
+}
+
+// frameObjects returns the outline and name of each page, placed for viewport
+func (b *BoardWidget) frameObjects(viewport Viewport) []fyne.CanvasObject {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	objects := make([]fyne.CanvasObject, 0, 2*len(b.frames))
+	for _, f := range b.frames {
+		outline := canvas.NewRectangle(color.Transparent)
+		outline.StrokeColor = frameColor
+		outline.StrokeWidth = 1
+		outline.Move(viewport.ToScreen(fyne.NewPos(f.X, f.Y)))
+		outline.Resize(fyne.NewSize(f.Width*viewport.Scale, f.Height*viewport.Scale))
+		name := canvas.NewText(f.Name, frameColor)
+		name.TextSize = theme.CaptionTextSize()
+		name.Move(outline.Position().SubtractXY(0, name.MinSize().Height))
+		objects = append(objects, outline, name)
+	}
+	return objects
+}
+
+func (b *BoardWidget) newFrameList() *widget.List {
+	return widget.NewList(
+		func() int {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			return len(b.frames)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("page")
+		},
+		func(row widget.ListItemID, item fyne.CanvasObject) {
+			view := b.VisibleRect()
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			if row >= len(b.frames) {
+				return
+			}
+			f := b.frames[row]
+			text := fmt.Sprintf("%d. %s", row+1, f.Name)
+			if current, ok := b.frameOf(view); ok && current.ID == f.ID {
+				text += " (here)"
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+}
+
+// pagesPanel is the side panel listing the pages of the board. Picking one
+// shows it; pages can be added, duplicated, renamed, moved and deleted.
+func (b *BoardWidget) pagesPanel(window fyne.Window) fyne.CanvasObject {
+	selected := ""
+	b.frameList.OnSelected = func(row widget.ListItemID) {
+		frames := b.Frames()
+		if row < 0 || row >= len(frames) {
+			return
+		}
+		selected = frames[row].ID
+		if b.following != "" {
+			b.Follow("")
+		}
+		b.ShowFrame(selected)
+	}
+	b.frameList.OnUnselected = func(widget.ListItemID) { selected = "" }
+
+	rename := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+		for _, f := range b.Frames() {
+			if f.ID != selected {
+				continue
+			}
+			entry := widget.NewEntry()
+			entry.SetText(f.Name)
+			entry.Validator = func(s string) error {
+				if s == "" || len(s) > state.MaxFrameName {
+					return fmt.Errorf("a name has 1 to %d characters", state.MaxFrameName)
+				}
+				return nil
+			}
+			dialog.ShowForm("Rename page", "Rename", "Cancel", []*widget.FormItem{widget.NewFormItem("Name", entry)}, func(ok bool) {
+				if ok {
+					f.Name = entry.Text
+					b.setFrame(f)
+				}
+			}, window)
+		}
+	})
+	remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		if selected == "" {
+			return
+		}
+		id := selected
+		dialog.ShowConfirm("Delete page", "Delete this page and everything on it?", func(ok bool) {
+			if ok {
+				b.DeletePage(id)
+				b.frameList.UnselectAll()
+			}
+		}, window)
+	})
+	move := func(steps int) func() {
+		return func() {
+			b.MovePage(selected, steps)
+			for i, f := range b.Frames() {
+				if f.ID == selected {
+					b.frameList.Select(i)
+				}
+			}
+		}
+	}
+	buttons := container.NewHBox(
+		widget.NewButtonWithIcon("", theme.ContentAddIcon(), b.AddPage),
+		widget.NewButtonWithIcon("", theme.ContentCopyIcon(), func() { b.DuplicatePage(selected) }),
+		rename,
+		widget.NewButtonWithIcon("", theme.MoveUpIcon(), move(-1)),
+		widget.NewButtonWithIcon("", theme.MoveDownIcon(), move(1)),
+		remove,
+	)
+	return container.NewBorder(widget.NewLabel("Pages"), buttons, nil, nil, b.frameList)
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Participant is a connected user shown in the participant list
+type Participant struct {
+	ID      string        `json:"id"`
+	Latency time.Duration `json:"latency"` // Round trip to the host, 0 for the host itself
+}
+
+// formatLatency describes the connection quality for a round-trip time
+func formatLatency(d time.Duration) string {
+	ms := d.Milliseconds()
+	switch {
+	case d <= 0:
+		return "local"
+	case d < 100*time.Millisecond:
+		return fmt.Sprintf("%d ms (good)", ms)
+	case d < 300*time.Millisecond:
+		return fmt.Sprintf("%d ms (fair)", ms)
+	default:
+		return fmt.Sprintf("%d ms (poor)", ms)
+	}
+}
+
+// SetParticipants replaces the participant list
+func (b *BoardWidget) SetParticipants(participants []Participant) {
+	b.mu.Lock()
+	b.participants = append([]Participant(nil), participants...)
+	// Forget the views of everyone who left
+	present := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		present[p.ID] = true
+	}
+	for id := range b.remoteViews {
+		if !present[id] {
+			delete(b.remoteViews, id)
+		}
+	}
+	// Stop following a presenter who left
+	presenterLeft := b.presenter != "" && !b.presentingLocked() && !present[b.presenter]
+	if presenterLeft {
+		b.presenter = ""
+	}
+	b.mu.Unlock()
+	if presenterLeft {
+		b.presenterChanged()
+	}
+	fyne.Do(b.participantList.Refresh)
+	fyne.Do(b.minimap.Refresh)
+}
+
+// SetLatency shows our own round-trip time in the status bar
+func (b *BoardWidget) SetLatency(d time.Duration) {
+	fyne.Do(func() {
+		b.latencyLabel.SetText("Latency: " + formatLatency(d))
+	})
+}
+
+func (b *BoardWidget) newParticipantList() *widget.List {
+	return widget.NewList(
+		func() int {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			return len(b.participants)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("participant")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			if id >= len(b.participants) {
+				return
+			}
+			p := b.participants[id]
+			name := p.ID
+			switch p.ID {
+			case b.LocalClientID:
+				name += " (you)"
+			case b.following:
+				name += " (following)"
+			}
+			if p.ID == b.presenter {
+				name += " (presenting)"
+			}
+			text := name + " - " + formatLatency(p.Latency)
+			if page := b.pageOf(p.ID); page != "" {
+				text += " - " + page
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+}
+
+// participantsPanel is the side panel listing everyone on the board, and the
+// page each is on. Picking someone follows them from page to page; picking
+// them again, or ourselves, stops. From here we can also present, or bring
+// everyone to our view.
+func (b *BoardWidget) participantsPanel() fyne.CanvasObject {
+	b.participantList.OnSelected = func(row widget.ListItemID) {
+		b.mu.RLock()
+		id := ""
+		if row < len(b.participants) {
+			id = b.participants[row].ID
+		}
+		following := b.following
+		b.mu.RUnlock()
+		if id == b.LocalClientID || id == following {
+			id = ""
+		}
+		b.Follow(id)
+		b.participantList.UnselectAll()
+	}
+	width := canvas.NewRectangle(color.Transparent)
+	width.SetMinSize(fyne.NewSize(200, 0))
+	summon := widget.NewButtonWithIcon("Summon everyone", theme.HomeIcon(), b.Summon)
+	buttons := container.NewGridWithColumns(2, b.presentButton, summon)
+	list := container.NewBorder(widget.NewLabel("Participants"), buttons, nil, nil, b.participantList)
+	return container.NewStack(width, list)
+}
+
+// statusArea is the status bar with the zoom level and connection latency on the right
+func (b *BoardWidget) statusArea() fyne.CanvasObject {
+	return container.NewBorder(nil, nil, nil, container.NewHBox(b.pageNav.box, b.zoomLabel, b.latencyLabel), b.statusBar)
+}
@@ -0,0 +1,224 @@
+package ui
+
+import (
+	"math"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// glideDuration is how long the view takes to catch up with a presenter or
+// summons. Presenters move in many small steps, so each glide is short.
+const glideDuration = 250 * time.Millisecond
+
+func (b *BoardWidget) newPresenterButtons() (present, back *widget.Button) {
+	present = widget.NewButtonWithIcon("Present", theme.MediaPlayIcon(), b.TogglePresenting)
+	back = widget.NewButtonWithIcon("Return to presenter", theme.NavigateBackIcon(), b.ReturnToPresenter)
+	back.Importance = widget.HighImportance
+	back.Hide()
+	return present, back
+}
+
+// presentingLocked reports whether we are the presenter. Callers must hold b.mu.
+func (b *BoardWidget) presentingLocked() bool {
+	return b.presenter != "" && b.presenter == b.LocalClientID
+}
+
+// Presenter returns the ID of the participant presenting, ourselves included,
+// or "" if nobody is
+func (b *BoardWidget) Presenter() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.presenter
+}
+
+// Presenting reports whether we are presenting
+func (b *BoardWidget) Presenting() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.presentingLocked()
+}
+
+// Present starts or stops presenting. While we present, everyone else's view
+// follows ours; whoever presented before us stops.
+func (b *BoardWidget) Present(on bool) {
+	b.mu.Lock()
+	if on == b.presentingLocked() || b.LocalClientID == "" {
+		b.mu.Unlock()
+		return
+	}
+	if on {
+		b.presenter = b.LocalClientID
+	} else {
+		b.presenter = ""
+	}
+	b.detached = false
+	b.mu.Unlock()
+	b.presenterChanged()
+	if on {
+		b.SetStatus("Presenting - everyone follows your view")
+	} else {
+		b.SetStatus("Stopped presenting")
+	}
+	if b.OnPresent != nil {
+		b.OnPresent(on)
+	}
+}
+
+// TogglePresenting starts presenting, or stops if we are
+func (b *BoardWidget) TogglePresenting() {
+	b.Present(!b.Presenting())
+}
+
+// SetPresenter records that the participant with the given ID started or
+// stopped presenting. While someone else presents, our view tracks theirs
+// until we move it ourselves.
+func (b *BoardWidget) SetPresenter(id string, presenting bool) {
+	b.mu.Lock()
+	switch {
+	case presenting:
+		b.presenter, b.detached, b.following = id, false, ""
+	case id == b.presenter:
+		b.presenter = ""
+	default:
+		b.mu.Unlock()
+		return
+	}
+	view, ok := b.remoteViews[id]
+	b.mu.Unlock()
+	b.presenterChanged()
+	if !presenting {
+		b.SetStatus(id + " stopped presenting")
+		return
+	}
+	b.SetStatus(id + " is presenting")
+	if ok {
+		fyne.Do(func() { b.glideTo(view) })
+	}
+}
+
+// trackPresenter moves our view along with the presenter's, unless we moved
+// away from it
+func (b *BoardWidget) trackPresenter(id string, view ViewRect) bool {
+	b.mu.RLock()
+	tracking := id == b.presenter && !b.presentingLocked() && !b.detached
+	b.mu.RUnlock()
+	if tracking {
+		fyne.Do(func() { b.glideTo(view) })
+	}
+	return tracking
+}
+
+// ReturnToPresenter goes back to following the presenter's view after we
+// moved away from it
+func (b *BoardWidget) ReturnToPresenter() {
+	b.mu.Lock()
+	b.detached = false
+	view, ok := b.remoteViews[b.presenter]
+	b.mu.Unlock()
+	b.presenterChanged()
+	if ok {
+		b.glideTo(view)
+	}
+}
+
+// leavePresenter stops following the presenter once we move the view
+// ourselves, and offers to return
+func (b *BoardWidget) leavePresenter() {
+	b.mu.Lock()
+	leaving := b.presenter != "" && !b.presentingLocked() && !b.detached
+	b.detached = b.detached || leaving
+	b.mu.Unlock()
+	if leaving {
+		b.presenterChanged()
+	}
+}
+
+// Summon brings everyone to what we are looking at
+func (b *BoardWidget) Summon() {
+	if b.OnSummon != nil {
+		b.OnSummon(b.VisibleRect())
+	}
+	b.SetStatus("Summoned everyone here")
+}
+
+// Summoned takes us to the view of the participant who summoned everyone
+func (b *BoardWidget) Summoned(id string, view ViewRect) {
+	if !view.usable() {
+		return
+	}
+	b.SetStatus(id + " summoned everyone")
+	fyne.Do(func() { b.glideTo(view) })
+}
+
+// presenterChanged updates the presenter controls and participant list
+func (b *BoardWidget) presenterChanged() {
+	b.mu.RLock()
+	presenting := b.presentingLocked()
+	detached := b.presenter != "" && b.detached
+	b.mu.RUnlock()
+	fyne.Do(func() {
+		if presenting {
+			b.presentButton.SetText("Stop presenting")
+			b.presentButton.SetIcon(theme.MediaStopIcon())
+		} else {
+			b.presentButton.SetText("Present")
+			b.presentButton.SetIcon(theme.MediaPlayIcon())
+		}
+		if detached {
+			b.returnButton.Show()
+		} else {
+			b.returnButton.Hide()
+		}
+		b.participantList.Refresh()
+	})
+}
+
+// viewportFor returns the viewport that shows view as large as fits, centred
+func (b *BoardWidget) viewportFor(view ViewRect) Viewport {
+	size := b.Size()
+	v := Viewport{Scale: clampZoom(min(size.Width/view.Width, size.Height/view.Height))}
+	v.Offset = fyne.NewPos(
+		size.Width/2-(view.X+view.Width/2)*v.Scale,
+		size.Height/2-(view.Y+view.Height/2)*v.Scale,
+	)
+	return v
+}
+
+// glideTo moves the view smoothly to show view, zooming at an even pace. A
+// glide under way is taken over from where it got to. Views without an area
+// are ignored.
+func (b *BoardWidget) glideTo(view ViewRect) {
+	if !view.usable() {
+		return
+	}
+	if b.glide != nil {
+		b.glide.Stop()
+	}
+	from, to := b.viewport, b.viewportFor(view)
+	middle := b.center()
+	start, end := from.ToBoard(middle), to.ToBoard(middle)
+	zoom := float64(to.Scale / from.Scale)
+	b.glide = fyne.NewAnimation(glideDuration, func(t float32) {
+		scale := from.Scale * float32(math.Pow(zoom, float64(t)))
+		at := fyne.NewPos(start.X+(end.X-start.X)*t, start.Y+(end.Y-start.Y)*t)
+		b.steering = true
+		b.SetViewport(Viewport{Scale: scale, Offset: fyne.NewPos(middle.X-at.X*scale, middle.Y-at.Y*scale)})
+		b.steering = false
+	})
+	b.glide.Curve = fyne.AnimationEaseOut
+	b.glide.Start()
+}
+
+// presenterOverlay places the button to return to the presenter at the top
+// of the board, above it
+func (b *BoardWidget) presenterOverlay() fyne.CanvasObject {
+	return container.NewVBox(
+		container.NewHBox(layout.NewSpacer(), b.returnButton, layout.NewSpacer()),
+		layout.NewSpacer(),
+	)
+}
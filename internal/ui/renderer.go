@@ -0,0 +1,439 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"sort"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"golang.org/x/image/vector"
+
+	"MyLocalBoard/internal/export"
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/stroke"
+)
+
+// eraserTrailColor marks the eraser preview, which is never a real path
+const eraserTrailColor = "eraser"
+
+// cullMargin keeps paths just outside the view rendered, so small pans do not
+// need a new visible set, in pixels
+const cullMargin float32 = 64
+
+// minLineWidth keeps strokes visible when zoomed far out, in pixels
+const minLineWidth float32 = 1
+
+func (b *BoardWidget) CreateRenderer() fyne.WidgetRenderer {
+	r := &boardWidgetRenderer{
+		board:      b,
+		background: canvas.NewRectangle(color.White),
+		layer:      image.NewRGBA(image.Rect(0, 0, 1, 1)),
+		raster:     vector.NewRasterizer(1, 1),
+		frames:     container.NewWithoutLayout(),
+		texts:      container.NewWithoutLayout(),
+		preview:    container.NewWithoutLayout(),
+		editor:     container.NewWithoutLayout(),
+		cache:      make(map[*Path]*renderedPath),
+		byID:       make(map[string]*renderedPath),
+	}
+	r.world = canvas.NewImageFromImage(r.layer)
+	r.world.ScaleMode = canvas.ImageScaleFastest
+	r.objects = []fyne.CanvasObject{r.background, r.frames, r.world, r.texts, r.preview, r.editor}
+	r.Refresh()
+	return r
+}
+
+// renderedPath caches what drawing a path needs
+type renderedPath struct {
+	path      *Path
+	rank      int           // Position of the path's layer, bottom first
+	lo, hi    fyne.Position // Bounding box in board units
+	color     color.NRGBA
+	points    []fyne.Position // Polyline, with Béziers flattened
+	widths    []float32       // Width at each point
+	fill      []fyne.Position // Area to fill first, for filled shapes
+	fillColor color.NRGBA
+	lines     []fyne.CanvasObject
+	text      []fyne.CanvasObject // Objects of a text element
+	textScale float32             // Scale text was laid out at
+	textShown bool                // text includes the lines, which are hidden while editing
+}
+
+func newRenderedPath(p *Path) *renderedPath {
+	rp := &renderedPath{path: p, color: pathColor(p)}
+	if box, ok := state.PathBounds(*p); ok {
+		rp.lo, rp.hi = fyne.NewPos(box.MinX, box.MinY), fyne.NewPos(box.MaxX, box.MaxY)
+	}
+	rp.points, rp.widths = p.PolylineWidths()
+	if fill, c, ok := p.FillPolygon(); ok {
+		rp.fill = fill
+		rp.fillColor = color.NRGBAModel.Convert(state.HexToColor(c)).(color.NRGBA)
+	}
+	return rp
+}
+
+func pathColor(p *Path) color.NRGBA {
+	if p.Color == eraserTrailColor {
+		return color.NRGBA{R: 128, G: 128, B: 128, A: 80}
+	}
+	return color.NRGBAModel.Convert(state.HexToColor(p.Color)).(color.NRGBA)
+}
+
+// widthAt returns the width of a path at point i
+func widthAt(p *Path, i int) float32 {
+	if len(p.Widths) == len(p.Points) && !p.Bezier {
+		return p.Widths[i]
+	}
+	return p.Stroke
+}
+
+// extend adds lines for points appended since the last call. It is how the
+// stroke being drawn grows without redrawing what is already there; lines
+// are cheap to add one at a time, so previews use them rather than outlines.
+func (rp *renderedPath) extend(scale float32) []fyne.CanvasObject {
+	points := rp.path.Polyline()
+	for i := len(rp.lines); i+1 < len(points); i++ {
+		line := canvas.NewLine(rp.color)
+		width := (widthAt(rp.path, i) + widthAt(rp.path, i+1)) / 2
+		line.StrokeWidth = max(width*scale, minLineWidth)
+		line.Position1 = fyne.NewPos(points[i].X*scale, points[i].Y*scale)
+		line.Position2 = fyne.NewPos(points[i+1].X*scale, points[i+1].Y*scale)
+		rp.lines = append(rp.lines, line)
+	}
+	return rp.lines
+}
+
+// boardWidgetRenderer draws the visible paths as filled outlines, and images
+// as their pictures, into one layer image, covering the view plus a margin. Text elements are canvas
+// objects above it, so the text stays sharp. A refresh only does the work
+// the change needs:
+//   - new or removed paths update the cache when the board's generation changes
+//   - an appended path is drawn on top of the layer
+//   - a pan moves the layer, and redraws it once the view leaves the area
+//     the layer covers
+//   - a zoom redraws the layer
+//   - the active tool's preview is extended point by point
+type boardWidgetRenderer struct {
+	board      *BoardWidget
+	background *canvas.Rectangle
+	frames     *fyne.Container // Outlines and names of the pages
+	world      *canvas.Image   // Shows layer
+	texts      *fyne.Container // Visible text elements
+	preview    *fyne.Container // What the active tool is in the middle of
+	editor     *fyne.Container // Holds the text editor while editing
+	objects    []fyne.CanvasObject
+
+	layer   *image.RGBA
+	raster  *vector.Rasterizer
+	density float32 // Layer pixels per canvas unit
+
+	visibleTexts []*renderedPath
+	editing      string // ID of the element the texts were laid out without
+
+	cache      map[*Path]*renderedPath
+	byID       map[string]*renderedPath // Cache entries by path ID, to resolve index hits
+	generation uint64
+	culled     ViewRect // Board area the layer covers
+	scale      float32  // Scale the layer was drawn at
+	size       fyne.Size
+
+	previews     []*renderedPath
+	previewScale float32
+}
+
+func (r *boardWidgetRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *boardWidgetRenderer) Refresh() {
+	b := r.board
+	viewport := b.viewport
+	view := b.VisibleRect()
+
+	b.mu.RLock()
+	recull := viewport.Scale != r.scale || r.size != b.Size() || r.density != canvasDensity(b) || !r.covers(view)
+	switch {
+	case r.generation == b.generation:
+	case r.generation+1 == b.generation && b.lastAppend == b.generation && !recull && b.drawnOnTop(b.paths[len(b.paths)-1]):
+		r.appendPath(b.paths[len(b.paths)-1])
+	default:
+		r.syncCache()
+		recull = true
+	}
+	if recull {
+		r.cull(view, viewport.Scale)
+	} else if editing, _ := b.Editing(); editing != r.editing {
+		r.layoutTexts()
+	}
+	b.mu.RUnlock()
+
+	r.world.Move(viewport.ToScreen(fyne.NewPos(r.culled.X, r.culled.Y)))
+	r.texts.Move(viewport.Offset)
+	r.frames.Objects = b.frameObjects(viewport)
+	r.frames.Refresh()
+	r.refreshPreview(viewport)
+	r.refreshEditor(viewport)
+	canvas.Refresh(b)
+}
+
+// canvasDensity returns the pixels per canvas unit of the window showing b
+func canvasDensity(b *BoardWidget) float32 {
+	if app := fyne.CurrentApp(); app != nil {
+		if c := app.Driver().CanvasForObject(b); c != nil {
+			return c.Scale()
+		}
+	}
+	return 1
+}
+
+// syncCache drops removed paths and adds new ones.
+// Callers must hold the board's read lock.
+func (r *boardWidgetRenderer) syncCache() {
+	present := make(map[*Path]bool, len(r.board.paths))
+	clear(r.byID)
+	for _, p := range r.board.paths {
+		present[p] = true
+		rp, ok := r.cache[p]
+		if !ok {
+			rp = newRenderedPath(p)
+			r.cache[p] = rp
+		}
+		rp.rank = r.board.layerRank(p)
+		r.byID[p.ID] = rp
+	}
+	for p := range r.cache {
+		if !present[p] {
+			delete(r.cache, p)
+		}
+	}
+	r.generation = r.board.generation
+}
+
+// appendPath draws a path appended to the board on top of the layer, without
+// redrawing everything else. Callers must hold the board's read lock.
+func (r *boardWidgetRenderer) appendPath(p *Path) {
+	rp := newRenderedPath(p)
+	rp.rank = r.board.layerRank(p)
+	r.cache[p] = rp
+	r.byID[p.ID] = rp
+	switch {
+	case !rp.overlaps(r.culled):
+	case p.Text != nil:
+		r.visibleTexts = append(r.visibleTexts, rp)
+		r.layoutTexts()
+	default:
+		r.draw(rp)
+		r.world.Refresh()
+	}
+	r.generation = r.board.generation
+}
+
+func (rp *renderedPath) overlaps(area ViewRect) bool {
+	return rp.hi.X >= area.X && rp.lo.X <= area.X+area.Width &&
+		rp.hi.Y >= area.Y && rp.lo.Y <= area.Y+area.Height
+}
+
+// covers reports whether the layer still contains everything in view
+func (r *boardWidgetRenderer) covers(view ViewRect) bool {
+	return view.X >= r.culled.X && view.Y >= r.culled.Y &&
+		view.X+view.Width <= r.culled.X+r.culled.Width &&
+		view.Y+view.Height <= r.culled.Y+r.culled.Height
+}
+
+// cull redraws the layer with the paths overlapping view, plus a margin, as
+// found by the board's spatial index. Callers must hold the board's read lock.
+func (r *boardWidgetRenderer) cull(view ViewRect, scale float32) {
+	margin := cullMargin / scale
+	area := ViewRect{X: view.X - margin, Y: view.Y - margin, Width: view.Width + 2*margin, Height: view.Height + 2*margin}
+	r.culled, r.scale, r.size = area, scale, r.board.Size()
+	r.density = canvasDensity(r.board)
+
+	// area is the widget plus the margin, in pixels
+	size := r.size.AddWidthHeight(2*cullMargin, 2*cullMargin)
+	w := max(int(math.Ceil(float64(size.Width*r.density))), 1)
+	h := max(int(math.Ceil(float64(size.Height*r.density))), 1)
+	if r.layer.Rect.Dx() != w || r.layer.Rect.Dy() != h {
+		r.layer = image.NewRGBA(image.Rect(0, 0, w, h))
+		r.world.Image = r.layer
+	} else {
+		clear(r.layer.Pix)
+	}
+	r.world.Resize(size)
+
+	ids := r.board.index.QueryRect(spatial.Rect{MinX: area.X, MinY: area.Y, MaxX: area.X + area.Width, MaxY: area.Y + area.Height})
+	visible := make([]*renderedPath, 0, len(ids))
+	for _, id := range ids {
+		if rp, ok := r.byID[id]; ok && !r.board.hidden(rp.path) {
+			visible = append(visible, rp)
+		}
+	}
+	// Lower layers go under higher ones, and within a layer paths are
+	// stacked the same on every peer
+	sort.Slice(visible, func(i, j int) bool {
+		if visible[i].rank != visible[j].rank {
+			return visible[i].rank < visible[j].rank
+		}
+		return state.Below(*visible[i].path, *visible[j].path)
+	})
+	r.visibleTexts = r.visibleTexts[:0]
+	for _, rp := range visible {
+		if rp.path.Text != nil {
+			r.visibleTexts = append(r.visibleTexts, rp)
+			continue
+		}
+		r.draw(rp)
+	}
+	r.world.Refresh()
+	r.layoutTexts()
+}
+
+// layoutTexts shows the visible text elements, leaving out the text of the
+// one being edited, which the editor shows instead
+func (r *boardWidgetRenderer) layoutTexts() {
+	r.editing, _ = r.board.Editing()
+	var objects []fyne.CanvasObject
+	for _, rp := range r.visibleTexts {
+		objects = append(objects, rp.textObjects(r.scale, rp.path.ID != r.editing)...)
+	}
+	r.texts.Objects = objects
+	r.texts.Refresh()
+}
+
+// textObjects returns the objects drawing a text element at scale: the
+// background of a sticky note, and the lines of text if shown
+func (rp *renderedPath) textObjects(scale float32, shown bool) []fyne.CanvasObject {
+	if rp.text != nil && rp.textScale == scale && rp.textShown == shown {
+		return rp.text
+	}
+	t := rp.path.Text
+	pos, size := rp.path.TextBox()
+	rp.text, rp.textScale, rp.textShown = nil, scale, shown
+	if t.Note != "" {
+		background := canvas.NewRectangle(state.HexToColor(t.Note))
+		background.CornerRadius = state.NoteCornerRadius * scale
+		background.StrokeColor = color.NRGBA{A: 40}
+		background.StrokeWidth = 1
+		background.Move(fyne.NewPos(pos.X*scale, pos.Y*scale))
+		background.Resize(fyne.NewSize(size.Width*scale, size.Height*scale))
+		rp.text = append(rp.text, background)
+	}
+	if !shown {
+		return rp.text
+	}
+	for _, line := range state.LayoutText(*rp.path, measureText) {
+		text := canvas.NewText(line.Text, rp.color)
+		text.TextSize = t.Size * scale
+		text.Move(fyne.NewPos(line.Pos.X*scale, line.Pos.Y*scale))
+		rp.text = append(rp.text, text)
+	}
+	return rp.text
+}
+
+// refreshEditor places the text editor over the element being edited
+func (r *boardWidgetRenderer) refreshEditor(viewport Viewport) {
+	s := r.board.editing
+	if s == nil {
+		r.editor.Objects = nil
+		r.editor.Refresh()
+		return
+	}
+	pos, size := s.path.TextBox()
+	editor := r.board.editor
+	editor.Move(viewport.ToScreen(pos))
+	editor.Resize(fyne.NewSize(size.Width*viewport.Scale, size.Height*viewport.Scale).Max(editor.MinSize()))
+	if len(r.editor.Objects) == 0 {
+		r.editor.Objects = []fyne.CanvasObject{editor}
+		r.editor.Refresh()
+	}
+}
+
+// draw fills the outline of rp into the layer
+func (r *boardWidgetRenderer) draw(rp *renderedPath) {
+	px := r.scale * r.density // Layer pixels per board unit
+	toLayer := func(p fyne.Position) (float32, float32) {
+		return (p.X - r.culled.X) * px, (p.Y - r.culled.Y) * px
+	}
+	x0, y0 := toLayer(rp.lo)
+	x1, y1 := toLayer(rp.hi)
+	box := image.Rect(int(math.Floor(float64(x0)))-1, int(math.Floor(float64(y0)))-1,
+		int(math.Ceil(float64(x1)))+1, int(math.Ceil(float64(y1)))+1).Intersect(r.layer.Rect)
+	if box.Empty() || len(rp.points) == 0 {
+		return
+	}
+
+	widths := rp.widths
+	if minWidth := minLineWidth * r.density / px; slices.Min(widths) < minWidth {
+		widths = make([]float32, len(rp.widths))
+		for i, w := range rp.widths {
+			widths[i] = max(w, minWidth)
+		}
+	}
+
+	ox, oy := float32(box.Min.X), float32(box.Min.Y)
+	addPolygon := func(polygon []fyne.Position) {
+		for i, p := range polygon {
+			x, y := toLayer(p)
+			if i == 0 {
+				r.raster.MoveTo(x-ox, y-oy)
+			} else {
+				r.raster.LineTo(x-ox, y-oy)
+			}
+		}
+		r.raster.ClosePath()
+	}
+	if rp.path.Image != nil {
+		toLayer := state.Translate(-r.culled.X, -r.culled.Y).Then(state.Transform{A: px, D: px})
+		if !export.DrawImage(r.layer, *rp.path, r.board.Assets, toLayer) {
+			r.raster.Reset(box.Dx(), box.Dy())
+			addPolygon(rp.points)
+			r.raster.Draw(r.layer, box, image.NewUniform(state.HexToColor(state.MissingImageColor)), image.Point{})
+		}
+		return
+	}
+	if rp.fill != nil {
+		r.raster.Reset(box.Dx(), box.Dy())
+		addPolygon(rp.fill)
+		r.raster.Draw(r.layer, box, image.NewUniform(rp.fillColor), image.Point{})
+	}
+	r.raster.Reset(box.Dx(), box.Dy())
+	stroke.EachOutlinePolygon(rp.points, widths, addPolygon)
+	r.raster.Draw(r.layer, box, image.NewUniform(rp.color), image.Point{})
+}
+
+// refreshPreview mirrors the active tool's preview, extending the objects of
+// a preview path that is still growing.
+func (r *boardWidgetRenderer) refreshPreview(viewport Viewport) {
+	paths := r.board.activeTool.Preview()
+	previews := make([]*renderedPath, len(paths))
+	var objects []fyne.CanvasObject
+	for i, p := range paths {
+		if i < len(r.previews) && r.previews[i].path == p && r.previewScale == viewport.Scale {
+			previews[i] = r.previews[i]
+		} else {
+			previews[i] = &renderedPath{path: p, color: pathColor(p)}
+		}
+		if len(paths) == 1 {
+			objects = previews[i].extend(viewport.Scale) // Nothing to merge, use the lines as they are
+		} else {
+			objects = append(objects, previews[i].extend(viewport.Scale)...)
+		}
+	}
+	r.previews, r.previewScale = previews, viewport.Scale
+	r.preview.Objects = objects
+	r.preview.Move(viewport.Offset)
+}
+
+func (r *boardWidgetRenderer) Destroy() {}
+
+func (r *boardWidgetRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+}
+
+func (r *boardWidgetRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(300, 300)
+}
@@ -0,0 +1,96 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+const benchPaths = 10000
+
+// benchmarkBoard returns a board holding n strokes of 20 points, spread over
+// a 100x100 grid of 200 unit cells, with its renderer.
+func benchmarkBoard(b *testing.B, n int) (*BoardWidget, fyne.WidgetRenderer) {
+	test.NewTempApp(b)
+	board := NewBoardWidget()
+	board.Resize(fyne.NewSize(1024, 768))
+	paths := make([]*Path, 0, n)
+	for i := 0; i < n; i++ {
+		x, y := float32(i%100)*200, float32(i/100)*200
+		p := &Path{ID: fmt.Sprintf("p%d", i), Color: "#000000ff", Stroke: 3}
+		for k := 0; k < 20; k++ {
+			p.Points = append(p.Points, fyne.NewPos(x+float32(k)*5, y+float32(k%4)*10))
+		}
+		paths = append(paths, p)
+	}
+	board.setPaths(paths)
+	r := test.WidgetRenderer(board)
+	r.Refresh()
+	return board, r
+}
+
+func BenchmarkRefreshUnchanged(b *testing.B) {
+	_, r := benchmarkBoard(b, benchPaths)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Refresh()
+	}
+}
+
+func BenchmarkPanSmall(b *testing.B) {
+	board, _ := benchmarkBoard(b, benchPaths)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := float32(1)
+		if i%2 == 1 {
+			d = -1
+		}
+		board.Pan(fyne.NewDelta(d, d))
+	}
+}
+
+func BenchmarkPanAcrossBoard(b *testing.B) {
+	board, _ := benchmarkBoard(b, benchPaths)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		board.Pan(fyne.NewDelta(-400, 0))
+		if i%40 == 39 {
+			board.Pan(fyne.NewDelta(16000, 0))
+		}
+	}
+}
+
+func BenchmarkZoom(b *testing.B) {
+	board, _ := benchmarkBoard(b, benchPaths)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			board.ZoomIn()
+		} else {
+			board.ZoomOut()
+		}
+	}
+}
+
+func BenchmarkDrawStroke(b *testing.B) {
+	board, _ := benchmarkBoard(b, benchPaths)
+	pen := board.ActiveTool()
+	pen.Pressed(board, fyne.NewPos(10, 10))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pen.Dragged(board, fyne.NewPos(10+float32(i%500), 10+float32(i%300)), fyne.Delta{})
+		board.Refresh()
+	}
+}
+
+func BenchmarkAddPath(b *testing.B) {
+	board, _ := benchmarkBoard(b, benchPaths)
+	p := Path{Color: "#ff0000ff", Stroke: 2, Points: []fyne.Position{{X: 1, Y: 1}, {X: 50, Y: 60}}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.ID = fmt.Sprintf("new%d", i)
+		board.AddRemotePath(p)
+	}
+}
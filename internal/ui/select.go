@@ -0,0 +1,424 @@
+package ui
+
+import (
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+// Selection tool names
+const (
+	ToolSelect = "Select"
+	ToolLasso  = "Lasso"
+)
+
+// Selection handles, in pixels
+const (
+	handleSize         float32 = 8
+	rotateHandleOffset float32 = 24 // Distance of the rotate handle above the box
+	clickSlop          float32 = 4  // Movement still taken as a click
+)
+
+// rotateSnap is the step rotations keep to while Shift is held
+const rotateSnap = math.Pi / 12
+
+// selectionColor outlines the selection and its handles
+const selectionColor = "#1e88e5ff"
+
+// What dragging with a selection tool does
+const (
+	dragSelect = iota
+	dragMove
+	dragScale
+	dragRotate
+)
+
+// selectTool picks paths with a rectangle (marquee) or a freeform lasso, or
+// by clicking one. Dragging inside the selection moves it, dragging a corner
+// handle scales it and dragging the handle above it rotates it; Shift keeps
+// scaling uniform and rotation to 15 degree steps. While dragging, only the
+// local board is updated; the transform is sent once, on release.
+type selectTool struct {
+	lasso     bool
+	board     *BoardWidget // Board the selection is drawn for
+	mode      int
+	start     fyne.Position
+	current   fyne.Position
+	trail     []fyne.Position // Lasso drawn so far
+	anchor    fyne.Position   // Fixed point of a scale or rotation
+	originals []Path          // Selected paths when the drag started
+	dragging  bool
+}
+
+func selectTools() []Tool {
+	return []Tool{&selectTool{}, &selectTool{lasso: true}}
+}
+
+func (t *selectTool) Name() string {
+	if t.lasso {
+		return ToolLasso
+	}
+	return ToolSelect
+}
+
+func (t *selectTool) Icon() fyne.Resource {
+	if t.lasso {
+		return theme.MediaReplayIcon() // A loop, drawn around what to select
+	}
+	return theme.ViewRestoreIcon()
+}
+
+func (t *selectTool) Shortcut() fyne.KeyName {
+	if t.lasso {
+		return fyne.KeyQ
+	}
+	return fyne.KeyV
+}
+
+func (t *selectTool) Cursor() desktop.Cursor { return desktop.DefaultCursor }
+
+func (t *selectTool) Pressed(b *BoardWidget, pos fyne.Position) {
+	t.board, t.start, t.current, t.dragging = b, pos, pos, true
+	t.trail = []fyne.Position{pos}
+	t.mode = dragSelect
+	box, ok := b.selectionBounds()
+	if !ok {
+		return
+	}
+	reach := handleSize / b.viewport.Scale
+	center := fyne.NewPos((box.MinX+box.MaxX)/2, (box.MinY+box.MaxY)/2)
+	corners := []fyne.Position{{X: box.MinX, Y: box.MinY}, {X: box.MaxX, Y: box.MinY}, {X: box.MaxX, Y: box.MaxY}, {X: box.MinX, Y: box.MaxY}}
+	if distance(pos, rotateHandle(box, b.viewport.Scale)) <= reach {
+		t.mode, t.anchor = dragRotate, center
+	}
+	for i, c := range corners {
+		if t.mode == dragSelect && distance(pos, c) <= reach {
+			t.mode, t.anchor = dragScale, corners[(i+2)%4]
+		}
+	}
+	if t.mode == dragSelect && box.Contains(spatial.Point(pos)) {
+		t.mode = dragMove
+	}
+	if t.mode != dragSelect {
+		t.originals = b.selectedPaths()
+	}
+}
+
+func (t *selectTool) Dragged(b *BoardWidget, pos fyne.Position, _ fyne.Delta) {
+	t.current = pos
+	if t.mode == dragSelect {
+		t.trail = append(t.trail, pos)
+		return
+	}
+	// Show the transform locally until it is sent on release
+	m := t.transform()
+	moved := make([]Path, len(t.originals))
+	for i, p := range t.originals {
+		moved[i] = state.TransformPath(p, m)
+	}
+	b.UpdatePaths(moved)
+}
+
+func (t *selectTool) Released(b *BoardWidget) {
+	if !t.dragging {
+		return
+	}
+	t.dragging = false
+	clicked := distance(t.start, t.current) <= clickSlop/b.viewport.Scale
+	switch {
+	case t.mode != dragSelect && !clicked:
+		if b.OnTransform != nil {
+			b.OnTransform(b.selection, t.transform())
+		} else {
+			b.UpdatePaths(t.originals)
+		}
+	case clicked:
+		if t.mode != dragSelect {
+			b.UpdatePaths(t.originals) // Take back what the drag showed so far
+		}
+		b.selection = nil
+		if p, ok := b.pathAt(t.start, clickSlop/b.viewport.Scale, nil); ok {
+			b.selection = []string{p.ID}
+		}
+	case t.lasso:
+		b.selection = b.pathsInPolygon(t.trail)
+	default:
+		b.selection = b.pathsInRect(rectBetween(t.start, t.current))
+	}
+	t.originals, t.trail = nil, nil
+}
+
+// transform returns what the current drag does to the selection
+func (t *selectTool) transform() state.Transform {
+	switch t.mode {
+	case dragMove:
+		return state.Translate(t.current.X-t.start.X, t.current.Y-t.start.Y)
+	case dragScale:
+		sx := scaleFactor(t.start.X, t.current.X, t.anchor.X)
+		sy := scaleFactor(t.start.Y, t.current.Y, t.anchor.Y)
+		if modifierHeld(fyne.KeyModifierShift) {
+			s := max(abs(sx), abs(sy))
+			sx, sy = s*sign(sx), s*sign(sy)
+		}
+		return state.ScaleAbout(t.anchor, sx, sy)
+	case dragRotate:
+		angle := math.Atan2(float64(t.current.Y-t.anchor.Y), float64(t.current.X-t.anchor.X)) -
+			math.Atan2(float64(t.start.Y-t.anchor.Y), float64(t.start.X-t.anchor.X))
+		if modifierHeld(fyne.KeyModifierShift) {
+			angle = math.Round(angle/rotateSnap) * rotateSnap
+		}
+		return state.RotateAbout(t.anchor, angle)
+	}
+	return state.Identity()
+}
+
+// scaleFactor returns how far a corner dragged from start to current moved
+// away from the anchor, relatively, never collapsing to nothing
+func scaleFactor(start, current, anchor float32) float32 {
+	if start == anchor {
+		return 1
+	}
+	s := (current - anchor) / (start - anchor)
+	if abs(s) < 0.01 {
+		return 0.01 * sign(s)
+	}
+	return s
+}
+
+// Preview outlines the marquee or lasso being drawn, and the selection with
+// its handles
+func (t *selectTool) Preview() []*Path {
+	b := t.board
+	if b == nil {
+		return nil
+	}
+	scale := b.viewport.Scale
+	outline := func(points []fyne.Position, shape *state.Shape) *Path {
+		return &Path{Points: points, Color: selectionColor, Stroke: 1 / scale, Shape: shape}
+	}
+	var previews []*Path
+	if t.dragging && t.mode == dragSelect {
+		if t.lasso {
+			previews = append(previews, outline(append(t.trail, t.trail[0]), nil))
+		} else {
+			previews = append(previews, outline([]fyne.Position{t.start, t.current}, &state.Shape{Kind: state.ShapeRect}))
+		}
+	}
+	box, ok := b.selectionBounds()
+	if !ok {
+		return previews
+	}
+	previews = append(previews, outline([]fyne.Position{{X: box.MinX, Y: box.MinY}, {X: box.MaxX, Y: box.MaxY}}, &state.Shape{Kind: state.ShapeRect}))
+	half := handleSize / 2 / scale
+	for _, c := range []fyne.Position{{X: box.MinX, Y: box.MinY}, {X: box.MaxX, Y: box.MinY}, {X: box.MaxX, Y: box.MaxY}, {X: box.MinX, Y: box.MaxY}} {
+		previews = append(previews, outline([]fyne.Position{c.AddXY(-half, -half), c.AddXY(half, half)}, &state.Shape{Kind: state.ShapeRect}))
+	}
+	r := rotateHandle(box, scale)
+	previews = append(previews,
+		outline([]fyne.Position{{X: r.X, Y: box.MinY}, r.AddXY(0, half)}, nil),
+		outline([]fyne.Position{r.AddXY(-half, -half), r.AddXY(half, half)}, &state.Shape{Kind: state.ShapeEllipse}),
+	)
+	return previews
+}
+
+// rotateHandle returns where the rotate handle of a selection box is
+func rotateHandle(box spatial.Rect, scale float32) fyne.Position {
+	return fyne.NewPos((box.MinX+box.MaxX)/2, box.MinY-rotateHandleOffset/scale)
+}
+
+func rectBetween(a, b fyne.Position) spatial.Rect {
+	return spatial.Rect{MinX: min(a.X, b.X), MinY: min(a.Y, b.Y), MaxX: max(a.X, b.X), MaxY: max(a.Y, b.Y)}
+}
+
+func distance(a, b fyne.Position) float32 {
+	return float32(math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)))
+}
+
+// pathAt returns the topmost path within radius of pos that match accepts,
+// or any path if match is nil. Locked paths are passed over, here and when
+// selecting by area.
+func (b *BoardWidget) pathAt(pos fyne.Position, radius float32, match func(*Path) bool) (Path, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var top *Path
+	for _, id := range b.index.QueryPoint(spatial.Point(pos), radius) {
+		p := b.byID[id]
+		if p != nil && !b.locked(p) && (match == nil || match(p)) && state.PathTouches(*p, []fyne.Position{pos}, radius) {
+			if top == nil || b.drawnAbove(p, top) {
+				top = p
+			}
+		}
+	}
+	if top == nil {
+		return Path{}, false
+	}
+	return *top, true
+}
+
+// pathsInRect returns the IDs of the paths lying entirely inside r
+func (b *BoardWidget) pathsInRect(r spatial.Rect) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var ids []string
+	for _, id := range b.index.QueryRect(r) {
+		p := b.byID[id]
+		if b.locked(p) {
+			continue
+		}
+		if box, ok := state.PathBounds(*p); ok && r.Encloses(box) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// pathsInPolygon returns the IDs of the paths lying entirely inside the
+// polygon, which is closed between its last and first point
+func (b *BoardWidget) pathsInPolygon(polygon []fyne.Position) []string {
+	if len(polygon) < 3 {
+		return nil
+	}
+	points := make([]spatial.Point, len(polygon))
+	for i, p := range polygon {
+		points[i] = spatial.Point(p)
+	}
+	bounds, _ := spatial.BoundsOf(points, 0)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var ids []string
+	for _, id := range b.index.QueryRect(bounds) {
+		if b.locked(b.byID[id]) {
+			continue
+		}
+		inside := true
+		for _, pt := range b.byID[id].Polyline() {
+			if !state.InsidePolygon(pt, polygon) {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Selection returns the IDs of the selected paths
+func (b *BoardWidget) Selection() []string {
+	return b.selection
+}
+
+// selectedPaths returns the selected paths still on the board, in drawing order
+func (b *BoardWidget) selectedPaths() []Path {
+	selected := make(map[string]bool, len(b.selection))
+	for _, id := range b.selection {
+		selected[id] = true
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var paths []Path
+	for _, p := range b.drawnLocked() {
+		if selected[p.ID] {
+			paths = append(paths, *p)
+		}
+	}
+	return paths
+}
+
+// selectionBounds returns the box around the selected paths
+func (b *BoardWidget) selectionBounds() (spatial.Rect, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var bounds spatial.Rect
+	found := false
+	for _, id := range b.selection {
+		p := b.byID[id]
+		if p == nil {
+			continue
+		}
+		box, ok := state.PathBounds(*p)
+		if !ok {
+			continue
+		}
+		if !found {
+			bounds, found = box, true
+		} else {
+			bounds = bounds.Union(box)
+		}
+	}
+	return bounds, found
+}
+
+// DeleteSelection deletes the selected paths
+func (b *BoardWidget) DeleteSelection() {
+	if len(b.selection) > 0 && b.OnDeletePaths != nil {
+		b.OnDeletePaths(b.selection)
+	}
+	b.selection = nil
+	b.Refresh()
+}
+
+// BringToFront stacks the selected paths above everything else on their layers
+func (b *BoardWidget) BringToFront() {
+	b.restackSelection(true)
+}
+
+// SendToBack stacks the selected paths below everything else on their layers
+func (b *BoardWidget) SendToBack() {
+	b.restackSelection(false)
+}
+
+func (b *BoardWidget) restackSelection(front bool) {
+	if len(b.selection) > 0 && b.OnRestack != nil {
+		b.OnRestack(b.selection, front)
+		b.Refresh()
+	}
+}
+
+// DuplicateSelection adds a copy of the selected paths next to them
+func (b *BoardWidget) DuplicateSelection() {
+	b.addCopies(b.selectedPaths(), GridSize)
+}
+
+// addCopies adds paths as new paths of ours on the active layer, moved by
+// offset, and selects them
+func (b *BoardWidget) addCopies(paths []Path, offset float32) {
+	if len(paths) == 0 || b.OnAddPaths == nil {
+		return
+	}
+	copies := b.copiesOf(paths, state.Translate(offset, offset))
+	for i := range copies {
+		copies[i].Layer, copies[i].Z = "", 0
+	}
+	if !b.addPaths(copies) {
+		return
+	}
+	b.selection = make([]string, len(copies))
+	for i, p := range copies {
+		b.selection[i] = p.ID
+	}
+	b.Refresh()
+}
+
+// copiesOf returns copies of paths as new paths of ours, moved by m. Copies
+// that would not be valid are left out.
+func (b *BoardWidget) copiesOf(paths []Path, m state.Transform) []Path {
+	copies := make([]Path, 0, len(paths))
+	for _, p := range paths {
+		p = state.TransformPath(p, m)
+		p.ID = "path-" + generateID()
+		p.OwnerID = b.LocalClientID
+		if state.ValidatePath(p, state.DefaultLimits) != nil {
+			continue
+		}
+		copies = append(copies, p)
+	}
+	return copies
+}
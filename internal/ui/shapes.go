@@ -0,0 +1,224 @@
+package ui
+
+import (
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+
+	"MyLocalBoard/internal/spatial"
+	"MyLocalBoard/internal/state"
+)
+
+// Shape tool names
+const (
+	ToolRect    = "Rectangle"
+	ToolEllipse = "Ellipse"
+	ToolLine    = "Line"
+	ToolArrow   = "Arrow"
+)
+
+// GridSize is the spacing of the grid shapes snap to, in board units
+const GridSize float32 = 20
+
+// snapDistance is how close to an edge a point must be to snap to it, in pixels
+const snapDistance float32 = 8
+
+// DefaultCornerRadius is the radius the toolbar's rounded corners option uses
+const DefaultCornerRadius float32 = 12
+
+// shapeFillAlpha is the opacity of shape fills, relative to the stroke colour
+const shapeFillAlpha = 0.25
+
+// shapeTool draws rectangles, ellipses, lines and arrows by dragging from one
+// corner or end to the other. Holding Shift makes rectangles and ellipses
+// square and keeps lines at multiples of 45 degrees.
+type shapeTool struct {
+	kind  string
+	start fyne.Position
+	path  *Path
+}
+
+func shapeTools() []Tool {
+	return []Tool{
+		&shapeTool{kind: state.ShapeRect},
+		&shapeTool{kind: state.ShapeEllipse},
+		&shapeTool{kind: state.ShapeLine},
+		&shapeTool{kind: state.ShapeArrow},
+	}
+}
+
+func (t *shapeTool) Name() string {
+	switch t.kind {
+	case state.ShapeRect:
+		return ToolRect
+	case state.ShapeEllipse:
+		return ToolEllipse
+	case state.ShapeLine:
+		return ToolLine
+	default:
+		return ToolArrow
+	}
+}
+
+func (t *shapeTool) Icon() fyne.Resource {
+	switch t.kind {
+	case state.ShapeRect:
+		return theme.CheckButtonIcon()
+	case state.ShapeEllipse:
+		return theme.RadioButtonIcon()
+	case state.ShapeLine:
+		return theme.ContentRemoveIcon()
+	default:
+		return theme.NavigateNextIcon()
+	}
+}
+
+func (t *shapeTool) Shortcut() fyne.KeyName {
+	switch t.kind {
+	case state.ShapeRect:
+		return fyne.KeyR
+	case state.ShapeEllipse:
+		return fyne.KeyO
+	case state.ShapeLine:
+		return fyne.KeyL
+	default:
+		return fyne.KeyA
+	}
+}
+
+func (t *shapeTool) Cursor() desktop.Cursor { return desktop.CrosshairCursor }
+
+func (t *shapeTool) Pressed(b *BoardWidget, pos fyne.Position) {
+	t.start = b.snap(pos)
+	t.path = nil
+}
+
+func (t *shapeTool) Dragged(b *BoardWidget, pos fyne.Position, _ fyne.Delta) {
+	end := b.snap(pos)
+	if modifierHeld(fyne.KeyModifierShift) {
+		end = constrain(t.kind, t.start, end)
+	}
+	shape := &state.Shape{Kind: t.kind}
+	if t.kind == state.ShapeRect {
+		shape.Radius = b.CornerRadius
+	}
+	if b.ShapeFill && (t.kind == state.ShapeRect || t.kind == state.ShapeEllipse) {
+		shape.Fill = fillColor(b.currentColor)
+	}
+	// A new path each time, so the preview is redrawn rather than extended
+	t.path = &Path{
+		ID:      generateID(),
+		OwnerID: b.LocalClientID,
+		Points:  []fyne.Position{t.start, end},
+		Color:   b.currentColor,
+		Stroke:  b.currentStroke,
+		Shape:   shape,
+	}
+}
+
+func (t *shapeTool) Released(b *BoardWidget) {
+	if t.path != nil && t.path.Points[0] != t.path.Points[1] {
+		b.newPath(*t.path)
+	}
+	t.path = nil
+}
+
+func (t *shapeTool) Preview() []*Path {
+	if t.path == nil {
+		return nil
+	}
+	return []*Path{t.path}
+}
+
+// fillColor returns the tint shapes drawn in c are filled with
+func fillColor(c string) string {
+	rgba := color.NRGBAModel.Convert(state.HexToColor(c)).(color.NRGBA)
+	rgba.A = uint8(float32(rgba.A) * shapeFillAlpha)
+	return state.ColorToHex(rgba)
+}
+
+// constrain keeps rectangles and ellipses square, and lines and arrows at a
+// multiple of 45 degrees
+func constrain(kind string, start, end fyne.Position) fyne.Position {
+	dx, dy := end.X-start.X, end.Y-start.Y
+	if kind == state.ShapeRect || kind == state.ShapeEllipse {
+		side := max(abs(dx), abs(dy))
+		return fyne.NewPos(start.X+side*sign(dx), start.Y+side*sign(dy))
+	}
+	length := math.Hypot(float64(dx), float64(dy))
+	angle := math.Round(math.Atan2(float64(dy), float64(dx))/(math.Pi/4)) * (math.Pi / 4)
+	return fyne.NewPos(start.X+float32(length*math.Cos(angle)), start.Y+float32(length*math.Sin(angle)))
+}
+
+func abs(f float32) float32 {
+	return float32(math.Abs(float64(f)))
+}
+
+func sign(f float32) float32 {
+	if f < 0 {
+		return -1
+	}
+	return 1
+}
+
+// snap moves pos onto the edges or centre lines of nearby shapes, or onto
+// the grid if SnapToGrid is on. Each axis snaps on its own.
+func (b *BoardWidget) snap(pos fyne.Position) fyne.Position {
+	reach := snapDistance / b.viewport.Scale
+	x, y := b.snapToShapes(pos, reach)
+	if b.SnapToGrid {
+		if x == nil {
+			gx := float32(math.Round(float64(pos.X/GridSize))) * GridSize
+			x = &gx
+		}
+		if y == nil {
+			gy := float32(math.Round(float64(pos.Y/GridSize))) * GridSize
+			y = &gy
+		}
+	}
+	if x != nil {
+		pos.X = *x
+	}
+	if y != nil {
+		pos.Y = *y
+	}
+	return pos
+}
+
+// snapToShapes returns the closest shape edge or centre line within reach of
+// pos on each axis, nil if there is none
+func (b *BoardWidget) snapToShapes(pos fyne.Position, reach float32) (x, y *float32) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bestX, bestY := reach, reach
+	for _, id := range b.index.QueryPoint(spatial.Point(pos), reach) {
+		p := b.byID[id]
+		if p == nil || p.Shape == nil {
+			continue
+		}
+		outline := p.Polyline() // Moved, scaled and rotated as drawn
+		points := make([]spatial.Point, len(outline))
+		for i, pt := range outline {
+			points[i] = spatial.Point(pt)
+		}
+		box, ok := spatial.BoundsOf(points, 0)
+		if !ok {
+			continue
+		}
+		for _, edge := range []float32{box.MinX, box.MaxX, (box.MinX + box.MaxX) / 2} {
+			if d := abs(pos.X - edge); d <= bestX {
+				bestX, x = d, &edge
+			}
+		}
+		for _, edge := range []float32{box.MinY, box.MaxY, (box.MinY + box.MaxY) / 2} {
+			if d := abs(pos.Y - edge); d <= bestY {
+				bestY, y = d, &edge
+			}
+		}
+	}
+	return x, y
+}
@@ -0,0 +1,371 @@
+package ui
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/state"
+)
+
+// Text tool names
+const (
+	ToolText = "Text"
+	ToolNote = "Sticky Note"
+)
+
+// Defaults of new text elements, in board units
+const (
+	DefaultTextSize  float32 = 24
+	DefaultTextWidth float32 = 320
+	DefaultNoteSize  float32 = 200
+	DefaultNoteColor         = "#fff59dff"
+)
+
+// Editing a text element holds a lock on it, so others wait until we are
+// done rather than typing over each other. Locks are advisory and expire
+// unless their holder refreshes them, so a peer that vanishes mid-edit does
+// not lock anyone out.
+const (
+	LockTimeout      = 10 * time.Second
+	lockRefresh      = 3 * time.Second
+	editSendInterval = 250 * time.Millisecond // Live edits are sent at most this often
+)
+
+// EditLock announces that Holder started (Locked) or stopped editing an element
+type EditLock struct {
+	ElementID string `json:"element_id"`
+	Holder    string `json:"holder"`
+	Locked    bool   `json:"locked"`
+}
+
+// heldLock is a remote participant's lock on an element
+type heldLock struct {
+	holder  string
+	expires time.Time
+}
+
+// editSession is the text element being edited in place
+type editSession struct {
+	path      Path
+	isNew     bool // Created by this session
+	sent      bool // Some version has been sent
+	dirty     bool // Changed since the last send
+	continued bool // Further edits merge into the first undo step
+	lastSent  time.Time
+	timer     *time.Timer
+	stop      chan struct{} // Ends the lock refresh
+}
+
+// textEditor is the entry text is typed into. Escape or leaving it ends editing.
+type textEditor struct {
+	widget.Entry
+	board *BoardWidget
+}
+
+func newTextEditor(b *BoardWidget) *textEditor {
+	e := &textEditor{board: b}
+	e.MultiLine = true
+	e.Wrapping = fyne.TextWrapWord
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+func (e *textEditor) TypedKey(key *fyne.KeyEvent) {
+	if key.Name == fyne.KeyEscape {
+		e.board.FinishEditing()
+		return
+	}
+	e.Entry.TypedKey(key)
+}
+
+func (e *textEditor) FocusLost() {
+	e.Entry.FocusLost()
+	e.board.FinishEditing()
+}
+
+// textTool places text boxes or sticky notes, and edits existing ones when
+// clicked. Editing starts once the button is released, as the click itself
+// would take the focus away from the editor again.
+type textTool struct {
+	note    bool
+	pos     fyne.Position
+	pressed bool
+}
+
+func textTools() []Tool {
+	return []Tool{&textTool{}, &textTool{note: true}}
+}
+
+func (t *textTool) Name() string {
+	if t.note {
+		return ToolNote
+	}
+	return ToolText
+}
+
+func (t *textTool) Icon() fyne.Resource {
+	if t.note {
+		return theme.DocumentIcon()
+	}
+	return theme.FileTextIcon()
+}
+
+func (t *textTool) Shortcut() fyne.KeyName {
+	if t.note {
+		return fyne.KeyN
+	}
+	return fyne.KeyT
+}
+
+func (t *textTool) Cursor() desktop.Cursor { return desktop.TextCursor }
+
+func (t *textTool) Pressed(b *BoardWidget, pos fyne.Position) {
+	b.FinishEditing()
+	t.pos, t.pressed = pos, true
+}
+
+func (t *textTool) Dragged(*BoardWidget, fyne.Position, fyne.Delta) {}
+
+func (t *textTool) Released(b *BoardWidget) {
+	if !t.pressed {
+		return
+	}
+	t.pressed = false
+	pos := t.pos
+	if p, ok := b.textAt(pos); ok {
+		b.startEditing(p, false)
+		return
+	}
+	text := &state.Text{Size: b.TextSize, Align: b.TextAlign}
+	end := pos.AddXY(DefaultTextWidth, b.TextSize*state.LineSpacing)
+	if t.note {
+		pos = b.snap(pos)
+		text.Note = DefaultNoteColor
+		end = pos.AddXY(DefaultNoteSize, DefaultNoteSize)
+	}
+	b.startEditing(Path{
+		ID:      "text-" + generateID(),
+		OwnerID: b.LocalClientID,
+		Points:  []fyne.Position{pos, end},
+		Color:   b.currentColor,
+		Stroke:  1,
+		Text:    text,
+	}, true)
+}
+
+func (t *textTool) Preview() []*Path { return nil }
+
+// textAt returns the topmost text element at pos
+func (b *BoardWidget) textAt(pos fyne.Position) (Path, bool) {
+	return b.pathAt(pos, 0, func(p *Path) bool { return p.Text != nil })
+}
+
+// measureText returns how wide text is drawn at size, in board units
+func measureText(text string, size float32) float32 {
+	return fyne.MeasureText(text, size, fyne.TextStyle{}).Width
+}
+
+// fitText grows or shrinks a text box to the height of its text. Sticky
+// notes keep their size.
+func fitText(p *Path) {
+	if p.Text.Note != "" {
+		return
+	}
+	pos, size := p.TextBox()
+	lines := max(len(state.LayoutText(*p, measureText)), 1)
+	p.Points = []fyne.Position{pos, pos.AddXY(size.Width, float32(lines)*p.Text.Size*state.LineSpacing)}
+}
+
+// Editing reports the ID of the text element being edited, if any
+func (b *BoardWidget) Editing() (string, bool) {
+	if b.editing == nil {
+		return "", false
+	}
+	return b.editing.path.ID, true
+}
+
+// startEditing opens the editor on p, unless someone else holds its lock
+func (b *BoardWidget) startEditing(p Path, isNew bool) {
+	if holder, locked := b.lockHolder(p.ID); locked {
+		b.SetStatus(holder + " is editing this")
+		return
+	}
+	if isNew {
+		paths := []Path{p}
+		if !b.onActiveLayer(paths) {
+			return
+		}
+		p = paths[0]
+	}
+	s := &editSession{path: p, isNew: isNew, stop: make(chan struct{})}
+	b.editing = s
+	if b.editor == nil {
+		b.editor = newTextEditor(b)
+	}
+	b.editor.OnChanged = nil
+	b.editor.SetText(p.Text.Content)
+	b.editor.OnChanged = b.textChanged
+
+	b.sendLock(p.ID, true)
+	go func() {
+		ticker := time.NewTicker(lockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.sendLock(p.ID, true)
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	if isNew && p.Text.Note != "" {
+		b.sendEdit() // A note is worth keeping even without text
+	}
+	b.Refresh()
+	// Focus once the click that started editing has been handled
+	go fyne.Do(func() {
+		if c := fyne.CurrentApp().Driver().CanvasForObject(b); c != nil && b.editing == s {
+			c.Focus(b.editor)
+		}
+	})
+}
+
+// textChanged takes in what was typed, sending it live but not too often
+func (b *BoardWidget) textChanged(content string) {
+	s := b.editing
+	if s == nil {
+		return
+	}
+	text := *s.path.Text
+	text.Content = content
+	s.path.Text = &text
+	fitText(&s.path)
+	s.dirty = true
+
+	if wait := editSendInterval - time.Since(s.lastSent); wait <= 0 {
+		b.sendEdit()
+	} else if s.timer == nil {
+		s.timer = time.AfterFunc(wait, func() {
+			fyne.Do(func() {
+				if b.editing == s {
+					b.sendEdit()
+				}
+			})
+		})
+	}
+	b.Refresh()
+}
+
+// sendEdit hands the current version of the edited element to OnEditPath.
+// A new text box is only sent once it has some text.
+func (b *BoardWidget) sendEdit() {
+	s := b.editing
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.isNew && !s.sent && s.path.Text.Note == "" && s.path.Text.Content == "" {
+		return
+	}
+	if b.OnEditPath != nil {
+		b.OnEditPath(s.path, s.continued)
+	}
+	s.sent, s.dirty, s.continued = true, false, true
+	s.lastSent = time.Now()
+}
+
+// FinishEditing sends the last changes of the element being edited and
+// releases its lock. A text box left empty is deleted.
+func (b *BoardWidget) FinishEditing() {
+	s := b.editing
+	if s == nil {
+		return
+	}
+	if s.dirty {
+		b.sendEdit()
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	b.editing = nil
+	close(s.stop)
+	b.sendLock(s.path.ID, false)
+	if s.sent && s.path.Text.Note == "" && s.path.Text.Content == "" && b.OnDeletePaths != nil {
+		b.OnDeletePaths([]string{s.path.ID})
+	}
+	if c := fyne.CurrentApp().Driver().CanvasForObject(b); c != nil && c.Focused() == b.editor {
+		c.Unfocus()
+	}
+	b.Refresh()
+}
+
+// SetTextStyle changes the size and alignment of new text, and of the text
+// being edited
+func (b *BoardWidget) SetTextStyle(size float32, align string) {
+	b.TextSize, b.TextAlign = size, align
+	s := b.editing
+	if s == nil {
+		return
+	}
+	text := *s.path.Text
+	text.Size, text.Align = size, align
+	s.path.Text = &text
+	fitText(&s.path)
+	b.sendEdit()
+	b.Refresh()
+}
+
+func (b *BoardWidget) sendLock(id string, locked bool) {
+	if b.OnLockChanged != nil {
+		b.OnLockChanged(EditLock{ElementID: id, Holder: b.LocalClientID, Locked: locked})
+	}
+}
+
+// lockHolder returns who else is editing an element, if anyone
+func (b *BoardWidget) lockHolder(id string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	l, ok := b.locks[id]
+	if !ok || time.Now().After(l.expires) {
+		return "", false
+	}
+	return l.holder, true
+}
+
+// SetRemoteLock records that another participant started or stopped editing
+// an element. If we are editing it too, the participant with the lower ID
+// keeps editing and the other one stops.
+func (b *BoardWidget) SetRemoteLock(lock EditLock) {
+	if lock.Holder == "" || lock.Holder == b.LocalClientID {
+		return
+	}
+	b.mu.Lock()
+	if lock.Locked {
+		b.locks[lock.ElementID] = heldLock{holder: lock.Holder, expires: time.Now().Add(LockTimeout)}
+	} else if l, ok := b.locks[lock.ElementID]; ok && l.holder == lock.Holder {
+		delete(b.locks, lock.ElementID)
+	}
+	// Drop locks whose holders went quiet
+	for id, l := range b.locks {
+		if time.Now().After(l.expires) {
+			delete(b.locks, id)
+		}
+	}
+	b.mu.Unlock()
+
+	if !lock.Locked || lock.Holder > b.LocalClientID {
+		return
+	}
+	fyne.Do(func() {
+		if id, ok := b.Editing(); ok && id == lock.ElementID {
+			b.FinishEditing()
+			b.SetStatus(lock.Holder + " is editing this")
+		}
+	})
+}
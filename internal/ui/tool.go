@@ -0,0 +1,202 @@
+package ui
+
+import (
+	"math"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+
+	"MyLocalBoard/internal/stroke"
+)
+
+// Tool handles the pointer on the board while it is the active tool.
+// Positions are in board coordinates, with panning already taken out.
+type Tool interface {
+	Name() string
+	Icon() fyne.Resource
+	Shortcut() fyne.KeyName // Key that selects the tool
+	Cursor() desktop.Cursor
+	Pressed(b *BoardWidget, pos fyne.Position)
+	Dragged(b *BoardWidget, pos fyne.Position, delta fyne.Delta)
+	Released(b *BoardWidget)
+	// Preview returns what the tool is in the middle of, drawn above the board
+	Preview() []*Path
+}
+
+// Built-in tool names
+const (
+	ToolPen           = "Pen"
+	ToolEraser        = "Eraser"
+	ToolPartialEraser = "Partial Eraser"
+	ToolPan           = "Pan"
+)
+
+// defaultTools returns the tools every board starts with, the pen first
+func defaultTools() []Tool {
+	tools := []Tool{
+		&penTool{},
+		&eraserTool{},
+		&eraserTool{partial: true},
+		&panTool{},
+	}
+	tools = append(tools, selectTools()...)
+	tools = append(tools, shapeTools()...)
+	return append(tools, textTools()...)
+}
+
+// --- Pen ---
+
+// penTool draws freehand strokes. Points are smoothed as they come in; the
+// finished stroke is simplified, and fitted with Béziers if the board's
+// stroke options ask for it. Fyne does not report pen pressure, so with
+// Pressure on it is simulated from drawing speed: fast strokes get thinner.
+type penTool struct {
+	path     *Path
+	smoother *stroke.Smoother
+	pressure float32       // Smoothed simulated pressure
+	last     fyne.Position // Last raw position, where the stroke must end
+	lastAt   time.Time
+}
+
+// pressureSmoothing is how much of a new pressure reading is taken in
+const pressureSmoothing float32 = 0.3
+
+func (t *penTool) Name() string           { return ToolPen }
+func (t *penTool) Icon() fyne.Resource    { return theme.DocumentCreateIcon() }
+func (t *penTool) Shortcut() fyne.KeyName { return fyne.KeyP }
+func (t *penTool) Cursor() desktop.Cursor { return desktop.CrosshairCursor }
+
+func (t *penTool) Pressed(b *BoardWidget, pos fyne.Position) {
+	t.smoother = stroke.NewSmoother(b.StrokeOptions.Smoothing)
+	t.smoother.Add(pos)
+	t.last, t.lastAt = pos, time.Now()
+	t.path = &Path{
+		ID:      generateID(),
+		OwnerID: b.LocalClientID,
+		Points:  []fyne.Position{pos},
+		Color:   b.currentColor,
+		Stroke:  b.currentStroke,
+	}
+	if b.StrokeOptions.Pressure {
+		t.pressure = 1
+		t.path.Widths = []float32{b.currentStroke}
+	}
+}
+
+func (t *penTool) Dragged(b *BoardWidget, pos fyne.Position, _ fyne.Delta) {
+	if t.path == nil {
+		return
+	}
+	t.path.Points = append(t.path.Points, t.smoother.Add(pos))
+	if t.path.Widths != nil {
+		now := time.Now()
+		if dt := now.Sub(t.lastAt).Seconds(); dt > 0 {
+			moved := float32(math.Hypot(float64(pos.X-t.last.X), float64(pos.Y-t.last.Y))) * b.viewport.Scale
+			speed := moved / float32(dt)
+			t.pressure += (stroke.SpeedPressure(speed) - t.pressure) * pressureSmoothing
+		}
+		t.lastAt = now
+		t.path.Widths = append(t.path.Widths, t.path.Stroke*t.pressure)
+	}
+	t.last = pos
+}
+
+func (t *penTool) Released(b *BoardWidget) {
+	if t.path != nil && len(t.path.Points) > 1 {
+		// Smoothing lags behind the pointer; end where it was let go
+		if end := t.path.Points[len(t.path.Points)-1]; end != t.last {
+			t.path.Points = append(t.path.Points, t.last)
+			if t.path.Widths != nil {
+				t.path.Widths = append(t.path.Widths, t.path.Widths[len(t.path.Widths)-1])
+			}
+		}
+		t.path.Points, t.path.Widths, t.path.Bezier = stroke.Finish(t.path.Points, t.path.Widths, b.StrokeOptions, b.viewport.Scale)
+		b.newPath(*t.path)
+	}
+	t.path = nil
+}
+
+func (t *penTool) Preview() []*Path {
+	if t.path == nil {
+		return nil
+	}
+	return []*Path{t.path}
+}
+
+// --- Eraser ---
+
+// EraserRadius is how far from the pointer the eraser reaches
+const EraserRadius float32 = 10
+
+// eraserTool deletes the strokes it is dragged over, or with partial only
+// the parts of them it touches. Erasing happens when the pointer is released.
+type eraserTool struct {
+	partial bool
+	trail   *Path // Where the eraser has been, drawn until it is released
+}
+
+func (t *eraserTool) Name() string {
+	if t.partial {
+		return ToolPartialEraser
+	}
+	return ToolEraser
+}
+
+func (t *eraserTool) Icon() fyne.Resource {
+	if t.partial {
+		return theme.ContentCutIcon()
+	}
+	return theme.ContentClearIcon()
+}
+
+func (t *eraserTool) Shortcut() fyne.KeyName {
+	if t.partial {
+		return fyne.KeyX
+	}
+	return fyne.KeyE
+}
+
+func (t *eraserTool) Cursor() desktop.Cursor { return desktop.CrosshairCursor }
+
+func (t *eraserTool) Pressed(b *BoardWidget, pos fyne.Position) {
+	t.trail = &Path{Points: []fyne.Position{pos}, Color: eraserTrailColor, Stroke: 2 * EraserRadius}
+}
+
+func (t *eraserTool) Dragged(b *BoardWidget, pos fyne.Position, _ fyne.Delta) {
+	if t.trail != nil {
+		t.trail.Points = append(t.trail.Points, pos)
+	}
+}
+
+func (t *eraserTool) Released(b *BoardWidget) {
+	if t.trail != nil && b.OnErase != nil {
+		b.OnErase(t.trail.Points, EraserRadius, t.partial)
+	}
+	t.trail = nil
+}
+
+func (t *eraserTool) Preview() []*Path {
+	if t.trail == nil {
+		return nil
+	}
+	return []*Path{t.trail}
+}
+
+// --- Pan ---
+
+// panTool moves the view around the board
+type panTool struct{}
+
+func (t *panTool) Name() string                        { return ToolPan }
+func (t *panTool) Icon() fyne.Resource                 { return theme.ViewFullScreenIcon() }
+func (t *panTool) Shortcut() fyne.KeyName              { return fyne.KeyH }
+func (t *panTool) Cursor() desktop.Cursor              { return desktop.PointerCursor }
+func (t *panTool) Pressed(*BoardWidget, fyne.Position) {}
+func (t *panTool) Released(*BoardWidget)               {}
+func (t *panTool) Preview() []*Path                    { return nil }
+
+func (t *panTool) Dragged(b *BoardWidget, _ fyne.Position, delta fyne.Delta) {
+	b.Pan(delta)
+}
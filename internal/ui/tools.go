@@ -1,18 +1,28 @@
 package ui
 
 import (
+	"fmt"
 	"image/color"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"MyLocalBoard/internal/state"
 )
 
-// We need to keep track of the last used color when switching back from the eraser.
-var lastSelectedColor color.Color = color.Black
+// selectColor makes c the drawing colour. Tools that do not draw give way to the pen.
+func selectColor(board *BoardWidget, c color.Color) {
+	board.SetColor(c)
+	switch board.ActiveTool().Name() {
+	case ToolEraser, ToolPartialEraser, ToolPan:
+		board.SetTool(ToolPen)
+	}
+}
 
 // --- Custom Widget for Color Swatches ---
 type colorSwatch struct {
@@ -44,26 +54,163 @@ func (s *colorSwatch) Tapped(_ *fyne.PointEvent) {
 	}
 }
 
+// MaxRecentColors is how many picked colours the toolbar remembers
+const MaxRecentColors = 8
+
+// newColorPicker returns a button opening the full colour picker, followed by
+// swatches of the most recently picked colours.
+func newColorPicker(board *BoardWidget, window fyne.Window) fyne.CanvasObject {
+	recent := container.NewHBox()
+	var recentColors []color.Color
+
+	var pick func(color.Color)
+	pick = func(c color.Color) {
+		selectColor(board, c)
+
+		// Move the colour to the front of the recent ones
+		hex := state.ColorToHex(c)
+		colors := []color.Color{c}
+		for _, r := range recentColors {
+			if state.ColorToHex(r) != hex && len(colors) < MaxRecentColors {
+				colors = append(colors, r)
+			}
+		}
+		recentColors = colors
+		recent.RemoveAll()
+		for _, r := range recentColors {
+			recent.Add(newColorSwatch(r, pick))
+		}
+	}
+
+	more := widget.NewButton("More...", func() {
+		picker := dialog.NewColorPicker("Pick a colour", "New strokes use this colour", pick, window)
+		picker.Advanced = true
+		picker.Show()
+	})
+	return container.NewHBox(more, recent)
+}
+
+// newToolButtons returns a button per registered tool, with the active one
+// highlighted and named next to them.
+func newToolButtons(board *BoardWidget) fyne.CanvasObject {
+	buttons := container.NewHBox()
+	byName := make(map[string]*widget.Button)
+	for _, t := range board.Tools() {
+		name := t.Name()
+		button := widget.NewButtonWithIcon("", t.Icon(), func() { board.SetTool(name) })
+		byName[name] = button
+		buttons.Add(button)
+	}
+
+	active := widget.NewLabel("")
+	board.OnToolChanged = func(t Tool) {
+		for name, button := range byName {
+			if name == t.Name() {
+				button.Importance = widget.HighImportance
+			} else {
+				button.Importance = widget.MediumImportance
+			}
+			button.Refresh()
+		}
+		active.SetText(fmt.Sprintf("%s (%s)", t.Name(), t.Shortcut()))
+	}
+	board.OnToolChanged(board.ActiveTool())
+	return container.NewHBox(buttons, active)
+}
+
+// newShapeOptions returns the checks controlling how shapes are drawn
+func newShapeOptions(board *BoardWidget) fyne.CanvasObject {
+	fill := widget.NewCheck("Fill", func(on bool) { board.ShapeFill = on })
+	fill.SetChecked(board.ShapeFill)
+	rounded := widget.NewCheck("Rounded", func(on bool) {
+		board.CornerRadius = 0
+		if on {
+			board.CornerRadius = DefaultCornerRadius
+		}
+	})
+	rounded.SetChecked(board.CornerRadius > 0)
+	snap := widget.NewCheck("Snap to grid", func(on bool) { board.SnapToGrid = on })
+	snap.SetChecked(board.SnapToGrid)
+	return container.NewHBox(fill, rounded, snap)
+}
+
+// textSizes are the sizes the toolbar offers for text, in board units
+var textSizes = []float32{12, 16, 24, 32, 48, 72}
+
+// newTextOptions returns the size and alignment pickers for text
+func newTextOptions(board *BoardWidget) fyne.CanvasObject {
+	sizes := make([]string, len(textSizes))
+	for i, s := range textSizes {
+		sizes[i] = fmt.Sprint(s)
+	}
+	size := widget.NewSelect(sizes, func(s string) {
+		var v float32
+		fmt.Sscan(s, &v)
+		board.SetTextStyle(v, board.TextAlign)
+	})
+	size.SetSelected(fmt.Sprint(board.TextSize))
+	align := widget.NewRadioGroup([]string{state.AlignLeft, state.AlignCenter, state.AlignRight}, func(a string) {
+		if a != "" {
+			board.SetTextStyle(board.TextSize, a)
+		}
+	})
+	align.Horizontal = true
+	align.SetSelected(board.TextAlign)
+	return container.NewHBox(widget.NewLabel("Text:"), size, align)
+}
+
+// strokeTolerances are the simplification tolerances the toolbar offers, in
+// pixels; 0 keeps every point
+var strokeTolerances = []float32{0, 0.5, 0.75, 1.5, 3}
+
+// newStrokeOptions returns the controls for how pen strokes are simplified
+// and stored
+func newStrokeOptions(board *BoardWidget) fyne.CanvasObject {
+	tolerances := make([]string, len(strokeTolerances))
+	for i, t := range strokeTolerances {
+		tolerances[i] = fmt.Sprint(t)
+	}
+	tolerance := widget.NewSelect(tolerances, func(s string) {
+		var v float32
+		fmt.Sscan(s, &v)
+		board.StrokeOptions.Tolerance = v
+	})
+	tolerance.SetSelected(fmt.Sprint(board.StrokeOptions.Tolerance))
+	curves := widget.NewCheck("Curves", func(on bool) { board.StrokeOptions.Bezier = on })
+	curves.SetChecked(board.StrokeOptions.Bezier)
+	return container.NewHBox(widget.NewLabel("Simplify:"), tolerance, curves)
+}
+
 // --- The Main Toolbar ---
-func NewToolbar(board *BoardWidget) fyne.CanvasObject {
+func NewToolbar(board *BoardWidget, window fyne.Window) fyne.CanvasObject {
+	// Boards without OnLoad, such as clients of a host, can't load files
+	load := widget.NewToolbarAction(theme.FolderOpenIcon(), func() { showLoadDialog(board, window) })
+	if board.OnLoad == nil {
+		load.Disable()
+	}
+
 	// toolbar with built-in tooltips
-	tb := widget.NewToolbar(
-		widget.NewToolbarAction(theme.DocumentCreateIcon(), func() {
-			board.SetColor(lastSelectedColor)
-			if board.currentStroke > 10.0 {
-				board.SetStroke(2.0)
-			}
-		}), // Pen
-		widget.NewToolbarAction(theme.DeleteIcon(), func() {
-			board.SetColor(color.White)
-			board.SetStroke(20.0)
-		}), // Eraser
+	actions := widget.NewToolbar(
+		widget.NewToolbarAction(theme.ContentUndoIcon(), board.Undo),
+		widget.NewToolbarAction(theme.ContentRedoIcon(), board.Redo),
+		widget.NewToolbarAction(theme.DeleteIcon(), board.ClearPaths), // Clear my drawings
+		widget.NewToolbarAction(theme.MoveUpIcon(), board.BringToFront),
+		widget.NewToolbarAction(theme.MoveDownIcon(), board.SendToBack),
+		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.ZoomOutIcon(), board.ZoomOut),
+		widget.NewToolbarAction(theme.ZoomInIcon(), board.ZoomIn),
+		widget.NewToolbarAction(theme.ZoomFitIcon(), board.ZoomToFit),
+		widget.NewToolbarSeparator(),
+		widget.NewToolbarAction(theme.DocumentSaveIcon(), func() { showSaveDialog(board, window) }),
+		load,
+		widget.NewToolbarAction(theme.DocumentPrintIcon(), func() { ShowExportDialog(board, window) }),
+		widget.NewToolbarAction(theme.FileImageIcon(), func() { ShowInsertImageDialog(board, window) }),
+		widget.NewToolbarAction(theme.DocumentIcon(), func() { ShowOpenBackgroundDialog(board, window) }), // Open as background
 	)
 
 	// --- Color Palette ---
 	onColorTapped := func(c color.Color) {
-		lastSelectedColor = c
-		board.SetColor(c)
+		selectColor(board, c)
 	}
 	colorBox := container.NewHBox(
 		newColorSwatch(color.Black, onColorTapped),
@@ -75,7 +222,7 @@ func NewToolbar(board *BoardWidget) fyne.CanvasObject {
 
 	// --- Stroke Width Slider ---
 	strokeSlider := widget.NewSlider(1.0, 50.0)
-	strokeSlider.SetValue(2.0)
+	strokeSlider.SetValue(float64(board.currentStroke))
 	strokeSlider.OnChanged = func(val float64) {
 		board.SetStroke(float32(val))
 	}
@@ -84,13 +231,21 @@ func NewToolbar(board *BoardWidget) fyne.CanvasObject {
 	// --- Assemble everything ---
 	return container.NewHBox(
 		widget.NewLabel("Tool:"),
-		tb,
+		newToolButtons(board),
 		widget.NewSeparator(),
 		widget.NewLabel("Color:"),
 		colorBox,
+		newColorPicker(board, window),
 		widget.NewSeparator(),
 		widget.NewLabel("Size:"),
 		sliderContainer,
+		newStrokeOptions(board),
+		widget.NewSeparator(),
+		newShapeOptions(board),
+		widget.NewSeparator(),
+		newTextOptions(board),
+		widget.NewSeparator(),
+		actions,
 		layout.NewSpacer(),
 	)
 }
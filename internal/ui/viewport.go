@@ -0,0 +1,223 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+
+	"MyLocalBoard/internal/state"
+)
+
+// Zoom limits and steps
+const (
+	MinZoom       float32 = 0.05
+	MaxZoom       float32 = 20
+	ZoomStep      float32 = 1.25 // Factor of one zoom in or out
+	wheelZoomRate         = 1.01 // Zoom factor per scrolled unit with Ctrl held
+	zoomFitMargin float32 = 40   // Space left around the content by ZoomToFit
+)
+
+// Viewport maps board coordinates to the widget: screen = board*Scale + Offset.
+// Paths, including stroke widths, are always stored in board units.
+type Viewport struct {
+	Scale  float32
+	Offset fyne.Position
+}
+
+// ToScreen converts a board position to a widget position
+func (v Viewport) ToScreen(p fyne.Position) fyne.Position {
+	return fyne.NewPos(p.X*v.Scale+v.Offset.X, p.Y*v.Scale+v.Offset.Y)
+}
+
+// ToBoard converts a widget position to a board position
+func (v Viewport) ToBoard(p fyne.Position) fyne.Position {
+	return fyne.NewPos((p.X-v.Offset.X)/v.Scale, (p.Y-v.Offset.Y)/v.Scale)
+}
+
+// ZoomAt scales the view by factor while keeping the board point under the
+// widget position anchor in place.
+func (v Viewport) ZoomAt(anchor fyne.Position, factor float32) Viewport {
+	scale := clampZoom(v.Scale * factor)
+	board := v.ToBoard(anchor)
+	return Viewport{
+		Scale:  scale,
+		Offset: fyne.NewPos(anchor.X-board.X*scale, anchor.Y-board.Y*scale),
+	}
+}
+
+// Viewport returns the current view of the board
+func (b *BoardWidget) Viewport() Viewport {
+	return b.viewport
+}
+
+// SetViewport changes the view of the board. Moving it ourselves stops
+// following the presenter.
+func (b *BoardWidget) SetViewport(v Viewport) {
+	if !b.steering {
+		if b.glide != nil {
+			b.glide.Stop()
+		}
+		b.leavePresenter()
+	}
+	b.viewport = v
+	b.zoomLabel.SetText(fmt.Sprintf("%.0f%%", v.Scale*100))
+	b.Refresh()
+	b.viewChanged()
+}
+
+// Resize keeps the minimap and remote participants up to date with our view
+func (b *BoardWidget) Resize(size fyne.Size) {
+	b.BaseWidget.Resize(size)
+	b.viewChanged()
+}
+
+func (b *BoardWidget) viewChanged() {
+	b.minimap.Refresh()
+	b.pageNav.followView()
+	if f, _ := b.CurrentFrame(); f.ID != b.hereFrame {
+		b.hereFrame = f.ID
+		b.frameList.Refresh()
+		b.participantList.Refresh()
+	}
+	if b.OnViewportChanged != nil {
+		b.OnViewportChanged(b.VisibleRect())
+	}
+}
+
+// Pan moves the view by delta
+func (b *BoardWidget) Pan(delta fyne.Delta) {
+	v := b.viewport
+	v.Offset = v.Offset.Add(delta)
+	b.SetViewport(v)
+}
+
+// ZoomIn zooms in one step around the centre of the board
+func (b *BoardWidget) ZoomIn() {
+	b.SetViewport(b.viewport.ZoomAt(b.center(), ZoomStep))
+}
+
+// ZoomOut zooms out one step around the centre of the board
+func (b *BoardWidget) ZoomOut() {
+	b.SetViewport(b.viewport.ZoomAt(b.center(), 1/ZoomStep))
+}
+
+// ResetZoom returns to 100% around the centre of the board
+func (b *BoardWidget) ResetZoom() {
+	b.SetViewport(b.viewport.ZoomAt(b.center(), 1/b.viewport.Scale))
+}
+
+// ZoomToFit shows all content, zooming as far as needed in either direction
+func (b *BoardWidget) ZoomToFit() {
+	lo, hi, ok := b.contentBounds()
+	if !ok {
+		b.ResetZoom()
+		return
+	}
+	b.fitView(lo, hi)
+}
+
+// fitView shows the area from lo to hi as large as fits, centred
+func (b *BoardWidget) fitView(lo, hi fyne.Position) {
+	size := b.Size()
+	scale := min(
+		(size.Width-2*zoomFitMargin)/max(hi.X-lo.X, 1),
+		(size.Height-2*zoomFitMargin)/max(hi.Y-lo.Y, 1),
+	)
+	v := Viewport{Scale: clampZoom(scale)}
+	// Centre the area
+	v.Offset = fyne.NewPos(
+		size.Width/2-(lo.X+hi.X)/2*v.Scale,
+		size.Height/2-(lo.Y+hi.Y)/2*v.Scale,
+	)
+	b.SetViewport(v)
+}
+
+// contentBounds returns the bounding box of every path, strokes included.
+// It is cached until the paths change; appending a path only extends it.
+func (b *BoardWidget) contentBounds() (fyne.Position, fyne.Position, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	b.boundsMu.Lock()
+	defer b.boundsMu.Unlock()
+
+	c := &b.bounds
+	switch {
+	case c.generation == b.generation:
+		return c.lo, c.hi, c.ok
+	case c.generation+1 == b.generation && b.lastAppend == b.generation:
+		c.extend(b.paths[len(b.paths)-1])
+	default:
+		*c = contentBox{}
+		for _, p := range b.paths {
+			c.extend(p)
+		}
+	}
+	c.generation = b.generation
+	return c.lo, c.hi, c.ok
+}
+
+// contentBox is the cached bounding box of all paths
+type contentBox struct {
+	generation uint64
+	lo, hi     fyne.Position
+	ok         bool
+}
+
+func (c *contentBox) extend(p *Path) {
+	box, ok := state.PathBounds(*p)
+	if !ok {
+		return
+	}
+	lo, hi := fyne.NewPos(box.MinX, box.MinY), fyne.NewPos(box.MaxX, box.MaxY)
+	if !c.ok {
+		c.lo, c.hi, c.ok = lo, hi, true
+		return
+	}
+	c.lo = fyne.NewPos(min(c.lo.X, lo.X), min(c.lo.Y, lo.Y))
+	c.hi = fyne.NewPos(max(c.hi.X, hi.X), max(c.hi.Y, hi.Y))
+}
+
+func clampZoom(scale float32) float32 {
+	return max(MinZoom, min(MaxZoom, scale))
+}
+
+func (b *BoardWidget) center() fyne.Position {
+	size := b.Size()
+	return fyne.NewPos(size.Width/2, size.Height/2)
+}
+
+// toBoard converts a position on the widget to board coordinates
+func (b *BoardWidget) toBoard(pos fyne.Position) fyne.Position {
+	return b.viewport.ToBoard(pos)
+}
+
+// Scrolled pans the view, or zooms around the pointer while Ctrl is held.
+// Touchpad pinches reach us as Ctrl+scroll on platforms that translate them
+// that way, such as Windows precision touchpads.
+func (b *BoardWidget) Scrolled(e *fyne.ScrollEvent) {
+	if zoomModifierHeld() {
+		factor := float32(math.Pow(wheelZoomRate, float64(e.Scrolled.DY)))
+		b.SetViewport(b.viewport.ZoomAt(e.Position, factor))
+		return
+	}
+	b.Pan(e.Scrolled)
+}
+
+func zoomModifierHeld() bool {
+	return modifierHeld(fyne.KeyModifierControl | fyne.KeyModifierSuper)
+}
+
+// modifierHeld reports whether any of mods is held down
+func modifierHeld(mods fyne.KeyModifier) bool {
+	app := fyne.CurrentApp()
+	if app == nil {
+		return false
+	}
+	drv, ok := app.Driver().(desktop.Driver)
+	if !ok {
+		return false
+	}
+	return drv.CurrentKeyModifiers()&mods != 0
+}
@@ -6,10 +6,14 @@ import (
     "log"
     "net"
     "os"
+    "sort"
     "strings"
     "sync"
     "time"
 
+    "MyLocalBoard/internal/assets"
+    localnet "MyLocalBoard/internal/net"
+    "MyLocalBoard/internal/state"
     "MyLocalBoard/internal/ui"
 )
 
@@ -19,47 +23,207 @@ const (
     Port            = 8888
 )
 
+const (
+    // How often a client compares state vectors with the host to repair lost messages
+    AntiEntropyInterval = 10 * time.Second
+    // How often send queue metrics are logged
+    QueueStatsInterval = 30 * time.Second
+)
+
+// NetworkMessage is the wire format. Types:
+//   op           - a single CRDT operation (Op)
+//   sync_request - the sender's state vector (Vector); answered with sync_ops
+//   sync_ops     - operations the receiver is missing (Ops). If Vector is set,
+//                  the receiver answers with the operations the sender is missing.
+//   disconnect   - the host is closing the connection (Reason)
+//   hello        - a client introduces itself (ClientID)
+//   ping, pong   - heartbeat; pong echoes the ping's Sent time
+//   participants - the host's list of connected users and their latency
+//   error        - the host rejected a message (Error)
+//   viewport     - the part of the board ClientID is looking at (View)
+//   lock         - ClientID started or stopped editing a text element (Lock).
+//                  Locks are advisory and expire unless refreshed.
+//   asset_request - asks for a chunk of an asset an image shows (Asset, no data)
+//   asset_chunk   - a chunk of an asset (Asset); the receiver asks for the next one
+//   present      - ClientID started or stopped presenting (Presenting); the
+//                  others follow the presenter's viewport
+//   summon       - ClientID asks everyone to look at the same part of the board (View)
 type NetworkMessage struct {
-    Type    string    `json:"type"`
-    Path    ui.Path   `json:"path,omitempty"`
-    Paths   []ui.Path `json:"paths,omitempty"`
-    OwnerID string    `json:"owner_id,omitempty"`
+    Type         string                `json:"type"`
+    Op           *state.PathOperation  `json:"op,omitempty"`
+    Ops          []state.PathOperation `json:"ops,omitempty"`
+    Vector       state.StateVector     `json:"vector,omitempty"`
+    Reason       string                `json:"reason,omitempty"`
+    ClientID     string                `json:"client_id,omitempty"`
+    SiteID       string                `json:"site_id,omitempty"`
+    Sent         int64                 `json:"sent,omitempty"`
+    Participants []ui.Participant      `json:"participants,omitempty"`
+    Error        *ProtocolError        `json:"error,omitempty"`
+    View         *ui.ViewRect          `json:"view,omitempty"`
+    Lock         *ui.EditLock          `json:"lock,omitempty"`
+    Asset        *assets.Chunk         `json:"asset,omitempty"`
+    Presenting   bool                  `json:"presenting,omitempty"`
 }
 
+// ConnectionManager tracks the host's clients. Every client has its own send
+// queue, so a stalled client never blocks the others or the UI thread.
 type ConnectionManager struct {
-    connections map[net.Conn]bool
+    connections map[net.Conn]*hostClient
+    clientSites map[string]string // Client ID -> the site it first said hello from
     mu          sync.RWMutex
 }
 
+// hostClient is the host's view of one connected client
+type hostClient struct {
+    queue   *localnet.SendQueue
+    id      string        // Client ID from its hello, remote address until then
+    latency time.Duration // Last measured round-trip time
+}
+
 func NewConnectionManager() *ConnectionManager {
     return &ConnectionManager{
-        connections: make(map[net.Conn]bool),
+        connections: make(map[net.Conn]*hostClient),
+        clientSites: make(map[string]string),
     }
 }
 
 func (cm *ConnectionManager) Add(conn net.Conn) {
+    queue := localnet.NewSendQueue(conn, localnet.SendQueueCapacity)
+    queue.Farewell = func(reason string) []byte {
+        data, _ := json.Marshal(NetworkMessage{Type: "disconnect", Reason: reason})
+        return data
+    }
+    queue.OnClose = func(string) { cm.Remove(conn) }
+
     cm.mu.Lock()
-    defer cm.mu.Unlock()
-    cm.connections[conn] = true
+    cm.connections[conn] = &hostClient{queue: queue, id: conn.RemoteAddr().String()}
+    cm.mu.Unlock()
+    queue.Start()
     log.Printf("Added connection: %s", conn.RemoteAddr().String())
 }
 
 func (cm *ConnectionManager) Remove(conn net.Conn) {
     cm.mu.Lock()
-    defer cm.mu.Unlock()
+    client, exists := cm.connections[conn]
     delete(cm.connections, conn)
-    log.Printf("Removed connection: %s", conn.RemoteAddr().String())
+    cm.mu.Unlock()
+    if exists {
+        client.queue.Close("")
+        log.Printf("Removed connection: %s", conn.RemoteAddr().String())
+    }
 }
 
+// Broadcast queues data for every client except exclude
 func (cm *ConnectionManager) Broadcast(data []byte, exclude net.Conn) {
     cm.mu.RLock()
     defer cm.mu.RUnlock()
-    dataWithNewline := append(data, '\n')
-    for conn := range cm.connections {
+    for conn, client := range cm.connections {
         if conn != exclude {
-            if _, err := conn.Write(dataWithNewline); err != nil {
-                log.Printf("Error sending message: %v", err)
-            }
+            client.queue.Send(data)
+        }
+    }
+}
+
+// BroadcastLatest queues an update of which only the newest version per key
+// matters, such as a cursor position. Slow clients get it coalesced.
+func (cm *ConnectionManager) BroadcastLatest(key string, data []byte, exclude net.Conn) {
+    cm.mu.RLock()
+    defer cm.mu.RUnlock()
+    for conn, client := range cm.connections {
+        if conn != exclude {
+            client.queue.SendLatest(key, data)
+        }
+    }
+}
+
+// Send queues data for a single client
+func (cm *ConnectionManager) Send(conn net.Conn, data []byte) {
+    cm.mu.RLock()
+    defer cm.mu.RUnlock()
+    if client, exists := cm.connections[conn]; exists {
+        client.queue.Send(data)
+    }
+}
+
+// Disconnect closes a client's connection, telling it the reason
+func (cm *ConnectionManager) Disconnect(conn net.Conn, reason string) {
+    cm.mu.RLock()
+    defer cm.mu.RUnlock()
+    if client, exists := cm.connections[conn]; exists {
+        client.queue.Close(reason)
+    }
+}
+
+// QueueStats returns the send queue metrics of every client
+func (cm *ConnectionManager) QueueStats() map[string]localnet.QueueStats {
+    cm.mu.RLock()
+    defer cm.mu.RUnlock()
+    stats := make(map[string]localnet.QueueStats, len(cm.connections))
+    for conn, client := range cm.connections {
+        stats[conn.RemoteAddr().String()] = client.queue.Stats()
+    }
+    return stats
+}
+
+// ClaimClientID binds a connection to the ID and site a client introduced
+// itself with. An ID stays tied to its first site for the whole session; a
+// reconnecting client takes over from its previous, probably dead, connection.
+func (cm *ConnectionManager) ClaimClientID(conn net.Conn, id, siteID string) error {
+    if id == "" || siteID == "" {
+        return fmt.Errorf("client and site IDs are required")
+    }
+    if id == "host" || id == "all" {
+        return fmt.Errorf("client ID %q is reserved", id)
+    }
+
+    cm.mu.Lock()
+    defer cm.mu.Unlock()
+    if known, exists := cm.clientSites[id]; exists && known != siteID {
+        return fmt.Errorf("client ID %q belongs to another site", id)
+    }
+    cm.clientSites[id] = siteID
+    for other, client := range cm.connections {
+        if other != conn && client.id == id {
+            client.queue.Close("replaced by a new connection")
+        }
+    }
+    if client, exists := cm.connections[conn]; exists {
+        client.id = id
+    }
+    return nil
+}
+
+// SetLatency records the last round-trip time measured to a client
+func (cm *ConnectionManager) SetLatency(conn net.Conn, latency time.Duration) {
+    cm.mu.Lock()
+    defer cm.mu.Unlock()
+    if client, exists := cm.connections[conn]; exists {
+        client.latency = latency
+    }
+}
+
+// Participants lists the host followed by every connected client
+func (cm *ConnectionManager) Participants(hostID string) []ui.Participant {
+    cm.mu.RLock()
+    defer cm.mu.RUnlock()
+    participants := []ui.Participant{{ID: hostID}}
+    for _, client := range cm.connections {
+        participants = append(participants, ui.Participant{ID: client.id, Latency: client.latency})
+    }
+    sort.Slice(participants[1:], func(i, j int) bool {
+        return participants[i+1].ID < participants[j+1].ID
+    })
+    return participants
+}
+
+// logQueueStats periodically logs the send queue metrics returned by stats
+func logQueueStats(stats func() map[string]localnet.QueueStats) {
+    ticker := time.NewTicker(QueueStatsInterval)
+    defer ticker.Stop()
+    for range ticker.C {
+        for addr, s := range stats() {
+            log.Printf("[QUEUE] %s depth=%d high=%d sent=%d dropped=%d coalesced=%d",
+                addr, s.Depth, s.HighWater, s.Sent, s.Dropped, s.Coalesced)
         }
     }
 }
@@ -76,7 +240,9 @@ func getLocalIP() string {
 
 func main() {
 	args := os.Args
-	if len(args) > 1 && strings.HasPrefix(args[1], CustomURLScheme) {
+	if len(args) > 1 && args[1] == "--mesh" {
+		runMesh()
+	} else if len(args) > 1 && strings.HasPrefix(args[1], CustomURLScheme) {
 		runClient(args[1])
 	} else {
 		runHost()
@@ -87,21 +253,28 @@ func runHost() {
 	log.Println("Starting as HOST")
 	board := ui.NewBoardWidget()
 	board.SetLocalClientID("host")
+	doc := state.NewWhiteboardState()
 	connManager := NewConnectionManager()
 	
-	board.OnNewPath = func(p ui.Path) {
-		log.Printf("Host: New path with %d points", len(p.Points))
-		board.AddRemotePath(p) // Draw locally
-		msg := NetworkMessage{Type: "draw", Path: p}
+	bindEditing("Host", board, doc, func(msg NetworkMessage) {
 		data, _ := json.Marshal(msg)
 		connManager.Broadcast(data, nil)
-	}
+	})
 	
-	board.OnClear = func() {
-		log.Println("Host: Clearing paths")
-		board.ClearRemote(board.LocalClientID) // Clear locally
-		msg := NetworkMessage{Type: "clear", OwnerID: board.LocalClientID}
-		data, _ := json.Marshal(msg)
+	board.OnViewportChanged = func(view ui.ViewRect) {
+		data, _ := json.Marshal(NetworkMessage{Type: "viewport", ClientID: board.LocalClientID, View: &view})
+		connManager.BroadcastLatest(viewportKey(board.LocalClientID), data, nil)
+	}
+	board.OnLockChanged = func(lock ui.EditLock) {
+		data, _ := json.Marshal(NetworkMessage{Type: "lock", ClientID: board.LocalClientID, Lock: &lock})
+		connManager.Broadcast(data, nil)
+	}
+	board.OnPresent = func(presenting bool) {
+		data, _ := json.Marshal(NetworkMessage{Type: "present", ClientID: board.LocalClientID, Presenting: presenting})
+		connManager.Broadcast(data, nil)
+	}
+	board.OnSummon = func(view ui.ViewRect) {
+		data, _ := json.Marshal(NetworkMessage{Type: "summon", ClientID: board.LocalClientID, View: &view})
 		connManager.Broadcast(data, nil)
 	}
 	
@@ -111,30 +284,45 @@ func runHost() {
 		return paths
 	}
 	
-	board.OnLoad = func(paths []ui.Path) {
+	board.OnLoad = func(paths []ui.Path, layers []state.Layer, frames []state.Frame) {
 		log.Printf("Host: Loading %d paths and broadcasting to clients", len(paths))
 		
 		// Broadcast to clients in a goroutine to avoid blocking
 		go func() {
-			loadMsg := NetworkMessage{Type: "sync_state", Paths: paths}
-			loadData, err := json.Marshal(loadMsg)
-			if err != nil {
-				log.Printf("Error marshaling load message: %v", err)
-				return
+			for _, batch := range operationBatches(doc.ImportPaths(paths, layers, frames), nil) {
+				loadData, err := json.Marshal(batch)
+				if err != nil {
+					log.Printf("Error marshaling load message: %v", err)
+					return
+				}
+				connManager.Broadcast(loadData, nil)
 			}
-			connManager.Broadcast(loadData, nil)
 			log.Printf("Broadcasted %d paths to all clients", len(paths))
 		}()
 	}
 
-	go startHostServer(connManager, board)
+	// Fetch the assets of images whose first request went unanswered
+	go func() {
+		ticker := time.NewTicker(AntiEntropyInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			requestAssets(board.Assets, board.GetAllPathsAsValues(), func(msg NetworkMessage) {
+				data, _ := json.Marshal(msg)
+				connManager.Broadcast(data, nil)
+			})
+		}
+	}()
+
+	go startHostServer(connManager, board, doc)
+	go logQueueStats(connManager.QueueStats)
+	go pingClients(connManager, board)
 	hostIP := getLocalIP()
 	shareLink := fmt.Sprintf("%s%s:%d", CustomURLScheme, hostIP, Port)
 	log.Printf("Share link: %s", shareLink)
 	ui.RunApp(shareLink, board)
 }
 
-func startHostServer(connManager *ConnectionManager, board *ui.BoardWidget) {
+func startHostServer(connManager *ConnectionManager, board *ui.BoardWidget, doc *state.WhiteboardState) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", Port))
 	if err != nil { 
 		log.Fatalf("Server start failed: %v", err) 
@@ -150,61 +338,112 @@ func startHostServer(connManager *ConnectionManager, board *ui.BoardWidget) {
 			continue 
 		}
 		
+		// The client starts with a sync_request carrying its state vector,
+		// so it only receives the operations it is missing.
 		connManager.Add(conn)
-		
-		// Send current state to new client after a brief delay
-		go func(c net.Conn) { 
-			time.Sleep(100 * time.Millisecond)
-			sendCurrentStateToClient(c, board) 
-		}(conn)
-		
-		go handleHostConnection(conn, connManager, board)
-	}
-}
-
-func sendCurrentStateToClient(conn net.Conn, board *ui.BoardWidget) {
-	paths := board.GetAllPathsAsValues()
-	if len(paths) > 0 {
-		msg := NetworkMessage{Type: "sync_state", Paths: paths}
-		data, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("Error marshaling sync state: %v", err)
-			return
-		}
-		
-		if _, err := conn.Write(append(data, '\n')); err != nil { 
-			log.Printf("Sync failed: %v", err) 
-		} else {
-			log.Printf("Sent %d paths to new client", len(paths))
-		}
+		go handleHostConnection(conn, connManager, board, doc)
 	}
 }
 
-func handleHostConnection(conn net.Conn, connManager *ConnectionManager, board *ui.BoardWidget) {
+func handleHostConnection(conn net.Conn, connManager *ConnectionManager, board *ui.BoardWidget, doc *state.WhiteboardState) {
 	defer conn.Close()
 	defer connManager.Remove(conn)
 	
-	decoder := json.NewDecoder(conn)
+	reply := func(m NetworkMessage) {
+		data, _ := json.Marshal(m)
+		connManager.Send(conn, data)
+	}
+	relay := func(m NetworkMessage) {
+		data, _ := json.Marshal(m)
+		connManager.Broadcast(data, conn)
+	}
+	onLatency := func(rtt time.Duration) { connManager.SetLatency(conn, rtt) }
+	guard := newClientGuard(conn, connManager, doc)
+	session := &syncSession{doc: doc, board: board, reply: reply, relay: relay, check: guard.CheckOperation}
+	
+	reader := localnet.NewMessageReader(conn, MaxMessageBytes)
 	for {
-		var msg NetworkMessage
-		if err := decoder.Decode(&msg); err != nil { 
-			log.Printf("Connection closed or decode error: %v", err)
+		conn.SetReadDeadline(time.Now().Add(IdleTimeout))
+		data, err := reader.Next()
+		if err == localnet.ErrMessageTooLarge {
+			guard.Reject(&ProtocolError{Code: "message_too_large", Message: fmt.Sprintf("messages are limited to %d bytes", MaxMessageBytes)})
+			continue
+		}
+		if err != nil { 
+			if isTimeout(err) {
+				log.Printf("No heartbeat from %s for %s, dropping it", conn.RemoteAddr(), IdleTimeout)
+			} else {
+				log.Printf("Connection closed or read error: %v", err)
+			}
 			return 
 		}
 
+		var msg NetworkMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			guard.Reject(&ProtocolError{Code: "invalid_json", Message: err.Error()})
+			continue
+		}
+
 		switch msg.Type {
-		case "draw":
-			log.Printf("Host received draw from client with %d points", len(msg.Path.Points))
-			board.AddRemotePath(msg.Path)
+		case "hello":
+			if err := guard.Hello(msg); err != nil {
+				guard.Reject(err)
+				continue
+			}
+			// Show the newcomer where we are, and who is presenting
+			view := board.VisibleRect()
+			reply(NetworkMessage{Type: "viewport", ClientID: board.LocalClientID, View: &view})
+			if presenter := board.Presenter(); presenter != "" {
+				reply(NetworkMessage{Type: "present", ClientID: presenter, Presenting: true})
+			}
+			continue
+		case "viewport":
+			if err := guard.CheckViewport(msg); err != nil {
+				continue
+			}
+			msg.ClientID = guard.clientID
+			board.SetRemoteViewport(msg.ClientID, *msg.View)
+			data, _ := json.Marshal(msg)
+			connManager.BroadcastLatest(viewportKey(msg.ClientID), data, conn)
+			continue
+		case "lock":
+			if err := guard.CheckLock(msg); err != nil {
+				continue
+			}
+			msg.ClientID = guard.clientID
+			msg.Lock.Holder = guard.clientID
+			board.SetRemoteLock(*msg.Lock)
 			data, _ := json.Marshal(msg)
 			connManager.Broadcast(data, conn)
-		case "clear":
-			log.Printf("Host received clear from client: %s", msg.OwnerID)
-			board.ClearRemote(msg.OwnerID)
+			continue
+		case "present", "summon":
+			if err := guard.CheckPresenter(msg); err != nil {
+				continue
+			}
+			msg.ClientID = guard.clientID
+			if msg.Type == "present" {
+				board.SetPresenter(msg.ClientID, msg.Presenting)
+			} else {
+				board.Summoned(msg.ClientID, *msg.View)
+			}
 			data, _ := json.Marshal(msg)
 			connManager.Broadcast(data, conn)
-		default:
-			log.Printf("Unknown message type from client: %s", msg.Type)
+			continue
+		case "asset_request", "asset_chunk":
+			if guard.CheckAsset(msg) == nil {
+				handleAssetMessage(msg, board.Assets, reply)
+			}
+			continue
+		case "sync_ops":
+			if guard.CheckBatch(msg) != nil {
+				continue
+			}
+		}
+		if handleHeartbeat(msg, reply, onLatency) {
+			continue
+		}
+		if !session.Handle(msg) {
+			guard.Reject(&ProtocolError{Code: "unknown_type", Message: fmt.Sprintf("unknown message type %q", msg.Type)})
 		}
 	}
 }
@@ -212,6 +451,7 @@ func handleHostConnection(conn net.Conn, connManager *ConnectionManager, board *
 func runClient(link string) {
 	log.Println("Starting as CLIENT")
 	board := ui.NewBoardWidget()
+	doc := state.NewWhiteboardState()
 	
 	// Set up client-specific handlers
 	board.OnSave = func() []ui.Path {
@@ -220,82 +460,198 @@ func runClient(link string) {
 		return paths
 	}
 	
-	board.OnLoad = func(paths []ui.Path) {
-		// For clients, just load locally - don't sync over network during load
-		log.Printf("Client: Loading %d paths locally", len(paths))
-	}
+	// No OnLoad: loading a file replaces the board for everyone, which only
+	// the host may do, so clients can't load
+	// Nor may clients lock or unlock layers, which the background needs
+	board.CanLockLayers = false
 	
-	go connectToHost(link, board)
+	go connectToHost(link, board, doc)
 	ui.RunApp("", board)
 }
 
-func connectToHost(link string, board *ui.BoardWidget) {
+// hostConnection queues writes to the host and survives reconnects.
+// Messages sent while disconnected are dropped; the state vector exchange
+// on reconnect delivers them.
+type hostConnection struct {
+	mu    sync.Mutex
+	queue *localnet.SendQueue
+}
+
+func (hc *hostConnection) Attach(conn net.Conn) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.queue = localnet.NewSendQueue(conn, localnet.SendQueueCapacity)
+	hc.queue.Start()
+}
+
+func (hc *hostConnection) Detach() {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.queue != nil {
+		hc.queue.Close("")
+		hc.queue = nil
+	}
+}
+
+// SendLatest queues msg, replacing any queued message with the same key
+func (hc *hostConnection) SendLatest(key string, msg NetworkMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling %s message: %v", msg.Type, err)
+		return
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.queue != nil {
+		hc.queue.SendLatest(key, data)
+	}
+}
+
+func (hc *hostConnection) Send(msg NetworkMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling %s message: %v", msg.Type, err)
+		return
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.queue != nil {
+		hc.queue.Send(data)
+	}
+}
+
+func connectToHost(link string, board *ui.BoardWidget, doc *state.WhiteboardState) {
 	address := strings.TrimPrefix(link, CustomURLScheme)
 	address = strings.TrimSuffix(address, "/")
+	host := &hostConnection{}
 	
-	log.Printf("Client connecting to: %s", address)
-	board.SetStatus("Connecting to " + address + "...")
-	time.Sleep(500 * time.Millisecond)
-	
-	conn, err := net.Dial("tcp", address)
-	if err != nil { 
-		board.SetStatus("Connection failed: " + err.Error())
-		log.Printf("Connection failed: %v", err)
-		return 
+	bindEditing("Client", board, doc, host.Send)
+	board.OnViewportChanged = func(view ui.ViewRect) {
+		host.SendLatest("viewport", NetworkMessage{Type: "viewport", View: &view})
 	}
-	defer conn.Close()
-	
-	localAddr := conn.LocalAddr().String()
-	board.SetLocalClientID(localAddr)
-	board.SetStatus("Connected as " + localAddr)
-	log.Println("Client connected as", localAddr)
-	
-	encoder := json.NewEncoder(conn)
-	
-	board.OnNewPath = func(p ui.Path) {
-		log.Printf("Client: New path with %d points", len(p.Points))
-		board.AddRemotePath(p) // Draw locally
-		msg := NetworkMessage{Type: "draw", Path: p}
-		if err := encoder.Encode(msg); err != nil {
-			log.Printf("Error sending draw message: %v", err)
-		}
+	board.OnLockChanged = func(lock ui.EditLock) {
+		host.Send(NetworkMessage{Type: "lock", Lock: &lock})
+	}
+	board.OnPresent = func(presenting bool) {
+		host.Send(NetworkMessage{Type: "present", Presenting: presenting})
 	}
+	board.OnSummon = func(view ui.ViewRect) {
+		host.Send(NetworkMessage{Type: "summon", View: &view})
+	}
+
+	// Periodic anti-entropy: the host answers with whatever we are missing and
+	// its own vector, so lost messages are repaired in both directions.
+	go func() {
+		ticker := time.NewTicker(AntiEntropyInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			host.Send(NetworkMessage{Type: "sync_request", Vector: doc.StateVector()})
+			requestAssets(board.Assets, board.GetAllPathsAsValues(), host.Send)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(PingInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			host.Send(newPing())
+		}
+	}()
 	
-	board.OnClear = func() {
-		log.Println("Client: Clearing paths")
-		board.ClearRemote(board.LocalClientID) // Clear locally
-		msg := NetworkMessage{Type: "clear", OwnerID: board.LocalClientID}
-		if err := encoder.Encode(msg); err != nil {
-			log.Printf("Error sending clear message: %v", err)
+	backoff := time.Second
+	for {
+		log.Printf("Client connecting to: %s", address)
+		board.SetStatus("Connecting to " + address + "...")
+		time.Sleep(500 * time.Millisecond)
+		
+		conn, err := net.Dial("tcp", address)
+		if err != nil { 
+			board.SetStatus(fmt.Sprintf("Connection failed: %v (retrying in %s)", err, backoff))
+			log.Printf("Connection failed: %v", err)
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue 
+		}
+		backoff = time.Second
+		
+		// Keep the first address as our identity so our paths stay ours across reconnects
+		if board.LocalClientID == "" {
+			board.SetLocalClientID(conn.LocalAddr().String())
+		}
+		board.SetStatus("Connected as " + board.LocalClientID)
+		log.Println("Client connected as", board.LocalClientID)
+		
+		host.Attach(conn)
+		host.Send(NetworkMessage{Type: "hello", ClientID: board.LocalClientID, SiteID: doc.GetSiteID()})
+		host.Send(NetworkMessage{Type: "sync_request", Vector: doc.StateVector()})
+		view := board.VisibleRect()
+		host.SendLatest("viewport", NetworkMessage{Type: "viewport", View: &view})
+		if board.Presenting() {
+			host.Send(NetworkMessage{Type: "present", Presenting: true})
+		}
+		err = readFromHost(conn, board, doc, host)
+		host.Detach()
+		conn.Close()
+		board.SetParticipants(nil)
+		
+		if isTimeout(err) {
+			board.SetStatus(fmt.Sprintf("Connection lost: no response from host for %s - reconnecting...", IdleTimeout))
+		} else {
+			board.SetStatus("Disconnected: " + err.Error() + " - reconnecting...")
 		}
+		log.Printf("Disconnected: %v", err)
 	}
+}
 
+func readFromHost(conn net.Conn, board *ui.BoardWidget, doc *state.WhiteboardState, host *hostConnection) error {
+	session := &syncSession{doc: doc, board: board, reply: host.Send, ownOnly: true}
 	decoder := json.NewDecoder(conn)
 	for {
 		var msg NetworkMessage
+		conn.SetReadDeadline(time.Now().Add(IdleTimeout))
 		if err := decoder.Decode(&msg); err != nil { 
-			board.SetStatus("Disconnected: " + err.Error())
-			log.Printf("Disconnected: %v", err)
-			return 
+			return err
 		}
 		
 		switch msg.Type {
-		case "draw":
-			if msg.Path.OwnerID != board.LocalClientID { 
-				log.Printf("Client: Received remote path with %d points", len(msg.Path.Points))
-				board.AddRemotePath(msg.Path) 
+		case "disconnect":
+			return fmt.Errorf("host closed the connection: %s", msg.Reason)
+		case "participants":
+			board.SetParticipants(msg.Participants)
+			continue
+		case "viewport":
+			if checkViewport(msg.View) == nil {
+				board.SetRemoteViewport(msg.ClientID, *msg.View)
+			}
+			continue
+		case "lock":
+			if msg.Lock != nil {
+				board.SetRemoteLock(*msg.Lock)
+			}
+			continue
+		case "present":
+			if msg.ClientID != "" {
+				board.SetPresenter(msg.ClientID, msg.Presenting)
 			}
-		case "clear":
-			log.Printf("Client: Received clear for owner: %s", msg.OwnerID)
-			board.ClearRemote(msg.OwnerID)
-		case "sync_state":
-			log.Printf("Client: Received sync_state with %d paths", len(msg.Paths))
-			board.ClearRemote("all")
-			for _, path := range msg.Paths {
-				board.AddRemotePath(path)
+			continue
+		case "summon":
+			if checkViewport(msg.View) == nil {
+				board.Summoned(msg.ClientID, *msg.View)
 			}
-		default:
+			continue
+		case "error":
+			if msg.Error != nil {
+				log.Printf("Client: Host rejected a message: %v", msg.Error)
+				board.SetStatus("Host rejected a message: " + msg.Error.Message)
+			}
+			continue
+		}
+		if handleHeartbeat(msg, host.Send, board.SetLatency) || handleAssetMessage(msg, board.Assets, host.Send) {
+			continue
+		}
+		if !session.Handle(msg) {
 			log.Printf("Client: Unknown message type: %s", msg.Type)
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	localnet "MyLocalBoard/internal/net"
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/ui"
+)
+
+// Mesh mode: there is no host. Every peer announces itself on the LAN,
+// connects to the peers it discovers and gossips operations to its
+// neighbours. Operations are deduplicated by ID, so a peer only relays what
+// was new to it, and the CRDT makes every board converge.
+const (
+	MeshPort       = 8890
+	BeaconInterval = 2 * time.Second
+)
+
+// peerCheck returns the check of operations received from peers. Peers are
+// trusted with any owner, as operations reach us relayed, but not with
+// malformed ones or ones stamped far ahead of doc's clock.
+func peerCheck(doc *state.WhiteboardState) func(op state.PathOperation, live bool) error {
+	return func(op state.PathOperation, _ bool) error {
+		err := state.ValidateOperation(op, state.DefaultLimits)
+		if err == nil {
+			err = doc.CheckTimestamp(op, state.DefaultLimits)
+		}
+		if err != nil {
+			log.Printf("Mesh: Rejected operation %s: %v", op.ID, err)
+		}
+		return err
+	}
+}
+
+func runMesh() {
+	log.Println("Starting as MESH peer")
+	board := ui.NewBoardWidget()
+	doc := state.NewWhiteboardState()
+	board.SetLocalClientID("peer-" + doc.GetSiteID())
+	peers := localnet.NewPeerManager()
+	peers.IdleTimeout = IdleTimeout
+	peers.MaxMessageBytes = MaxMessageBytes
+
+	broadcast := func(msg NetworkMessage) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("Error marshaling %s message: %v", msg.Type, err)
+			return
+		}
+		peers.Broadcast(data)
+	}
+	updateStatus := func() {
+		board.SetStatus(fmt.Sprintf("Mesh peer %s - %d peers connected", board.LocalClientID, peers.PeerCount()))
+	}
+
+	// Sites we have dialed, so each pair of peers shares a single connection
+	var dialMu sync.Mutex
+	dialed := make(map[string]string) // siteID -> address
+
+	// Neighbours by connection, with their board ID and latency
+	var neighbourMu sync.Mutex
+	neighbours := make(map[string]*ui.Participant)
+	participants := func() []ui.Participant {
+		neighbourMu.Lock()
+		defer neighbourMu.Unlock()
+		list := []ui.Participant{{ID: board.LocalClientID}}
+		for _, p := range neighbours {
+			list = append(list, *p)
+		}
+		sort.Slice(list[1:], func(i, j int) bool { return list[i+1].ID < list[j+1].ID })
+		return list
+	}
+
+	peers.OnPeerConnected = func(clientID string) {
+		neighbourMu.Lock()
+		neighbours[clientID] = &ui.Participant{ID: clientID}
+		neighbourMu.Unlock()
+		updateStatus()
+		// Join: introduce ourselves and exchange state vectors with the new neighbour
+		view := board.VisibleRect()
+		for _, msg := range []NetworkMessage{
+			{Type: "hello", ClientID: board.LocalClientID},
+			{Type: "sync_request", Vector: doc.StateVector()},
+			{Type: "viewport", ClientID: board.LocalClientID, View: &view},
+			{Type: "present", ClientID: board.LocalClientID, Presenting: board.Presenting()},
+		} {
+			data, _ := json.Marshal(msg)
+			peers.SendToClient(clientID, data)
+		}
+	}
+	peers.OnPeerDisconnected = func(clientID string) {
+		dialMu.Lock()
+		for site, address := range dialed {
+			if address == clientID {
+				delete(dialed, site)
+			}
+		}
+		dialMu.Unlock()
+		neighbourMu.Lock()
+		delete(neighbours, clientID)
+		neighbourMu.Unlock()
+		board.SetParticipants(participants())
+		updateStatus()
+	}
+
+	bindEditing("Mesh", board, doc, broadcast)
+	board.OnViewportChanged = func(view ui.ViewRect) {
+		data, _ := json.Marshal(NetworkMessage{Type: "viewport", ClientID: board.LocalClientID, View: &view})
+		peers.BroadcastLatest(viewportKey(board.LocalClientID), data)
+	}
+	// Like viewports, locks and presenting only reach our neighbours
+	board.OnLockChanged = func(lock ui.EditLock) {
+		broadcast(NetworkMessage{Type: "lock", ClientID: board.LocalClientID, Lock: &lock})
+	}
+	board.OnPresent = func(presenting bool) {
+		broadcast(NetworkMessage{Type: "present", ClientID: board.LocalClientID, Presenting: presenting})
+	}
+	board.OnSummon = func(view ui.ViewRect) {
+		broadcast(NetworkMessage{Type: "summon", ClientID: board.LocalClientID, View: &view})
+	}
+
+	board.OnSave = func() []ui.Path {
+		paths := board.GetAllPathsAsValues()
+		log.Printf("Mesh: Saving %d paths", len(paths))
+		return paths
+	}
+
+	board.OnLoad = func(paths []ui.Path, layers []state.Layer, frames []state.Frame) {
+		log.Printf("Mesh: Loading %d paths and broadcasting to peers", len(paths))
+		go func() {
+			for _, batch := range operationBatches(doc.ImportPaths(paths, layers, frames), nil) {
+				broadcast(batch)
+			}
+		}()
+	}
+
+	check := peerCheck(doc)
+	go func() {
+		for m := range peers.Messages {
+			var msg NetworkMessage
+			if err := json.Unmarshal(m.Data, &msg); err != nil {
+				log.Printf("Mesh: Decode error from %s: %v", m.ClientID, err)
+				continue
+			}
+			clientID := m.ClientID
+			reply := func(r NetworkMessage) {
+				data, _ := json.Marshal(r)
+				peers.SendToClient(clientID, data)
+			}
+			relay := func(r NetworkMessage) {
+				data, _ := json.Marshal(r)
+				peers.BroadcastExcept(clientID, data)
+			}
+			if msg.Type == "hello" {
+				neighbourMu.Lock()
+				if p, ok := neighbours[clientID]; ok && msg.ClientID != "" {
+					p.ID = msg.ClientID
+				}
+				neighbourMu.Unlock()
+				continue
+			}
+			if msg.Type == "viewport" {
+				if checkViewport(msg.View) == nil && msg.ClientID != "" {
+					board.SetRemoteViewport(msg.ClientID, *msg.View)
+				}
+				continue
+			}
+			if msg.Type == "present" {
+				if msg.ClientID != "" {
+					board.SetPresenter(msg.ClientID, msg.Presenting)
+				}
+				continue
+			}
+			if msg.Type == "summon" {
+				if checkViewport(msg.View) == nil && msg.ClientID != "" {
+					board.Summoned(msg.ClientID, *msg.View)
+				}
+				continue
+			}
+			if msg.Type == "lock" {
+				if msg.Lock != nil && msg.ClientID != "" {
+					msg.Lock.Holder = msg.ClientID
+					board.SetRemoteLock(*msg.Lock)
+				}
+				continue
+			}
+			onLatency := func(rtt time.Duration) {
+				neighbourMu.Lock()
+				if p, ok := neighbours[clientID]; ok {
+					p.Latency = rtt
+				}
+				neighbourMu.Unlock()
+			}
+			if handleHeartbeat(msg, reply, onLatency) || handleAssetMessage(msg, board.Assets, reply) {
+				continue
+			}
+			session := &syncSession{doc: doc, board: board, reply: reply, relay: relay, check: check}
+			if !session.Handle(msg) {
+				log.Printf("Mesh: Unknown message type from %s: %s", clientID, msg.Type)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(PingInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			broadcast(newPing())
+			board.SetParticipants(participants())
+		}
+	}()
+
+	// Anti-entropy with every neighbour repairs messages dropped anywhere in the mesh
+	go func() {
+		ticker := time.NewTicker(AntiEntropyInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			broadcast(NetworkMessage{Type: "sync_request", Vector: doc.StateVector()})
+			requestAssets(board.Assets, board.GetAllPathsAsValues(), broadcast)
+		}
+	}()
+
+	go peers.StartTCPServer(MeshPort)
+	go logQueueStats(peers.QueueStats)
+	go localnet.AnnouncePeer(localnet.Beacon{SiteID: doc.GetSiteID(), Port: MeshPort}, BeaconInterval)
+	go localnet.DiscoverPeers(func(address string, beacon localnet.Beacon) {
+		// Only the peer with the lower site ID dials, which also skips our own beacon
+		if beacon.SiteID <= doc.GetSiteID() {
+			return
+		}
+		dialMu.Lock()
+		if _, known := dialed[beacon.SiteID]; known {
+			dialMu.Unlock()
+			return
+		}
+		dialed[beacon.SiteID] = address
+		dialMu.Unlock()
+
+		log.Printf("Mesh: Discovered peer %s at %s", beacon.SiteID, address)
+		go func() {
+			if err := peers.Connect(address); err != nil {
+				log.Printf("Mesh: Failed to connect to %s: %v", address, err)
+				dialMu.Lock()
+				delete(dialed, beacon.SiteID)
+				dialMu.Unlock()
+			}
+		}()
+	})
+
+	ui.RunApp("", board)
+}
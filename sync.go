@@ -0,0 +1,168 @@
+package main
+
+import (
+	"log"
+
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/ui"
+)
+
+// Operations are shipped in batches so that no message exceeds the host's
+// MaxMessageBytes, however far behind a peer is.
+const (
+	MaxOpsPerMessage  = 500
+	maxBatchBytes     = MaxMessageBytes / 2
+	approxPointBytes  = 40  // JSON size of one point
+	approxWidthBytes  = 12  // JSON size of one width
+	approxOpBaseBytes = 300 // JSON size of an operation without points
+)
+
+// applyOperations merges remote operations into doc, mirrors the resulting
+// changes on the board and returns the operations that were new to us.
+func applyOperations(doc *state.WhiteboardState, board *ui.BoardWidget, ops []state.PathOperation) []state.PathOperation {
+	fresh := make([]state.PathOperation, 0, len(ops))
+	for _, op := range ops {
+		change, applied := doc.Apply(op)
+		if !applied {
+			continue
+		}
+		showChange(board, change)
+		fresh = append(fresh, op)
+	}
+	return fresh
+}
+
+// showChange mirrors a change of the document on the board. Paths that were
+// replaced by a new version keep their place in the drawing order.
+func showChange(board *ui.BoardWidget, change state.Change) {
+	replaced := make(map[string]bool, len(change.Added))
+	for _, p := range change.Added {
+		replaced[p.ID] = true
+	}
+	removed := make([]string, 0, len(change.Removed))
+	for _, id := range change.Removed {
+		if !replaced[id] {
+			removed = append(removed, id)
+		}
+	}
+	board.RemovePaths(removed)
+	board.UpdatePaths(change.Added)
+	if change.Layers != nil {
+		board.SetLayers(change.Layers)
+	}
+	if change.Frames != nil {
+		board.SetFrames(change.Frames)
+	}
+}
+
+// operationBatches splits ops into sync_ops messages of bounded size. vector,
+// if set, is attached to the last one; at least one message is returned.
+func operationBatches(ops []state.PathOperation, vector state.StateVector) []NetworkMessage {
+	batches := make([]NetworkMessage, 0, 1)
+	current := NetworkMessage{Type: "sync_ops"}
+	size := 0
+	for _, op := range ops {
+		opSize := approxOpBaseBytes
+		if op.Path != nil {
+			opSize += len(op.Path.Points)*approxPointBytes + len(op.Path.Widths)*approxWidthBytes
+		}
+		if len(current.Ops) > 0 && (len(current.Ops) >= MaxOpsPerMessage || size+opSize > maxBatchBytes) {
+			batches = append(batches, current)
+			current = NetworkMessage{Type: "sync_ops"}
+			size = 0
+		}
+		current.Ops = append(current.Ops, op)
+		size += opSize
+	}
+	current.Vector = vector
+	return append(batches, current)
+}
+
+// syncSession handles the operation and state vector messages of one connection.
+type syncSession struct {
+	doc   *state.WhiteboardState
+	board *ui.BoardWidget
+	reply func(NetworkMessage) // Answers the sender
+	relay func(NetworkMessage) // Forwards new operations to everyone else, if set
+	// check, if set, vets every incoming operation; rejected ones are skipped.
+	// live is false for operations arriving in a sync_ops batch.
+	check func(op state.PathOperation, live bool) error
+	// ownOnly limits what we push back to operations created on this site.
+	// Clients use it, as the host only accepts a client's own operations.
+	ownOnly bool
+}
+
+func (s *syncSession) missing(sv state.StateVector) []state.PathOperation {
+	if s.ownOnly {
+		return s.doc.MissingLocalOperations(sv)
+	}
+	return s.doc.MissingOperations(sv)
+}
+
+func (s *syncSession) accepted(ops []state.PathOperation, live bool) []state.PathOperation {
+	if s.check == nil {
+		return ops
+	}
+	valid := make([]state.PathOperation, 0, len(ops))
+	for _, op := range ops {
+		if err := s.check(op, live); err == nil {
+			valid = append(valid, op)
+		}
+	}
+	return valid
+}
+
+// Handle processes op and state vector messages. It returns false for any
+// other message type.
+func (s *syncSession) Handle(msg NetworkMessage) bool {
+	switch msg.Type {
+	case "op":
+		if msg.Op == nil {
+			return true
+		}
+		ops := s.accepted([]state.PathOperation{*msg.Op}, true)
+		fresh := applyOperations(s.doc, s.board, ops)
+		if len(fresh) > 0 && s.relay != nil {
+			s.relay(msg)
+		}
+		s.fetchAssets(fresh)
+	case "sync_request":
+		// Attach our vector so the peer can send back what we lack
+		for _, batch := range operationBatches(s.missing(msg.Vector), s.doc.StateVector()) {
+			s.reply(batch)
+		}
+	case "sync_ops":
+		fresh := applyOperations(s.doc, s.board, s.accepted(msg.Ops, false))
+		s.fetchAssets(fresh)
+		if len(fresh) > 0 {
+			log.Printf("Received %d missing operations", len(fresh))
+			if s.relay != nil {
+				for _, batch := range operationBatches(fresh, nil) {
+					s.relay(batch)
+				}
+			}
+		}
+		if msg.Vector != nil {
+			if missing := s.missing(msg.Vector); len(missing) > 0 {
+				for _, batch := range operationBatches(missing, nil) {
+					s.reply(batch)
+				}
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// fetchAssets asks the sender of ops for the assets of the images they add
+// that we lack; whoever sent an image most likely has its picture
+func (s *syncSession) fetchAssets(ops []state.PathOperation) {
+	var paths []state.Path
+	for _, op := range ops {
+		if op.Path != nil && op.Path.Image != nil {
+			paths = append(paths, *op.Path)
+		}
+	}
+	requestAssets(s.board.Assets, paths, s.reply)
+}
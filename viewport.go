@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"MyLocalBoard/internal/state"
+	"MyLocalBoard/internal/ui"
+)
+
+// viewportKey coalesces viewport updates per participant in send queues, so
+// a slow peer only ever gets the newest view.
+func viewportKey(clientID string) string {
+	return "viewport:" + clientID
+}
+
+// checkViewport validates a viewport sent by a peer
+func checkViewport(view *ui.ViewRect) error {
+	if view == nil {
+		return fmt.Errorf("viewport message without a view")
+	}
+	limit := float64(state.DefaultLimits.MaxCoordinate)
+	for _, v := range []float32{view.X, view.Y, view.Width, view.Height} {
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) || math.Abs(f) > 2*limit {
+			return fmt.Errorf("viewport out of bounds")
+		}
+	}
+	if view.Width <= 0 || view.Height <= 0 {
+		return fmt.Errorf("viewport has no area")
+	}
+	return nil
+}